@@ -0,0 +1,110 @@
+package tokenizer
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Anonymizer renames identifiers and replaces the text of strings and
+// comments so code can be shared without its names and messages. The same
+// name or string always gets the same placeholder (id1, id2, ... and str1,
+// str2, ...); keywords, types, numbers, operators and every position are
+// kept.
+type Anonymizer struct {
+	names   map[string]string
+	strings map[string]string
+}
+
+func NewAnonymizer() *Anonymizer {
+	return &Anonymizer{names: map[string]string{}, strings: map[string]string{}}
+}
+
+func (an *Anonymizer) name(s string) string {
+	if p, ok := an.names[s]; ok {
+		return p
+	}
+	p := fmt.Sprintf("id%d", len(an.names)+1)
+	an.names[s] = p
+	return p
+}
+
+func (an *Anonymizer) str(s string) string {
+	if p, ok := an.strings[s]; ok {
+		return p
+	}
+	p := fmt.Sprintf("str%d", len(an.strings)+1)
+	an.strings[s] = p
+	return p
+}
+
+// Tokens returns anonymized copies of toks, which may be a token stream or
+// comments. The pieces of an interpolated string each get a placeholder.
+func (an *Anonymizer) Tokens(toks []Token) []Token {
+	out := make([]Token, len(toks))
+	for i, t := range toks {
+		switch t.Type {
+		case IDENT:
+			t.Lexeme, t.Value = an.name(NameOf(t)), nil
+		case STRING_LIT:
+			quote := t.Lexeme[:1]
+			if strings.HasPrefix(t.Lexeme, `"""`) {
+				quote = `"""`
+			}
+			p := an.str(valueOr(t))
+			t.Lexeme, t.Value = quote+p+quote, &p
+		case STRING_SEGMENT:
+			p := an.str(valueOr(t))
+			t.Lexeme, t.Value = p, &p
+			if i == 0 || toks[i-1].Type != INTERP_END {
+				t.Lexeme = `"` + t.Lexeme
+			}
+			if i+1 == len(toks) || toks[i+1].Type != INTERP_START {
+				t.Lexeme += `"`
+			}
+		case COMMENT, DOC_COMMENT:
+			if strings.HasPrefix(t.Lexeme, "/*") {
+				t.Lexeme = "/* comment */"
+			} else {
+				t.Lexeme = "// comment"
+			}
+		case DIRECTIVE, PRAGMA:
+			// the argument is code: `#define N limit`
+			if t.Arg != nil && *t.Arg != "" {
+				head := strings.TrimSuffix(strings.TrimRight(t.Lexeme, " \t"), *t.Arg)
+				arg := an.text(*t.Arg)
+				t.Lexeme, t.Arg = head+arg, &arg
+			}
+		}
+		if t.Doc != nil {
+			doc := "doc"
+			t.Doc = &doc
+		}
+		out[i] = t
+	}
+	return out
+}
+
+// text anonymizes the tokens of a piece of code, such as a directive's
+// argument, keeping the blanks between them.
+func (an *Anonymizer) text(s string) string {
+	lx := NewLexer(s)
+	toks, _ := lx.LexAll()
+	var b strings.Builder
+	prev := 0
+	for _, t := range an.Tokens(MergeTrivia(toks, lx.Comments())) {
+		b.WriteString(s[prev:t.Offset])
+		b.WriteString(t.Lexeme)
+		prev = t.End
+	}
+	b.WriteString(s[prev:])
+	return b.String()
+}
+
+// valueOr returns the decoded value of a literal, or its lexeme when it has
+// none.
+func valueOr(t Token) string {
+	if t.Value != nil {
+		return *t.Value
+	}
+	return t.Lexeme
+}
@@ -0,0 +1,32 @@
+package tokenizer
+
+import (
+	"fmt"
+)
+
+// bidiControls names the Unicode bidirectional embedding, override and
+// isolate controls. A terminal or editor reorders the text around them, so
+// code containing one can display differently than it lexes: the "Trojan
+// Source" attack hides code in what looks like a comment or a string.
+var bidiControls = map[rune]string{
+	0x202A: "LEFT-TO-RIGHT EMBEDDING",
+	0x202B: "RIGHT-TO-LEFT EMBEDDING",
+	0x202C: "POP DIRECTIONAL FORMATTING",
+	0x202D: "LEFT-TO-RIGHT OVERRIDE",
+	0x202E: "RIGHT-TO-LEFT OVERRIDE",
+	0x2066: "LEFT-TO-RIGHT ISOLATE",
+	0x2067: "RIGHT-TO-LEFT ISOLATE",
+	0x2068: "FIRST STRONG ISOLATE",
+	0x2069: "POP DIRECTIONAL ISOLATE",
+}
+
+// IsBidiControl reports whether r is one of the bidirectional controls
+// that the lexer reports.
+func IsBidiControl(r rune) bool {
+	return r >= 0x202A && r <= 0x202E || r >= 0x2066 && r <= 0x2069
+}
+
+// bidiMessage is the error for the control r.
+func bidiMessage(r rune) string {
+	return fmt.Sprintf("bidirectional control character U+%04X (%s) can make the code display differently than it reads", r, bidiControls[r])
+}
@@ -0,0 +1,141 @@
+package tokenizer
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// BPE is a byte-level byte-pair encoding. IDs 0 to 255 stand for the bytes
+// themselves, so any input can be encoded (byte fallback), and merge i
+// joins the two tokens of its pair into token 256+i.
+type BPE struct {
+	merges [][2]int
+	ranks  map[[2]int]int // pair -> merge index
+	pieces [][]byte       // id -> the bytes it stands for
+	cache  map[string][]int
+}
+
+// bpeFile is the JSON form of a merge table.
+type bpeFile struct {
+	Merges [][2]int `json:"merges"`
+}
+
+// NewBPE builds an encoding from its merges, in priority order. A merge may
+// only use the bytes and the tokens of earlier merges.
+func NewBPE(merges [][2]int) (*BPE, error) {
+	b := &BPE{merges: merges, ranks: map[[2]int]int{}, pieces: make([][]byte, 256, 256+len(merges)), cache: map[string][]int{}}
+	for i := range b.pieces[:256] {
+		b.pieces[i] = []byte{byte(i)}
+	}
+	for i, m := range merges {
+		id := 256 + i
+		if m[0] < 0 || m[0] >= id || m[1] < 0 || m[1] >= id {
+			return nil, fmt.Errorf("bpe: merge %d joins %d and %d, but only ids below %d exist", i, m[0], m[1], id)
+		}
+		if _, dup := b.ranks[m]; dup {
+			return nil, fmt.Errorf("bpe: merge %d repeats the pair %d %d", i, m[0], m[1])
+		}
+		b.ranks[m] = i
+		piece := append(append([]byte{}, b.pieces[m[0]]...), b.pieces[m[1]]...)
+		b.pieces = append(b.pieces, piece)
+	}
+	return b, nil
+}
+
+// LoadBPE reads a JSON merge table: {"merges": [[97, 98], [256, 99], ...]}.
+func LoadBPE(path string) (*BPE, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read merges error: %w", err)
+	}
+	var f bpeFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return NewBPE(f.Merges)
+}
+
+// Size returns the number of token ids, bytes included.
+func (b *BPE) Size() int { return len(b.pieces) }
+
+// Piece returns the bytes id stands for, or nil if there is no such id.
+func (b *BPE) Piece(id int) []byte {
+	if id < 0 || id >= len(b.pieces) {
+		return nil
+	}
+	return b.pieces[id]
+}
+
+// Encode splits src into lexical pieces, its tokens, comments and the text
+// between them, and encodes each one on its own: a subword never spans two
+// tokens. Decode(Encode(src)) is src.
+func (b *BPE) Encode(src string) []int {
+	var ids []int
+	for _, p := range bpePieces(src) {
+		ids = append(ids, b.EncodePiece(p)...)
+	}
+	return ids
+}
+
+// bpePieces returns the lexemes of the lossless token stream of src.
+func bpePieces(src string) []string {
+	lx := NewLexer(src)
+	toks, _ := lx.LexAll()
+	var out []string
+	for _, t := range WithTrivia(src, toks, lx.Comments(), ColumnBytes) {
+		if t.Lexeme != "" {
+			out = append(out, t.Lexeme)
+		}
+	}
+	return out
+}
+
+// EncodePiece encodes s by starting from its bytes and applying, as long
+// as one applies, the earliest merge found among the adjacent pairs.
+func (b *BPE) EncodePiece(s string) []int {
+	if ids, ok := b.cache[s]; ok {
+		return ids
+	}
+	ids := make([]int, len(s))
+	for i := 0; i < len(s); i++ {
+		ids[i] = int(s[i])
+	}
+	for len(ids) > 1 {
+		best, rank := -1, len(b.merges)
+		for i := 0; i+1 < len(ids); i++ {
+			if r, ok := b.ranks[[2]int{ids[i], ids[i+1]}]; ok && r < rank {
+				best, rank = i, r
+			}
+		}
+		if best < 0 {
+			break
+		}
+		// merge every occurrence of the pair, left to right
+		pair, out := b.merges[rank], ids[:0]
+		for i := 0; i < len(ids); i++ {
+			if i+1 < len(ids) && ids[i] == pair[0] && ids[i+1] == pair[1] {
+				out = append(out, 256+rank)
+				i++
+				continue
+			}
+			out = append(out, ids[i])
+		}
+		ids = out
+	}
+	b.cache[s] = ids
+	return ids
+}
+
+// Decode returns the bytes ids stand for.
+func (b *BPE) Decode(ids []int) ([]byte, error) {
+	var out []byte
+	for i, id := range ids {
+		p := b.Piece(id)
+		if p == nil {
+			return nil, fmt.Errorf("bpe: id %d at index %d is not in the vocabulary of %d", id, i, len(b.pieces))
+		}
+		out = append(out, p...)
+	}
+	return out, nil
+}
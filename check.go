@@ -0,0 +1,88 @@
+package tokenizer
+
+import (
+	"fmt"
+)
+
+// closerOf maps each opening bracket to its closing one.
+var closerOf = map[TokenType]TokenType{LPAREN: RPAREN, LBRACE: RBRACE, LBRACK: RBRACK}
+
+// walkBrackets pairs the (), {} and [] of toks, calling pair with the
+// indices of each opener and its closer. The braces of string
+// interpolations are the lexer's business and are left out. A closer of the
+// wrong kind that matches an opener further out closes it, and cut is
+// called for each opener in between; otherwise the closer is stray, as is
+// one without any opener. unclosed is called for the openers left at the
+// end.
+func walkBrackets(toks []Token, pair, cut func(open, close int), stray func(close int, open int), unclosed func(open int)) {
+	var open []int
+	for i, t := range toks {
+		switch t.Type {
+		case LPAREN, LBRACE, LBRACK:
+			open = append(open, i)
+		case RPAREN, RBRACE, RBRACK:
+			outer := len(open) - 1
+			for outer >= 0 && closerOf[toks[open[outer]].Type] != t.Type {
+				outer--
+			}
+			if outer < 0 {
+				top := -1
+				if len(open) > 0 {
+					top = open[len(open)-1]
+				}
+				stray(i, top)
+				continue
+			}
+			for _, o := range open[outer+1:] {
+				cut(o, i)
+			}
+			pair(open[outer], i)
+			open = open[:outer]
+		}
+	}
+	for _, o := range open {
+		unclosed(o)
+	}
+}
+
+// CheckBrackets reports the (), {} and [] of toks that are not balanced or
+// not properly nested: a closer without an opener, a closer of the wrong
+// kind, and an opener never closed, paired as walkBrackets does.
+func CheckBrackets(toks []Token) []Diagnostic {
+	var diags []Diagnostic
+	report := func(t Token, format string, args ...interface{}) {
+		diags = append(diags, Diagnostic{
+			Phase: "check", Code: ErrBracket, Line: t.Line, Col: t.Column, Offset: t.Offset, End: t.End, File: t.File,
+			Message: fmt.Sprintf(format, args...),
+		})
+	}
+	walkBrackets(toks,
+		func(open, close int) {},
+		func(open, close int) {
+			o, c := toks[open], toks[close]
+			report(o, "%q is not closed before the %q at %d:%d", o.Lexeme, c.Lexeme, c.Line, c.Column)
+		},
+		func(close, open int) {
+			c := toks[close]
+			if open < 0 {
+				report(c, "unmatched %q", c.Lexeme)
+				return
+			}
+			o := toks[open]
+			report(c, "unexpected %q: %q opened at %d:%d is still open", c.Lexeme, o.Lexeme, o.Line, o.Column)
+		},
+		func(open int) { report(toks[open], "unclosed %q", toks[open].Lexeme) })
+	return diags
+}
+
+// MatchBrackets sets the Match of each bracket of toks that walkBrackets
+// pairs to the index of the other one, so an editor can highlight the
+// matching bracket. Unpaired brackets keep a nil Match.
+func MatchBrackets(toks []Token) {
+	walkBrackets(toks,
+		func(open, close int) {
+			o, c := open, close
+			toks[open].Match, toks[close].Match = &c, &o
+		},
+		func(open, close int) {}, func(close, open int) {}, func(open int) {})
+}
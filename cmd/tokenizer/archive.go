@@ -0,0 +1,93 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+)
+
+// maxArchiveEntry bounds the size of one archive member read into memory,
+// so a zip bomb fails instead of exhausting memory.
+const maxArchiveEntry = 8 << 20
+
+// walkArchive calls fn with the name and contents of every regular file in
+// the zip, tar, tar.gz or tgz archive at file whose name has the extension
+// ext (all files when ext is empty). Nothing is extracted to disk.
+func walkArchive(file, ext string, fn func(name string, data []byte)) error {
+	match := func(name string) bool { return ext == "" || path.Ext(name) == ext }
+	lower := strings.ToLower(file)
+	switch {
+	case strings.HasSuffix(lower, ".zip"):
+		zr, err := zip.OpenReader(file)
+		if err != nil {
+			return fmt.Errorf("open archive error: %w", err)
+		}
+		defer zr.Close()
+		for _, f := range zr.File {
+			if f.FileInfo().IsDir() || !match(f.Name) {
+				continue
+			}
+			rc, err := f.Open()
+			if err != nil {
+				return fmt.Errorf("%s: %s: %w", file, f.Name, err)
+			}
+			data, err := readEntry(rc)
+			rc.Close()
+			if err != nil {
+				return fmt.Errorf("%s: %s: %w", file, f.Name, err)
+			}
+			fn(f.Name, data)
+		}
+		return nil
+	case strings.HasSuffix(lower, ".tar"), strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+		fh, err := os.Open(file)
+		if err != nil {
+			return fmt.Errorf("open archive error: %w", err)
+		}
+		defer fh.Close()
+		var r io.Reader = fh
+		if !strings.HasSuffix(lower, ".tar") {
+			gz, err := gzip.NewReader(fh)
+			if err != nil {
+				return fmt.Errorf("%s: %w", file, err)
+			}
+			defer gz.Close()
+			r = gz
+		}
+		tr := tar.NewReader(r)
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("%s: %w", file, err)
+			}
+			if hdr.Typeflag != tar.TypeReg || !match(hdr.Name) {
+				continue
+			}
+			data, err := readEntry(tr)
+			if err != nil {
+				return fmt.Errorf("%s: %s: %w", file, hdr.Name, err)
+			}
+			fn(hdr.Name, data)
+		}
+	}
+	return fmt.Errorf("%s: unsupported archive type (want .zip, .tar, .tar.gz or .tgz)", file)
+}
+
+func readEntry(r io.Reader) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, maxArchiveEntry+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxArchiveEntry {
+		return nil, fmt.Errorf("entry exceeds the %d byte limit", maxArchiveEntry)
+	}
+	return data, nil
+}
@@ -0,0 +1,19 @@
+package main
+
+import (
+	"strings"
+
+	"tokenizer"
+)
+
+// maskBidi replaces the bidirectional controls of s with U+FFFD, which has
+// the same UTF-8 length, so a line quoted in a diagnostic shows in source
+// order.
+func maskBidi(s string) string {
+	return strings.Map(func(r rune) rune {
+		if tokenizer.IsBidiControl(r) {
+			return '�'
+		}
+		return r
+	}, s)
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"tokenizer"
+)
+
+// runBPE implements `tokenizer bpe -merges table.json [-pieces] [path]`: it
+// prints the subword ids of the input as a JSON array, or with -pieces one
+// id and its Go-quoted bytes per line.
+func runBPE(args []string) int {
+	fs := flag.NewFlagSet("bpe", flag.ExitOnError)
+	mergesPath := fs.String("merges", "", "JSON merge table to encode with (required)")
+	pieces := fs.Bool("pieces", false, "print each id with the text it stands for instead of a JSON array")
+	fs.Parse(args)
+	if *mergesPath == "" || fs.NArg() > 1 {
+		fmt.Fprintln(os.Stderr, "usage: tokenizer bpe -merges table.json [-pieces] [path]")
+		return exitFailure
+	}
+
+	b, err := tokenizer.LoadBPE(*mergesPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitFailure
+	}
+	data, _, err := readSource(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitFailure
+	}
+	ids := b.Encode(string(data))
+
+	if *pieces {
+		var sb strings.Builder
+		for _, id := range ids {
+			sb.WriteString(strconv.Itoa(id) + "\t" + strconv.Quote(string(b.Piece(id))) + "\n")
+		}
+		os.Stdout.WriteString(sb.String())
+		return 0
+	}
+	if ids == nil {
+		ids = []int{}
+	}
+	out, err := json.Marshal(ids)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "marshal json error: %v\n", err)
+		return exitFailure
+	}
+	os.Stdout.Write(append(out, '\n'))
+	return 0
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"tokenizer"
+)
+
+// runCheck implements `tokenizer check [-brackets] [path ...]`: token-level
+// checks reported as diagnostics. With no check named, all of them run. It
+// exits 1 when a check failed.
+func runCheck(args []string) int {
+	fs := flag.NewFlagSet("check", flag.ExitOnError)
+	brackets := fs.Bool("brackets", false, "check that (), {} and [] are balanced and properly nested")
+	style := fs.String("diagnostics", diagPretty, "style of the errors on stderr: pretty, short or json")
+	ext := fs.String("ext", ".jl", "extension of the files read from directories")
+	fs.Parse(args)
+
+	all := !*brackets
+	paths := fs.Args()
+	if len(paths) == 0 {
+		paths = []string{"-"}
+	}
+	paths, err := expandPaths(paths, *ext)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitFailure
+	}
+	status := 0
+	for _, path := range paths {
+		data, name, err := readSource(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitFailure
+		}
+		toks, _ := tokenizer.Lex(string(data))
+		var diags []tokenizer.Diagnostic
+		if all || *brackets {
+			diags = append(diags, tokenizer.CheckBrackets(toks)...)
+		}
+		if len(diags) > 0 {
+			status = exitErrors
+			if err := writeDiagnostics(os.Stderr, name, string(data), diags, *style, useColor(colorModeAuto, os.Stderr)); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				return exitFailure
+			}
+		}
+	}
+	return status
+}
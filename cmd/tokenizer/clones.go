@@ -0,0 +1,218 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"sort"
+
+	"tokenizer"
+)
+
+// CloneRegion is a stretch of tokens of one file.
+type CloneRegion struct {
+	File    string `json:"file"`
+	Line    int    `json:"line"`
+	Col     int    `json:"col"`
+	EndLine int    `json:"endLine"`
+	Offset  int    `json:"offset"`
+	End     int    `json:"end"`
+}
+
+// Clone is a pair of regions with the same token sequence once identifiers
+// and literal values are ignored (a type-2 clone).
+type Clone struct {
+	Tokens int         `json:"tokens"`
+	A      CloneRegion `json:"a"`
+	B      CloneRegion `json:"b"`
+}
+
+// cloneFile is a lexed file with the normalized symbol of each token.
+type cloneFile struct {
+	name string
+	toks []tokenizer.Token
+	syms []uint64
+}
+
+// newCloneFile lexes src. Pragmas are dropped: they do not change the code.
+func newCloneFile(name, src string) cloneFile {
+	toks, _ := tokenizer.NewLexer(src).LexAll()
+	toks = tokenizer.Filter(toks, func(t tokenizer.Token) bool { return t.Type != tokenizer.PRAGMA })
+	f := cloneFile{name: name, toks: toks, syms: make([]uint64, len(toks))}
+	for i, t := range toks {
+		f.syms[i] = cloneSymbol(t)
+	}
+	return f
+}
+
+// cloneSymbol hashes what a clone has to keep of t: its type, so that any
+// name stands for any other and any number for any other number.
+func cloneSymbol(t tokenizer.Token) uint64 {
+	tt := t.Type
+	if t.AsIdent {
+		tt = tokenizer.IDENT
+	}
+	h := fnv.New64a()
+	h.Write([]byte(tt))
+	return h.Sum64()
+}
+
+// cloneBase is the multiplier of the rolling hash.
+const cloneBase = 1099511628211
+
+// maxCloneSites bounds how often a window may repeat to be compared:
+// more often is boilerplate, such as a long list of numbers, whose pairs
+// would grow quadratically.
+const maxCloneSites = 64
+
+// cloneSite is where a window starts: a file and a token index.
+type cloneSite struct{ file, pos int }
+
+// findClones reports the maximal regions of at least min tokens that occur
+// twice, within one file or across files, longest first. Overlapping
+// occurrences in one file are not reported.
+func findClones(files []cloneFile, min int) []Clone {
+	// fingerprint every window of min tokens with a rolling hash
+	var pow uint64 = 1
+	for i := 0; i < min; i++ {
+		pow *= cloneBase
+	}
+	windows := map[uint64][]cloneSite{}
+	for fi, f := range files {
+		var h uint64
+		for i, s := range f.syms {
+			h = h*cloneBase + s
+			if i >= min {
+				h -= pow * f.syms[i-min]
+			}
+			if i >= min-1 {
+				windows[h] = append(windows[h], cloneSite{fi, i - min + 1})
+			}
+		}
+	}
+
+	// pairs of windows with equal tokens; a hash collision is ruled out by
+	// comparing the symbols
+	type pair struct{ a, b cloneSite }
+	pairs := map[pair]bool{}
+	for _, sites := range windows {
+		if len(sites) > maxCloneSites {
+			continue
+		}
+		for i := 0; i < len(sites); i++ {
+			for j := i + 1; j < len(sites); j++ {
+				a, b := sites[i], sites[j]
+				if a.file == b.file && b.pos-a.pos < min {
+					continue
+				}
+				if equalSyms(files[a.file].syms[a.pos:a.pos+min], files[b.file].syms[b.pos:b.pos+min]) {
+					pairs[pair{a, b}] = true
+				}
+			}
+		}
+	}
+
+	// merge runs of pairs shifted by one token into maximal clones
+	var clones []Clone
+	for p := range pairs {
+		if pairs[pair{cloneSite{p.a.file, p.a.pos - 1}, cloneSite{p.b.file, p.b.pos - 1}}] {
+			continue // not the start of a run
+		}
+		n := 1
+		for pairs[pair{cloneSite{p.a.file, p.a.pos + n}, cloneSite{p.b.file, p.b.pos + n}}] {
+			n++
+		}
+		size := n - 1 + min
+		if p.a.file == p.b.file && size > p.b.pos-p.a.pos {
+			size = p.b.pos - p.a.pos // the copies would overlap
+		}
+		clones = append(clones, Clone{
+			Tokens: size,
+			A:      files[p.a.file].region(p.a.pos, size),
+			B:      files[p.b.file].region(p.b.pos, size),
+		})
+	}
+	sort.Slice(clones, func(i, j int) bool {
+		a, b := clones[i], clones[j]
+		if a.Tokens != b.Tokens {
+			return a.Tokens > b.Tokens
+		}
+		if a.A.File != b.A.File {
+			return a.A.File < b.A.File
+		}
+		return a.A.Offset < b.A.Offset || a.A.Offset == b.A.Offset && a.B.Offset < b.B.Offset
+	})
+	return clones
+}
+
+func equalSyms(a, b []uint64) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// region describes the n tokens of f from index pos.
+func (f cloneFile) region(pos, n int) CloneRegion {
+	first, last := f.toks[pos], f.toks[pos+n-1]
+	return CloneRegion{File: f.name, Line: first.Line, Col: first.Column, EndLine: tokenizer.EndLine(last), Offset: first.Offset, End: last.End}
+}
+
+// runClones implements `tokenizer clones [-min N] [-json] [path ...]`. Like
+// grep it exits 0 when clones were found and 1 when none were.
+func runClones(args []string) int {
+	fs := flag.NewFlagSet("clones", flag.ExitOnError)
+	min := fs.Int("min", 30, "smallest clone to report, in tokens")
+	asJSON := fs.Bool("json", false, "print the clones as JSON")
+	ext := fs.String("ext", ".jl", "extension of the files read from directories")
+	fs.Parse(args)
+
+	if *min < 1 {
+		fmt.Fprintln(os.Stderr, "clones: -min must be at least 1")
+		return exitFailure
+	}
+	paths := fs.Args()
+	if len(paths) == 0 {
+		paths = []string{"-"}
+	}
+	paths, err := expandPaths(paths, *ext)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitFailure
+	}
+	var files []cloneFile
+	for _, path := range paths {
+		data, name, err := readSource(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitFailure
+		}
+		files = append(files, newCloneFile(name, string(data)))
+	}
+	clones := findClones(files, *min)
+
+	if *asJSON {
+		if clones == nil {
+			clones = []Clone{}
+		}
+		b, err := json.MarshalIndent(clones, "", "  ")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "marshal json error: %v\n", err)
+			return exitFailure
+		}
+		os.Stdout.Write(append(b, '\n'))
+	} else {
+		for _, c := range clones {
+			fmt.Printf("%d tokens: %s:%d:%d-%d and %s:%d:%d-%d\n", c.Tokens,
+				c.A.File, c.A.Line, c.A.Col, c.A.EndLine, c.B.File, c.B.Line, c.B.Col, c.B.EndLine)
+		}
+	}
+	if len(clones) == 0 {
+		return 1
+	}
+	return 0
+}
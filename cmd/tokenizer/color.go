@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+
+	"tokenizer"
+)
+
+const (
+	ansiReset     = "\x1b[0m"
+	ansiBold      = "\x1b[1m"
+	ansiGreen     = "\x1b[32m"
+	ansiCyan      = "\x1b[36m"
+	ansiGray      = "\x1b[90m"
+	ansiRedUnder  = "\x1b[31;4m"
+	ansiBoldRed   = "\x1b[1;31m"
+	ansiNoStyle   = ""
+	colorModeAuto = "auto"
+)
+
+// colorFlag is the value of --color. Used bare it means "auto"; it also
+// accepts --color=always and --color=never.
+type colorFlag string
+
+func (f *colorFlag) String() string   { return string(*f) }
+func (f *colorFlag) IsBoolFlag() bool { return true }
+func (f *colorFlag) Set(v string) error {
+	switch v {
+	case "true", colorModeAuto:
+		*f = colorModeAuto
+	case "always", "never", "false":
+		if v == "false" {
+			v = "never"
+		}
+		*f = colorFlag(v)
+	default:
+		return fmt.Errorf("invalid color mode %q (want auto, always or never)", v)
+	}
+	return nil
+}
+
+// useColor reports whether ANSI escapes should be written to f for the given
+// mode. In auto mode colors are used only when NO_COLOR is unset and f is a
+// terminal.
+func useColor(mode colorFlag, f *os.File) bool {
+	switch mode {
+	case "always":
+		return true
+	case colorModeAuto:
+		if os.Getenv("NO_COLOR") != "" {
+			return false
+		}
+		fi, err := f.Stat()
+		return err == nil && fi.Mode()&os.ModeCharDevice != 0
+	}
+	return false
+}
+
+// ansiStyle maps a token category to its terminal style.
+func ansiStyle(category string) string {
+	switch category {
+	case "keyword":
+		return ansiBold
+	case "literal":
+		return ansiGreen
+	case "type":
+		return ansiCyan
+	case "comment":
+		return ansiGray
+	}
+	return ansiNoStyle
+}
+
+// writeColored prints src with ANSI styles derived from the token stream.
+// Error spans are drawn red and underlined on top of the token styles and the
+// error messages are listed after the source.
+func writeColored(w io.Writer, src string, toks, comments []tokenizer.Token, diags []tokenizer.Diagnostic, color bool) {
+	if !color {
+		io.WriteString(w, src)
+		for _, d := range diags {
+			fmt.Fprintln(w, d)
+		}
+		return
+	}
+	styles := make([]string, len(src))
+	for _, t := range tokenizer.MergeTrivia(toks, comments) {
+		st := ansiStyle(tokenizer.TokenCategory(t.Type))
+		for i := t.Offset; i < t.End && i < len(src); i++ {
+			styles[i] = st
+		}
+	}
+	for _, d := range diags {
+		for i := d.Offset; i < d.End && i < len(src); i++ {
+			styles[i] = ansiRedUnder
+		}
+	}
+
+	bw := bufio.NewWriter(w)
+	cur := ansiNoStyle
+	run := 0 // start of the source not written yet
+	for i := 0; i < len(src); i++ {
+		st := styles[i]
+		// never carry an underline across a line break
+		if src[i] == '\n' || src[i] == '\r' {
+			st = ansiNoStyle
+		}
+		if st != cur {
+			bw.WriteString(src[run:i])
+			run = i
+			if cur != ansiNoStyle {
+				bw.WriteString(ansiReset)
+			}
+			bw.WriteString(st)
+			cur = st
+		}
+	}
+	bw.WriteString(src[run:])
+	if cur != ansiNoStyle {
+		bw.WriteString(ansiReset)
+	}
+	for _, d := range diags {
+		fmt.Fprintf(bw, "%s%s%s\n", ansiBoldRed, d, ansiReset)
+	}
+	bw.Flush()
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// Comparison is how much of two files is the same code, measured MOSS-style
+// on winnowed fingerprints of their token k-grams (identifiers and literal
+// values normalized, as for clones).
+type Comparison struct {
+	A          string  `json:"a"`
+	B          string  `json:"b"`
+	Shared     int     `json:"shared"`     // fingerprints in both files
+	PercentA   float64 `json:"percentA"`   // of the fingerprints of A found in B
+	PercentB   float64 `json:"percentB"`   // of the fingerprints of B found in A
+	Similarity float64 `json:"similarity"` // the larger of the two
+}
+
+// winnow returns the fingerprints of syms: the hashes of its k-grams, of
+// which the smallest of every w consecutive ones is kept (the rightmost on a
+// tie). Any run of w+k-1 tokens two files share gives them a common
+// fingerprint, while the set stays about 2/(w+1) of the k-grams.
+func winnow(syms []uint64, k, w int) map[uint64]bool {
+	var pow uint64 = 1
+	for i := 0; i < k; i++ {
+		pow *= cloneBase
+	}
+	var grams []uint64
+	var h uint64
+	for i, s := range syms {
+		h = h*cloneBase + s
+		if i >= k {
+			h -= pow * syms[i-k]
+		}
+		if i >= k-1 {
+			grams = append(grams, h)
+		}
+	}
+
+	prints := map[uint64]bool{}
+	if len(grams) > 0 && len(grams) < w {
+		w = len(grams)
+	}
+	picked := -1
+	for start := 0; start+w <= len(grams); start++ {
+		min := start
+		for i := start + 1; i < start+w; i++ {
+			if grams[i] <= grams[min] {
+				min = i
+			}
+		}
+		if min != picked {
+			prints[grams[min]] = true
+			picked = min
+		}
+	}
+	return prints
+}
+
+// compareFingerprints scores the fingerprints of two files.
+func compareFingerprints(a, b string, pa, pb map[uint64]bool) Comparison {
+	c := Comparison{A: a, B: b}
+	for h := range pa {
+		if pb[h] {
+			c.Shared++
+		}
+	}
+	if len(pa) > 0 {
+		c.PercentA = 100 * float64(c.Shared) / float64(len(pa))
+	}
+	if len(pb) > 0 {
+		c.PercentB = 100 * float64(c.Shared) / float64(len(pb))
+	}
+	c.Similarity = c.PercentA
+	if c.PercentB > c.Similarity {
+		c.Similarity = c.PercentB
+	}
+	return c
+}
+
+// runCompare implements `tokenizer compare [-k N] [-w N] [-json] path ...`.
+// Two files are compared with each other; more files, or directories, are
+// compared in all pairs, most similar first.
+func runCompare(args []string) int {
+	fs := flag.NewFlagSet("compare", flag.ExitOnError)
+	k := fs.Int("k", 12, "length of the compared token sequences (k-grams)")
+	w := fs.Int("w", 8, "winnowing window, in k-grams")
+	threshold := fs.Float64("min", 0, "only report pairs at least this similar, in percent")
+	asJSON := fs.Bool("json", false, "print the comparisons as JSON")
+	ext := fs.String("ext", ".jl", "extension of the files read from directories")
+	fs.Parse(args)
+
+	if *k < 1 || *w < 1 {
+		fmt.Fprintln(os.Stderr, "compare: -k and -w must be at least 1")
+		return exitFailure
+	}
+	paths, err := expandPaths(fs.Args(), *ext)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitFailure
+	}
+	if len(paths) < 2 {
+		fmt.Fprintln(os.Stderr, "usage: tokenizer compare [-k N] [-w N] [-min percent] [-json] a.jl b.jl | dir ...")
+		return exitFailure
+	}
+	names := make([]string, len(paths))
+	prints := make([]map[uint64]bool, len(paths))
+	for i, path := range paths {
+		data, name, err := readSource(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitFailure
+		}
+		names[i] = name
+		prints[i] = winnow(newCloneFile(name, string(data)).syms, *k, *w)
+	}
+
+	report := []Comparison{}
+	for i := range paths {
+		for j := i + 1; j < len(paths); j++ {
+			if c := compareFingerprints(names[i], names[j], prints[i], prints[j]); c.Similarity >= *threshold {
+				report = append(report, c)
+			}
+		}
+	}
+	sort.SliceStable(report, func(i, j int) bool { return report[i].Similarity > report[j].Similarity })
+
+	if *asJSON {
+		b, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "marshal json error: %v\n", err)
+			return exitFailure
+		}
+		os.Stdout.Write(append(b, '\n'))
+		return 0
+	}
+	for _, c := range report {
+		fmt.Printf("%5.1f%%  %s (%.1f%%)  %s (%.1f%%)\n", c.Similarity, c.A, c.PercentA, c.B, c.PercentB)
+	}
+	return 0
+}
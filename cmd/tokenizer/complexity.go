@@ -0,0 +1,160 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"tokenizer"
+)
+
+// FuncComplexity is the approximate cyclomatic complexity of one function:
+// 1 plus the number of if, fr, case, && and || in its body. The tokens of
+// a function nested in it count toward the nested one only.
+type FuncComplexity struct {
+	File       string `json:"file"`
+	Name       string `json:"name"` // "" for a function literal
+	Line       int    `json:"line"`
+	Col        int    `json:"col"`
+	EndLine    int    `json:"endLine"`
+	Complexity int    `json:"complexity"`
+
+	def tokenizer.Token // the def keyword
+}
+
+// isDecision reports whether t adds a path through a function.
+func isDecision(tt tokenizer.TokenType) bool {
+	switch tt {
+	case tokenizer.KW_IF, tokenizer.KW_FR, tokenizer.KW_CASE, tokenizer.ANDAND, tokenizer.OROR:
+		return true
+	}
+	return false
+}
+
+// functionComplexity finds each def and its body, the braces after its
+// parameter list, and scores it, in order of the def keywords.
+func functionComplexity(name string, toks []tokenizer.Token) []FuncComplexity {
+	var out []FuncComplexity
+	type open struct {
+		index int // into out
+		depth int // brace depth inside the body
+	}
+	var stack []open
+	pending := -1 // a def whose body has not begun
+	parens := 0
+	for i, t := range toks {
+		switch t.Type {
+		case tokenizer.KW_DEF:
+			out = append(out, FuncComplexity{File: name, Name: declName(toks, i), Line: t.Line, Col: t.Column, Complexity: 1, def: t})
+			pending, parens = len(out)-1, 0
+			continue
+		case tokenizer.LPAREN:
+			parens++
+		case tokenizer.RPAREN:
+			parens--
+		case tokenizer.LBRACE:
+			if pending >= 0 && parens == 0 {
+				stack = append(stack, open{index: pending})
+				pending = -1
+			}
+			if len(stack) > 0 {
+				stack[len(stack)-1].depth++
+			}
+		case tokenizer.RBRACE:
+			pending = -1 // a method of an interface has no body
+			if len(stack) > 0 {
+				top := &stack[len(stack)-1]
+				if top.depth--; top.depth == 0 {
+					out[top.index].EndLine = t.Line
+					stack = stack[:len(stack)-1]
+				}
+			}
+		case tokenizer.SEMI:
+			if pending >= 0 && parens == 0 {
+				pending = -1 // a declaration without a body
+			}
+		}
+		if isDecision(t.Type) && len(stack) > 0 {
+			out[stack[len(stack)-1].index].Complexity++
+		}
+	}
+	// a def whose body never began or ended is scored as far as it got
+	for i := range out {
+		if out[i].EndLine == 0 {
+			out[i].EndLine = out[i].Line
+		}
+	}
+	return out
+}
+
+// runComplexity implements `tokenizer complexity [-max N] [-json] [path ...]`.
+// Functions above -max are also reported as diagnostics, and make it exit 1.
+func runComplexity(args []string) int {
+	fs := flag.NewFlagSet("complexity", flag.ExitOnError)
+	limit := fs.Int("max", 10, "report functions more complex than this as errors (0 disables)")
+	asJSON := fs.Bool("json", false, "print the report as JSON")
+	style := fs.String("diagnostics", diagPretty, "style of the errors on stderr: pretty, short or json")
+	ext := fs.String("ext", ".jl", "extension of the files read from directories")
+	fs.Parse(args)
+
+	paths := fs.Args()
+	if len(paths) == 0 {
+		paths = []string{"-"}
+	}
+	paths, err := expandPaths(paths, *ext)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitFailure
+	}
+	report := []FuncComplexity{}
+	status := 0
+	for _, path := range paths {
+		data, name, err := readSource(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitFailure
+		}
+		toks, _ := tokenizer.NewLexer(string(data)).LexAll()
+		funcs := functionComplexity(name, toks)
+		var diags []tokenizer.Diagnostic
+		for _, f := range funcs {
+			if *limit > 0 && f.Complexity > *limit {
+				what := "function literal"
+				if f.Name != "" {
+					what = "function " + f.Name
+				}
+				diags = append(diags, tokenizer.Diagnostic{
+					Phase: "complexity", Code: tokenizer.ErrComplexity, Line: f.Line, Col: f.Col, Offset: f.def.Offset, End: f.def.End,
+					Message: fmt.Sprintf("%s has complexity %d (more than %d)", what, f.Complexity, *limit),
+				})
+			}
+		}
+		if len(diags) > 0 {
+			status = exitErrors
+			if err := writeDiagnostics(os.Stderr, name, string(data), diags, *style, useColor(colorModeAuto, os.Stderr)); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				return exitFailure
+			}
+		}
+		report = append(report, funcs...)
+	}
+
+	if *asJSON {
+		b, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "marshal json error: %v\n", err)
+			return exitFailure
+		}
+		os.Stdout.Write(append(b, '\n'))
+		return status
+	}
+	for _, f := range report {
+		name := f.Name
+		if name == "" {
+			name = "(literal)"
+		}
+		fmt.Printf("%s:%d:%d: %s %d\n", f.File, f.Line, f.Col, name, f.Complexity)
+	}
+	return status
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"tokenizer"
+)
+
+// isFileMarker reports whether t is a FILE_BEGIN or FILE_END token, which
+// --only and --exclude never drop.
+func isFileMarker(t tokenizer.Token) bool {
+	return t.Type == tokenizer.FILE_BEGIN || t.Type == tokenizer.FILE_END
+}
+
+// runConcat prints the inputs gathered by --concat as one report.
+func runConcat(o *cliOptions) int {
+	var (
+		timings = make([]Timing, len(o.sources))
+		lexed   = make([][]tokenizer.Token, len(o.sources)) // the tokens of each input
+		next    int
+	)
+	toks, errs, lexers := tokenizer.LexSourcesFunc(o.sources, func(src tokenizer.Source) *tokenizer.Lexer {
+		if o.progress != nil {
+			o.progress.begin(src.Name)
+		}
+		return o.newLexer(src.Text)
+	}, func(lx *tokenizer.Lexer) ([]tokenizer.Token, []string) {
+		// time the lexing alone, not the copying into the stream
+		src := o.sources[next]
+		timer := startTimer(o.timings != nil)
+		toks, errs := lx.LexAll()
+		timings[next], lexed[next] = timer.stop(src.Name, len(src.Text), len(toks)), toks
+		next++
+		return toks, errs
+	})
+	out := tokenizer.TokenDocument{Tokens: toks, Errors: errs}
+	omitted := 0
+	color := o.color != "never" && useColor(colorModeAuto, os.Stderr)
+	for i, src := range o.sources {
+		lx := lexers[i]
+		out.Files = append(out.Files, src.Name)
+		if o.verify {
+			if err := tokenizer.VerifyRoundTrip(src.Text, lexed[i], lx.Comments(), lx.Diagnostics(), lx.Continuations()); err != nil {
+				fmt.Fprintf(os.Stderr, "verify failed: %s: %v\n", src.Name, err)
+				return exitFailure
+			}
+		}
+		if o.timings != nil {
+			o.timings.add(timings[i])
+		}
+		logInput(o.log, src.Name, len(src.Text), len(lexed[i]), len(lx.Diagnostics()), timings[i].Elapsed)
+		logRecoveries(o.log, src.Name, lx.Diagnostics())
+		omitted += lx.OmittedErrors()
+		if o.format != "gcc" {
+			writeDiagnostics(os.Stderr, src.Name, src.Text, lx.Diagnostics(), o.diagStyle, color)
+		}
+	}
+	if omitted > 0 && o.diagStyle != diagJSON {
+		fmt.Fprintf(os.Stderr, "%d more errors not shown (--max-errors %d)\n", omitted, o.maxErrors)
+	}
+
+	if keep := typeFilter(o.only, o.exclude); keep != nil {
+		if wantsTrivia(o.only) {
+			// merge each file's comments between its markers
+			var merged []tokenizer.Token
+			for i, lx := range lexers {
+				n := len(lexed[i])
+				merged = append(merged, toks[0])
+				merged = append(merged, tokenizer.MergeTrivia(toks[1:n+1], lx.Comments())...)
+				merged = append(merged, toks[n+1])
+				toks = toks[n+2:]
+			}
+			toks = merged
+		}
+		out.Tokens = tokenizer.Filter(toks, func(t tokenizer.Token) bool { return isFileMarker(t) || keep(t) })
+	}
+
+	var stdout io.Writer = os.Stdout
+	if o.quiet || o.outPath == "-" {
+		stdout = io.Discard
+	}
+	switch o.format {
+	case "json":
+		if err := writeDocumentLine(stdout, &out, o.compact); err != nil {
+			fmt.Fprintf(os.Stderr, "write json error: %v\n", err)
+			return exitFailure
+		}
+	case "table":
+		writeTable(stdout, out.Tokens, errs)
+	case "gcc":
+		for i, src := range o.sources {
+			writeGCC(stdout, src.Name, lexers[i].Diagnostics())
+		}
+	}
+	if st := writeOutputFile(&out, "", o); st != exitClean {
+		return st
+	}
+	if len(errs) > 0 || omitted > 0 {
+		return exitErrors
+	}
+	return exitClean
+}
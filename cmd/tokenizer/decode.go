@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"tokenizer"
+)
+
+// layoutLines gives tokens that lost their positions, such as those of
+// TokensFromIDs, line numbers a formatter can lay out: a statement per line,
+// guessed from ';', block braces and an operand followed by the start of
+// another one.
+func layoutLines(toks []tokenizer.Token) {
+	line := 1
+	header := false
+	var literal []bool // per open '{', as in formatSource
+	for i := range toks {
+		t := &toks[i]
+		if i > 0 {
+			prev := toks[i-1]
+			inLiteral := len(literal) > 0 && literal[len(literal)-1]
+			switch {
+			case prev.Type == tokenizer.SEMI,
+				prev.Type == tokenizer.LBRACE && !inLiteral && t.Type != tokenizer.RBRACE,
+				t.Type == tokenizer.RBRACE && !inLiteral && prev.Type != tokenizer.LBRACE,
+				prev.Type == tokenizer.RBRACE && t.Type != tokenizer.KW_ELSE && t.Type != tokenizer.RPAREN && t.Type != tokenizer.COMMA && t.Type != tokenizer.SEMI && t.Type != tokenizer.RBRACE,
+				endsOperand(prev) && startsStatement(t.Type):
+				line++
+			}
+		}
+		t.Line = line
+		switch t.Type {
+		case tokenizer.LBRACE:
+			lit := !header && i > 0 && (toks[i-1].Type == tokenizer.IDENT || toks[i-1].Type == tokenizer.TYPE_NAME || toks[i-1].Type == tokenizer.RBRACK)
+			literal = append(literal, lit)
+			header = false
+		case tokenizer.RBRACE:
+			if len(literal) > 0 {
+				literal = literal[:len(literal)-1]
+			}
+		case tokenizer.SEMI:
+			header = false
+		}
+		if opensHeader(t.Type) {
+			header = true
+		}
+	}
+}
+
+// startsStatement reports whether a token of type tt after an operand can
+// only begin something new: a name, a literal or a statement keyword.
+func startsStatement(tt tokenizer.TokenType) bool {
+	switch tt {
+	case tokenizer.IDENT, tokenizer.INT_LIT, tokenizer.FLOAT_LIT, tokenizer.STRING_LIT, tokenizer.CHAR_LIT, tokenizer.KW_VAR, tokenizer.KW_CONS, tokenizer.KW_TYPE, tokenizer.KW_DEF,
+		tokenizer.KW_RET, tokenizer.KW_IF, tokenizer.KW_FR, tokenizer.KW_SWITCH, tokenizer.KW_SELECT, tokenizer.KW_LATER, tokenizer.KW_J, tokenizer.KW_BREAK, tokenizer.KW_CONTINUE,
+		tokenizer.KW_JOTO, tokenizer.KW_PANIC, tokenizer.KW_CASE, tokenizer.KW_DFT, tokenizer.KW_FALL, tokenizer.KW_IMP, tokenizer.KW_PKG:
+		return true
+	}
+	return false
+}
+
+// decodeBPE returns the text of BPE ids, with a placeholder such as <?9000>
+// for an id the encoding lacks.
+func decodeBPE(b *tokenizer.BPE, ids []int) string {
+	var sb strings.Builder
+	for _, id := range ids {
+		if p := b.Piece(id); p != nil {
+			sb.Write(p)
+		} else {
+			fmt.Fprintf(&sb, "<?%d>", id)
+		}
+	}
+	return sb.String()
+}
+
+// runDecode implements `tokenizer decode [-vocab file] [ids.json]`: it turns
+// JSON arrays of ids back into readable text. Without a vocabulary or with
+// a lexical one they are the ids of --format ids, and the tokens are laid
+// out like `tokenizer fmt` would; with a BPE vocabulary they are subword ids
+// and decode to the exact text.
+func runDecode(args []string) int {
+	fs := flag.NewFlagSet("decode", flag.ExitOnError)
+	vocabPath := fs.String("vocab", "", "vocabulary the ids were made with (see `tokenizer train-vocab`)")
+	fs.Parse(args)
+	if fs.NArg() > 1 {
+		fmt.Fprintln(os.Stderr, "usage: tokenizer decode [-vocab vocab.json] [ids.json]")
+		return exitFailure
+	}
+
+	var v *tokenizer.Vocab
+	if *vocabPath != "" {
+		var err error
+		if v, err = tokenizer.LoadVocab(*vocabPath); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitFailure
+		}
+	}
+	data, name, err := readSource(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitFailure
+	}
+	// one array per input, as --format ids prints them
+	dec := json.NewDecoder(strings.NewReader(string(data)))
+	for {
+		var ids []int
+		if err := dec.Decode(&ids); errors.Is(err, io.EOF) {
+			return 0
+		} else if err != nil {
+			fmt.Fprintf(os.Stderr, "decode: %s: %v\n", name, err)
+			return exitFailure
+		}
+		if v != nil && v.BPE() != nil {
+			os.Stdout.WriteString(decodeBPE(v.BPE(), ids))
+			continue
+		}
+		toks := tokenizer.TokensFromIDs(ids, v)
+		layoutLines(toks)
+		os.Stdout.WriteString(formatSource(toks, nil))
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"tokenizer"
+)
+
+// Diagnostic output styles for --diagnostics.
+const (
+	diagPretty = "pretty"
+	diagShort  = "short"
+	diagJSON   = "json"
+)
+
+// writeDiagnostics prints diags for the file name in the given style:
+// pretty shows the offending source line with the bad span underlined,
+// short prints one name:line:col line per error and json one JSON object per
+// line. Carets are placed from the byte offsets, so they line up whatever
+// unit the columns are counted in.
+func writeDiagnostics(w io.Writer, name, src string, diags []tokenizer.Diagnostic, style string, color bool) error {
+	switch style {
+	case diagShort:
+		for _, d := range diags {
+			fmt.Fprintf(w, "%s:%d:%d: %s error[%s]: %s\n", name, d.Line, d.Col, d.Phase, d.Code, d.Message)
+			writeNotes(w, d)
+		}
+	case diagJSON:
+		enc := json.NewEncoder(w)
+		for _, d := range diags {
+			if err := enc.Encode(struct {
+				File string `json:"file"`
+				tokenizer.Diagnostic
+			}{name, d}); err != nil {
+				return err
+			}
+		}
+	case diagPretty:
+		for _, d := range diags {
+			writePrettyDiagnostic(w, name, src, d, color)
+		}
+	default:
+		return fmt.Errorf("unknown diagnostics style %q (want pretty, short or json)", style)
+	}
+	return nil
+}
+
+// writeFileDiagnostics is writeDiagnostics for diagnostics from several
+// files: each is printed for files[d.File].
+func writeFileDiagnostics(w io.Writer, files []tokenizer.Source, diags []tokenizer.Diagnostic, style string, color bool) error {
+	for _, d := range diags {
+		f := files[d.File]
+		if err := writeDiagnostics(w, f.Name, f.Text, []tokenizer.Diagnostic{d}, style, color); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeGCC prints diags in the file:line:col: error: message form that
+// editors' quickfix lists and CI annotators understand.
+func writeGCC(w io.Writer, name string, diags []tokenizer.Diagnostic) {
+	for _, d := range diags {
+		fmt.Fprintf(w, "%s:%d:%d: error: %s\n", name, d.Line, d.Col, d.Message)
+		writeNotes(w, d)
+	}
+}
+
+// writeNotes prints the notes of d in the file:line:col: note: form.
+func writeNotes(w io.Writer, d tokenizer.Diagnostic) {
+	for _, n := range d.Notes {
+		fmt.Fprintf(w, "%s:%d:%d: note: %s\n", n.File, n.Line, n.Col, n.Message)
+	}
+}
+
+// writeFileGCC is writeGCC for diagnostics from several files.
+func writeFileGCC(w io.Writer, files []tokenizer.Source, diags []tokenizer.Diagnostic) {
+	for _, d := range diags {
+		writeGCC(w, files[d.File].Name, []tokenizer.Diagnostic{d})
+	}
+}
+
+// writePrettyDiagnostic prints d rustc-style:
+//
+//	lexical error[E0004]: invalid hex literal
+//	  --> main.jl:5:14
+//	   |
+//	 5 |     x := 0xZZ
+//	   |          ^^^^
+func writePrettyDiagnostic(w io.Writer, name, src string, d tokenizer.Diagnostic, color bool) {
+	bold, red, blue, reset := "", "", "", ""
+	if color {
+		bold, red, blue, reset = ansiBold, ansiBoldRed, "\x1b[1;34m", ansiReset
+	}
+	fmt.Fprintf(w, "%s%s error[%s]%s%s: %s%s\n", red, d.Phase, d.Code, reset, bold, d.Message, reset)
+
+	off := d.Offset
+	if off > len(src) {
+		off = len(src)
+	}
+	lineStart := tokenizer.LineStartOf(src, off)
+	line := maskBidi(src[lineStart:tokenizer.LineEndOf(src, off)])
+	if off > lineStart+len(line) {
+		off = lineStart + len(line)
+	}
+	num := strconv.Itoa(d.Line)
+	pad := strings.Repeat(" ", len(num))
+	fmt.Fprintf(w, "%s%s-->%s %s:%d:%d\n", pad, blue, reset, name, d.Line, d.Col)
+	fmt.Fprintf(w, "%s %s|%s\n", pad, blue, reset)
+	fmt.Fprintf(w, "%s%s |%s %s\n", blue, num, reset, line)
+
+	// the underline keeps the line's tabs so it stays aligned in any
+	// terminal, and stops at the end of the first line of a multi-line span
+	var under strings.Builder
+	for _, r := range line[:off-lineStart] {
+		if r == '\t' {
+			under.WriteByte('\t')
+		} else {
+			under.WriteByte(' ')
+		}
+	}
+	end := d.End
+	if end > lineStart+len(line) {
+		end = lineStart + len(line)
+	}
+	n := 1
+	if end > off {
+		n = utf8.RuneCountInString(src[off:end])
+	}
+	fmt.Fprintf(w, "%s %s|%s %s%s%s%s\n", pad, blue, reset, under.String(), red, strings.Repeat("^", n), reset)
+	for _, note := range d.Notes {
+		fmt.Fprintf(w, "%s %s=%s %snote%s: %s at %s:%d:%d\n", pad, blue, reset, bold, reset, note.Message, note.File, note.Line, note.Col)
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"tokenizer"
+)
+
+// declName returns the name declared by the keyword toks[i], or "" if
+// none follows.
+func declName(toks []tokenizer.Token, i int) string {
+	if i+1 < len(toks) && (toks[i+1].Type == tokenizer.IDENT || toks[i+1].AsIdent) {
+		return tokenizer.NameOf(toks[i+1])
+	}
+	return ""
+}
+
+// runDocs implements `tokenizer docs [path ...]`: it prints a JSON object
+// mapping each documented declaration's name to its doc text.
+func runDocs(args []string) int {
+	fs := flag.NewFlagSet("docs", flag.ExitOnError)
+	ext := fs.String("ext", ".jl", "extension of the files read from directories")
+	fs.Parse(args)
+
+	paths := fs.Args()
+	if len(paths) == 0 {
+		paths = []string{"-"}
+	}
+	paths, err := expandPaths(paths, *ext)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitFailure
+	}
+	docs := map[string]string{}
+	where := map[string]string{}
+	for _, path := range paths {
+		data, name, err := readSource(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitFailure
+		}
+		toks, _ := tokenizer.NewLexer(string(data)).LexAll()
+		for i, t := range toks {
+			decl := declName(toks, i)
+			if t.Doc == nil || decl == "" {
+				continue
+			}
+			at := fmt.Sprintf("%s:%d", name, t.Line)
+			if prev, dup := where[decl]; dup {
+				fmt.Fprintf(os.Stderr, "docs: %s is documented at %s and %s; keeping the first\n", decl, prev, at)
+				continue
+			}
+			docs[decl], where[decl] = *t.Doc, at
+		}
+	}
+	b, err := json.MarshalIndent(docs, "", "  ")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "marshal json error: %v\n", err)
+		return exitFailure
+	}
+	os.Stdout.Write(append(b, '\n'))
+	return 0
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"tokenizer"
+)
+
+// typeFilter builds the predicate for --only and --exclude, which hold
+// comma-separated token type names. It returns nil when both are empty.
+func typeFilter(only, exclude []string) func(tokenizer.Token) bool {
+	if len(only) == 0 && len(exclude) == 0 {
+		return nil
+	}
+	toTypes := func(names []string) []tokenizer.TokenType {
+		types := make([]tokenizer.TokenType, len(names))
+		for i, n := range names {
+			types[i] = tokenizer.TokenType(n)
+		}
+		return types
+	}
+	in, out := tokenizer.OfType(toTypes(only)...), tokenizer.OfType(toTypes(exclude)...)
+	return func(t tokenizer.Token) bool {
+		return (len(only) == 0 || in(t)) && !out(t)
+	}
+}
+
+// wantsTrivia reports whether --only asks for comments, doc comments or a
+// shebang, which are then merged into the emitted stream.
+func wantsTrivia(only []string) bool {
+	for _, n := range only {
+		if tokenizer.TokenType(n) == tokenizer.COMMENT || tokenizer.TokenType(n) == tokenizer.DOC_COMMENT || tokenizer.TokenType(n) == tokenizer.SHEBANG {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"tokenizer"
+)
+
+// endsOperand reports whether t can end an operand, in which case a
+// following + - * ** & ^ <- is a binary operator rather than a unary one.
+func endsOperand(t tokenizer.Token) bool {
+	switch t.Type {
+	case tokenizer.IDENT, tokenizer.TYPE_NAME, tokenizer.INT_LIT, tokenizer.FLOAT_LIT, tokenizer.STRING_LIT, tokenizer.CHAR_LIT, tokenizer.STRING_SEGMENT,
+		tokenizer.RPAREN, tokenizer.RBRACK, tokenizer.RBRACE, tokenizer.KW_RECOVER:
+		return true
+	}
+	return false
+}
+
+// opensHeader reports whether tt starts a construct whose '{' belongs on the
+// same line.
+func opensHeader(tt tokenizer.TokenType) bool {
+	switch tt {
+	case tokenizer.KW_DEF, tokenizer.KW_IF, tokenizer.KW_ELSE, tokenizer.KW_FR, tokenizer.KW_SWITCH, tokenizer.KW_SELECT, tokenizer.KW_STRUCT, tokenizer.KW_INTERFACE:
+		return true
+	}
+	return false
+}
+
+// isComment reports whether t is written like a comment: a comment, a doc
+// comment or a pragma.
+func isComment(t tokenizer.Token) bool {
+	return t.Type == tokenizer.COMMENT || t.Type == tokenizer.DOC_COMMENT || t.Type == tokenizer.PRAGMA
+}
+
+// needsSpace decides whether a space separates prev and cur on one line.
+// unary reports whether prev was written as a unary operator.
+func needsSpace(prev, cur tokenizer.Token, unary bool) bool {
+	if isComment(prev) || isComment(cur) {
+		return true
+	}
+	if (prev.Type == tokenizer.PLUS || prev.Type == tokenizer.MINUS) && cur.Lexeme[0] == prev.Lexeme[0] {
+		return true // - -x, not --x
+	}
+	if unary {
+		return false
+	}
+	if cur.Type == tokenizer.INC || cur.Type == tokenizer.DEC {
+		return false
+	}
+	// the pieces of an interpolated string are written exactly as lexed
+	switch {
+	case prev.Type == tokenizer.STRING_SEGMENT && cur.Type == tokenizer.INTERP_START,
+		prev.Type == tokenizer.INTERP_START, cur.Type == tokenizer.INTERP_END,
+		prev.Type == tokenizer.INTERP_END && cur.Type == tokenizer.STRING_SEGMENT:
+		return false
+	}
+	switch prev.Type {
+	case tokenizer.LPAREN, tokenizer.LBRACK, tokenizer.DOT, tokenizer.ELLIPSIS, tokenizer.RANGE_OP:
+		return false
+	case tokenizer.LBRACE:
+		return cur.Type != tokenizer.RBRACE
+	case tokenizer.RBRACK:
+		switch cur.Type {
+		case tokenizer.IDENT, tokenizer.TYPE_NAME, tokenizer.LBRACK, tokenizer.KW_MAPPING, tokenizer.KW_CHANNEL, tokenizer.KW_STRUCT, tokenizer.KW_INTERFACE:
+			return false // []T, [N]T, mapping[K]V
+		}
+	}
+	switch cur.Type {
+	case tokenizer.RPAREN, tokenizer.RBRACK, tokenizer.COMMA, tokenizer.SEMI, tokenizer.COLON, tokenizer.DOT, tokenizer.RANGE_OP:
+		return false
+	case tokenizer.ELLIPSIS:
+		return prev.Type == tokenizer.COLON || prev.Type == tokenizer.COMMA || prev.Type == tokenizer.LPAREN // f(xs...), a: ...T
+	case tokenizer.LPAREN:
+		return !endsOperand(prev) && prev.Type != tokenizer.ANNOTATION
+	case tokenizer.LBRACK:
+		return !endsOperand(prev) && prev.Type != tokenizer.KW_MAPPING
+	}
+	return true
+}
+
+// formatSource rebuilds a source file from its tokens and comments: one space around
+// binary operators, none inside brackets, tab indentation by nesting depth,
+// '{' and 'else' joined to the preceding line, block bodies and their '}' on
+// lines of their own, and at most one blank line between lines. Line breaks
+// otherwise follow the input.
+func formatSource(toks, comments []tokenizer.Token) string {
+	var b strings.Builder
+	depth := 0
+	header := false
+	var literal []bool // per open '{': true for composite literals like []i32{1, 2}
+	var prev *tokenizer.Token
+	prevUnary := false
+	prevClosed := false // prev is a '}' ending a block rather than a literal
+	ternary := 0        // '?' still waiting for their ':'; that ':' is spaced like an operator
+	for _, t := range tokenizer.MergeTrivia(toks, comments) {
+		t := t
+		newline := prev != nil && t.Line > tokenizer.EndLine(*prev)
+		inBlock := len(literal) > 0 && !literal[len(literal)-1]
+		trailing := isComment(t) && prev != nil && t.Line == tokenizer.EndLine(*prev)
+		closes := t.Type == tokenizer.RBRACE && inBlock
+		// a block's body and its closing '}' go on lines of their own:
+		// `{ ret x }` and `} }` are broken up, `{}` and literals are not
+		switch {
+		case trailing:
+		case prev != nil && prev.Type == tokenizer.LBRACE && inBlock && t.Type != tokenizer.RBRACE,
+			closes && prev.Type != tokenizer.LBRACE:
+			newline = true
+		case prevClosed:
+			switch t.Type {
+			case tokenizer.KW_ELSE, tokenizer.RPAREN, tokenizer.RBRACK, tokenizer.COMMA, tokenizer.SEMI, tokenizer.LPAREN, tokenizer.LBRACE, tokenizer.DOT:
+			default:
+				newline = true
+			}
+		}
+		tight := false // no space even though needsSpace would add one
+		if t.Type == tokenizer.LBRACE {
+			lit := !header && prev != nil && (prev.Type == tokenizer.IDENT || prev.Type == tokenizer.TYPE_NAME || prev.Type == tokenizer.RBRACK)
+			literal = append(literal, lit)
+			tight = lit
+		} else if prev != nil && prev.Type == tokenizer.LBRACE || t.Type == tokenizer.RBRACE {
+			tight = len(literal) > 0 && literal[len(literal)-1]
+		}
+		if t.Type == tokenizer.RBRACE && len(literal) > 0 {
+			literal = literal[:len(literal)-1]
+		}
+		if newline && !isComment(*prev) {
+			if t.Type == tokenizer.LBRACE && header || t.Type == tokenizer.KW_ELSE && prev.Type == tokenizer.RBRACE {
+				newline = false
+			}
+		}
+		switch {
+		case prev == nil:
+		case newline:
+			b.WriteByte('\n')
+			if t.Line-tokenizer.EndLine(*prev) > 1 {
+				b.WriteByte('\n')
+			}
+			indent := depth
+			switch t.Type {
+			case tokenizer.RBRACE, tokenizer.RPAREN, tokenizer.RBRACK, tokenizer.KW_CASE, tokenizer.KW_DFT:
+				indent--
+			case tokenizer.DIRECTIVE:
+				indent = 0
+			}
+			if indent > 0 {
+				b.WriteString(strings.Repeat("\t", indent))
+			}
+		case !tight && (needsSpace(*prev, t, prevUnary) || t.Type == tokenizer.COLON && ternary > 0):
+			b.WriteByte(' ')
+		}
+		if newline || t.Type == tokenizer.SEMI || t.Type == tokenizer.LBRACE {
+			ternary = 0
+		}
+		if t.Type == tokenizer.QUESTION {
+			ternary++
+		} else if t.Type == tokenizer.COLON && ternary > 0 {
+			ternary--
+		}
+		if isComment(t) || t.Type == tokenizer.DIRECTIVE {
+			b.WriteString(strings.TrimRight(t.Lexeme, " \t\r"))
+		} else {
+			b.WriteString(t.Lexeme)
+		}
+
+		switch t.Type {
+		case tokenizer.LBRACE, tokenizer.LPAREN, tokenizer.LBRACK:
+			depth++
+		case tokenizer.RBRACE, tokenizer.RPAREN, tokenizer.RBRACK:
+			if depth > 0 {
+				depth--
+			}
+		}
+		if opensHeader(t.Type) {
+			header = true
+		} else if t.Type == tokenizer.LBRACE || t.Type == tokenizer.SEMI {
+			header = false
+		}
+		switch t.Type {
+		case tokenizer.PLUS, tokenizer.MINUS, tokenizer.STAR, tokenizer.POW, tokenizer.BAND, tokenizer.BXOR, tokenizer.BANG, tokenizer.CH_SEND:
+			prevUnary = t.Type == tokenizer.BANG || prev == nil || !endsOperand(*prev)
+		default:
+			prevUnary = false
+		}
+		prevClosed = closes
+		prev = &t
+	}
+	if prev != nil {
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// runFmt implements `tokenizer fmt [-w | -d] [file ...]`.
+func runFmt(args []string) int {
+	fs := flag.NewFlagSet("fmt", flag.ExitOnError)
+	write := fs.Bool("w", false, "write the result back to the source file")
+	diff := fs.Bool("d", false, "print a diff instead of the formatted source")
+	fs.Parse(args)
+
+	paths := fs.Args()
+	if len(paths) == 0 {
+		paths = []string{"-"}
+	}
+	status := 0
+	for _, path := range paths {
+		data, name, err := readSource(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			status = exitFailure
+			continue
+		}
+		lx := tokenizer.NewLexer(string(data))
+		toks, errs := lx.LexAll()
+		if len(errs) > 0 {
+			for _, e := range errs {
+				fmt.Fprintf(os.Stderr, "%s: %s\n", name, e)
+			}
+			status = max(status, exitErrors)
+			continue
+		}
+		out := formatSource(toks, lx.Comments())
+		switch {
+		case *diff:
+			writeUnifiedDiff(os.Stdout, name+".orig", name, splitLines(string(data)), splitLines(out))
+		case *write && name != "-":
+			if out == string(data) {
+				continue
+			}
+			// keep the file's permissions; WriteFile only applies its mode
+			// to new files
+			fi, err := os.Stat(name)
+			if err == nil {
+				err = os.WriteFile(name, []byte(out), fi.Mode().Perm())
+			}
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "write file error: %v\n", err)
+				status = exitFailure
+			}
+		default:
+			os.Stdout.WriteString(out)
+		}
+	}
+	return status
+}
+
+// splitLines splits s into lines without their terminators.
+func splitLines(s string) []string {
+	s = strings.TrimSuffix(s, "\n")
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, "\n")
+}
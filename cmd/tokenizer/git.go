@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// git runs a git command in the current directory and returns its stdout.
+func git(args ...string) ([]byte, error) {
+	var stderr bytes.Buffer
+	cmd := exec.Command("git", args...)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("git %s: %s", args[0], msg)
+		}
+		return nil, fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return out, nil
+}
+
+// gitChangedFiles lists the added, copied, modified or renamed files with
+// extension ext (all when empty): those staged in the index when ref is
+// empty, otherwise those that differ between ref and the working tree.
+// Paths are relative to the repository root.
+func gitChangedFiles(ref, ext string) ([]string, error) {
+	args := []string{"diff", "--name-only", "-z", "--diff-filter=ACMR"}
+	switch {
+	case ref == "":
+		args = append(args, "--cached")
+	case strings.HasPrefix(ref, "-"):
+		// git would take it as an option, such as --output=file
+		return nil, fmt.Errorf("invalid git ref %q", ref)
+	default:
+		args = append(args, ref, "--")
+	}
+	out, err := git(args...)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, name := range strings.Split(string(out), "\x00") {
+		if name != "" && (ext == "" || path.Ext(name) == ext) {
+			files = append(files, name)
+		}
+	}
+	return files, nil
+}
+
+// gitRoot returns the top directory of the current repository.
+func gitRoot() (string, error) {
+	out, err := git("rev-parse", "--show-toplevel")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// runGitChanged tokenizes the files reported by gitChangedFiles. Staged
+// files are read from the index, so a pre-commit hook checks exactly what is
+// about to be committed; with a ref they are read from the working tree.
+func runGitChanged(ref, ext string, o *cliOptions) int {
+	// ask for the root first: outside a repository git diff would fall back
+	// to --no-index and print its usage
+	root, err := gitRoot()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitFailure
+	}
+	files, err := gitChangedFiles(ref, ext)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitFailure
+	}
+	wd, _ := os.Getwd()
+	status := exitClean
+	for _, name := range files {
+		// report paths relative to the current directory, like git status
+		file := filepath.Join(root, filepath.FromSlash(name))
+		if rel, err := filepath.Rel(wd, file); err == nil && wd != "" {
+			file = rel
+		}
+		st := exitFailure
+		if ref == "" {
+			if data, err := git("show", ":"+name); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+			} else {
+				st = runSource(string(data), file, o)
+			}
+		} else {
+			st = runFile(file, o)
+		}
+		if st > status {
+			status = st
+		}
+	}
+	return status
+}
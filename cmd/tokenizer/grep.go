@@ -0,0 +1,108 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"tokenizer"
+)
+
+// expandPaths replaces every directory in paths by the files below it with
+// extension ext, in lexical order. Other paths are kept as given.
+func expandPaths(paths []string, ext string) ([]string, error) {
+	var out []string
+	for _, p := range paths {
+		if fi, err := os.Stat(p); err != nil || !fi.IsDir() {
+			out = append(out, p)
+			continue
+		}
+		err := filepath.WalkDir(p, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.IsDir() && filepath.Ext(path) == ext {
+				out = append(out, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// grepTokens writes the tokens of one file accepted by match as
+// name:line:col: TYPE lexeme lines and returns how many there were.
+func grepTokens(w io.Writer, name string, toks []tokenizer.Token, match func(tokenizer.Token) bool) int {
+	n := 0
+	for _, t := range toks {
+		if match(t) {
+			fmt.Fprintf(w, "%s:%d:%d: %s %s\n", name, t.Line, t.Column, t.Type, escapeLexeme(t.Lexeme))
+			n++
+		}
+	}
+	return n
+}
+
+// runGrep implements `tokenizer grep [--type T,...] [--lexeme s | --regexp re] [path ...]`.
+// Like grep it exits 0 when something matched, 1 when nothing did and 2 on
+// errors.
+func runGrep(args []string) int {
+	fs := flag.NewFlagSet("grep", flag.ExitOnError)
+	types := fs.String("type", "", "comma-separated token types to match, e.g. IDENT (COMMENT searches comments)")
+	lexeme := fs.String("lexeme", "", "match tokens whose lexeme is exactly this")
+	pattern := fs.String("regexp", "", "match tokens whose lexeme matches this regular expression")
+	ext := fs.String("ext", ".jl", "extension of the files searched in directories")
+	fs.Parse(args)
+
+	var re *regexp.Regexp
+	if *pattern != "" {
+		var err error
+		if re, err = regexp.Compile(*pattern); err != nil {
+			fmt.Fprintf(os.Stderr, "grep: %v\n", err)
+			return exitFailure
+		}
+	}
+	only := splitList(*types)
+	ofType := typeFilter(only, nil)
+	match := func(t tokenizer.Token) bool {
+		return (ofType == nil || ofType(t)) &&
+			(*lexeme == "" || t.Lexeme == *lexeme) &&
+			(re == nil || re.MatchString(t.Lexeme))
+	}
+
+	paths := fs.Args()
+	if len(paths) == 0 {
+		paths = []string{"-"}
+	}
+	paths, err := expandPaths(paths, *ext)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitFailure
+	}
+	status, found := 0, 0
+	for _, path := range paths {
+		data, name, err := readSource(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			status = exitFailure
+			continue
+		}
+		lx := tokenizer.NewLexer(string(data))
+		toks, _ := lx.LexAll()
+		if wantsTrivia(only) {
+			toks = tokenizer.MergeTrivia(toks, lx.Comments())
+		}
+		found += grepTokens(os.Stdout, name, toks, match)
+	}
+	if status == 0 && found == 0 {
+		status = 1
+	}
+	return status
+}
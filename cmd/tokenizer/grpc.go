@@ -0,0 +1,397 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"tokenizer"
+)
+
+// The gRPC service of proto/tokenizer.proto, served by net/http over HTTP/2
+// with the few protobuf messages it needs encoded by hand, so the module
+// keeps no dependencies.
+
+const (
+	grpcTokenize       = "/tokenizer.v1.Tokenizer/Tokenize"
+	grpcTokenizeStream = "/tokenizer.v1.Tokenizer/TokenizeStream"
+)
+
+// gRPC status codes used here.
+const (
+	grpcOK                = 0
+	grpcCanceled          = 1
+	grpcInvalidArgument   = 3
+	grpcDeadlineExceeded  = 4
+	grpcResourceExhausted = 8
+	grpcUnimplemented     = 12
+	grpcInternal          = 13
+)
+
+// grpcError is a call's failure, sent as its grpc-status and grpc-message.
+type grpcError struct {
+	code int
+	msg  string
+}
+
+func (e *grpcError) Error() string { return e.msg }
+
+func grpcErrorf(code int, format string, args ...interface{}) error {
+	return &grpcError{code, fmt.Sprintf(format, args...)}
+}
+
+// grpcRequest is a decoded TokenizeRequest.
+type grpcRequest struct {
+	ID     string
+	Source string
+}
+
+func newGRPCHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(grpcTokenize, func(w http.ResponseWriter, r *http.Request) {
+		serveGRPCCall(w, r, false)
+	})
+	mux.HandleFunc(grpcTokenizeStream, func(w http.ResponseWriter, r *http.Request) {
+		serveGRPCCall(w, r, true)
+	})
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if !isGRPC(r) {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/grpc")
+		writeGRPCStatus(w, grpcErrorf(grpcUnimplemented, "unknown method %s", r.URL.Path))
+	})
+	return mux
+}
+
+func isGRPC(r *http.Request) bool {
+	return r.ProtoMajor == 2 && strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc")
+}
+
+// serveGRPCCall answers one call: a single request for Tokenize, and any
+// number for TokenizeStream, each answered as soon as it is lexed. The next
+// request is read only after the response to the last one is written, so a
+// client that does not read its responses is held back by HTTP/2 flow
+// control.
+func serveGRPCCall(w http.ResponseWriter, r *http.Request, stream bool) {
+	if r.Method != http.MethodPost || !isGRPC(r) {
+		http.Error(w, "gRPC requests only", http.StatusUnsupportedMediaType)
+		return
+	}
+	ctx := r.Context()
+	if t := r.Header.Get("Grpc-Timeout"); t != "" {
+		d, err := parseGRPCTimeout(t)
+		if err != nil {
+			w.Header().Set("Content-Type", "application/grpc")
+			writeGRPCStatus(w, grpcErrorf(grpcInvalidArgument, "%v", err))
+			return
+		}
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d)
+		defer cancel()
+	}
+	w.Header().Set("Content-Type", "application/grpc")
+	w.WriteHeader(http.StatusOK)
+	// send the headers now: a streaming client waits for them before it
+	// sends its first request
+	rc := http.NewResponseController(w)
+	rc.Flush()
+	var err error
+	for n := 0; ; n++ {
+		var msg []byte
+		msg, err = readGRPCMessage(r.Body)
+		if err == io.EOF {
+			err = nil
+			if !stream && n == 0 {
+				err = grpcErrorf(grpcInvalidArgument, "no request message")
+			}
+			break
+		}
+		if err != nil {
+			break
+		}
+		if !stream && n > 0 {
+			err = grpcErrorf(grpcInvalidArgument, "more than one request message")
+			break
+		}
+		var req grpcRequest
+		if req, err = decodeTokenizeRequest(msg); err != nil {
+			break
+		}
+		var resp []byte
+		if resp, err = tokenizeGRPC(ctx, req); err != nil {
+			break
+		}
+		if _, err = w.Write(resp); err != nil {
+			break
+		}
+		if err = rc.Flush(); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = ctx.Err() // a deadline that passed while idle
+	}
+	writeGRPCStatus(w, err)
+}
+
+// writeGRPCStatus sends the trailers ending a call.
+func writeGRPCStatus(w http.ResponseWriter, err error) {
+	code, msg := grpcOK, ""
+	var ge *grpcError
+	switch {
+	case err == nil:
+	case errors.As(err, &ge):
+		code, msg = ge.code, ge.msg
+	case errors.Is(err, context.DeadlineExceeded):
+		code, msg = grpcDeadlineExceeded, err.Error()
+	case errors.Is(err, context.Canceled):
+		code, msg = grpcCanceled, err.Error()
+	default:
+		code, msg = grpcInternal, err.Error()
+	}
+	w.Header().Set(http.TrailerPrefix+"Grpc-Status", strconv.Itoa(code))
+	if msg != "" {
+		w.Header().Set(http.TrailerPrefix+"Grpc-Message", grpcPercentEncode(msg))
+	}
+}
+
+// grpcPercentEncode escapes msg as the grpc-message trailer requires.
+func grpcPercentEncode(msg string) string {
+	var b strings.Builder
+	for i := 0; i < len(msg); i++ {
+		if c := msg[i]; c < ' ' || c > '~' || c == '%' {
+			fmt.Fprintf(&b, "%%%02X", c)
+		} else {
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// parseGRPCTimeout reads a grpc-timeout header: up to 8 digits and a unit.
+func parseGRPCTimeout(s string) (time.Duration, error) {
+	units := map[byte]time.Duration{'H': time.Hour, 'M': time.Minute, 'S': time.Second, 'm': time.Millisecond, 'u': time.Microsecond, 'n': time.Nanosecond}
+	if len(s) < 2 || len(s) > 9 {
+		return 0, fmt.Errorf("bad grpc-timeout %q", s)
+	}
+	unit, ok := units[s[len(s)-1]]
+	n, err := strconv.ParseInt(s[:len(s)-1], 10, 64)
+	if !ok || err != nil || n < 0 {
+		return 0, fmt.Errorf("bad grpc-timeout %q", s)
+	}
+	return time.Duration(n) * unit, nil
+}
+
+// readGRPCMessage reads one length-prefixed message. It returns io.EOF when
+// the client has no more.
+func readGRPCMessage(r io.Reader) ([]byte, error) {
+	var hdr [5]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		if err == io.EOF {
+			return nil, io.EOF
+		}
+		return nil, grpcErrorf(grpcInternal, "read message header: %v", err)
+	}
+	if hdr[0] != 0 {
+		return nil, grpcErrorf(grpcUnimplemented, "compressed messages are not supported")
+	}
+	n := binary.BigEndian.Uint32(hdr[1:])
+	if n > maxRequestBody {
+		return nil, grpcErrorf(grpcResourceExhausted, "message of %d bytes is larger than %d", n, maxRequestBody)
+	}
+	msg := make([]byte, n)
+	if _, err := io.ReadFull(r, msg); err != nil {
+		return nil, grpcErrorf(grpcInternal, "read message: %v", err)
+	}
+	return msg, nil
+}
+
+// tokenizeGRPC lexes req into a framed TokenizeResponse.
+func tokenizeGRPC(ctx context.Context, req grpcRequest) ([]byte, error) {
+	lx := tokenizer.NewLexer(req.Source)
+	toks, _, err := lx.LexAllContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	msg := make([]byte, 5, 5+len(toks)*16)
+	msg = appendProtoString(msg, 1, req.ID)
+	var sub []byte
+	for _, t := range toks {
+		sub = encodeProtoToken(sub[:0], t)
+		msg = appendProtoBytes(msg, 2, sub)
+	}
+	for _, d := range lx.Diagnostics() {
+		sub = encodeProtoDiagnostic(sub[:0], d)
+		msg = appendProtoBytes(msg, 3, sub)
+	}
+	binary.BigEndian.PutUint32(msg[1:5], uint32(len(msg)-5))
+	return msg, nil
+}
+
+// decodeTokenizeRequest decodes a TokenizeRequest.
+func decodeTokenizeRequest(msg []byte) (grpcRequest, error) {
+	var req grpcRequest
+	err := eachProtoField(msg, func(num, wire int, _ uint64, data []byte) {
+		switch {
+		case num == 1 && wire == protoBytes:
+			req.ID = string(data)
+		case num == 2 && wire == protoBytes:
+			req.Source = string(data)
+		}
+	})
+	if err != nil {
+		return req, grpcErrorf(grpcInvalidArgument, "bad TokenizeRequest: %v", err)
+	}
+	return req, nil
+}
+
+func encodeProtoToken(b []byte, t tokenizer.Token) []byte {
+	b = appendProtoString(b, 1, string(t.Type))
+	b = appendProtoString(b, 2, t.Lexeme)
+	b = appendProtoInt(b, 3, int64(t.Line))
+	b = appendProtoInt(b, 4, int64(t.Column))
+	b = appendProtoInt(b, 5, int64(t.Offset))
+	b = appendProtoInt(b, 6, int64(t.End))
+	if t.Value != nil {
+		b = appendProtoBytes(b, 7, []byte(*t.Value))
+	}
+	b = appendProtoInt(b, 8, int64(t.File))
+	if t.IntVal != nil {
+		b = appendProtoVarint(b, 9, uint64(*t.IntVal))
+	}
+	if t.FloatVal != nil {
+		b = binary.AppendUvarint(b, 10<<3|protoFixed64)
+		b = binary.LittleEndian.AppendUint64(b, math.Float64bits(*t.FloatVal))
+	}
+	if t.RuneVal != nil {
+		b = appendProtoVarint(b, 11, uint64(int64(*t.RuneVal)))
+	}
+	if t.BigVal != nil {
+		b = appendProtoBytes(b, 12, []byte(*t.BigVal))
+	}
+	return b
+}
+
+func encodeProtoDiagnostic(b []byte, d tokenizer.Diagnostic) []byte {
+	b = appendProtoString(b, 1, d.Phase)
+	b = appendProtoInt(b, 2, int64(d.Line))
+	b = appendProtoInt(b, 3, int64(d.Col))
+	b = appendProtoInt(b, 4, int64(d.Offset))
+	b = appendProtoInt(b, 5, int64(d.End))
+	b = appendProtoString(b, 6, d.Message)
+	b = appendProtoString(b, 7, d.Code)
+	b = appendProtoInt(b, 8, int64(d.File))
+	return b
+}
+
+// Protobuf wire types.
+const (
+	protoVarint  = 0
+	protoFixed64 = 1
+	protoBytes   = 2
+	protoFixed32 = 5
+)
+
+func appendProtoVarint(b []byte, num int, v uint64) []byte {
+	b = binary.AppendUvarint(b, uint64(num)<<3|protoVarint)
+	return binary.AppendUvarint(b, v)
+}
+
+// appendProtoInt appends an int32 or int64 field, left out when 0 as proto3
+// does.
+func appendProtoInt(b []byte, num int, v int64) []byte {
+	if v == 0 {
+		return b
+	}
+	return appendProtoVarint(b, num, uint64(v))
+}
+
+func appendProtoBytes(b []byte, num int, data []byte) []byte {
+	b = binary.AppendUvarint(b, uint64(num)<<3|protoBytes)
+	b = binary.AppendUvarint(b, uint64(len(data)))
+	return append(b, data...)
+}
+
+// appendProtoString appends a string field, left out when empty.
+func appendProtoString(b []byte, num int, s string) []byte {
+	if s == "" {
+		return b
+	}
+	b = binary.AppendUvarint(b, uint64(num)<<3|protoBytes)
+	b = binary.AppendUvarint(b, uint64(len(s)))
+	return append(b, s...)
+}
+
+// eachProtoField calls fn with each field of the message msg: its number,
+// wire type, and value, as v for varint and fixed types and as data for
+// length-delimited ones.
+func eachProtoField(msg []byte, fn func(num, wire int, v uint64, data []byte)) error {
+	for len(msg) > 0 {
+		key, n := binary.Uvarint(msg)
+		if n <= 0 || key>>3 == 0 {
+			return errors.New("bad field key")
+		}
+		msg = msg[n:]
+		num, wire := int(key>>3), int(key&7)
+		var v uint64
+		var data []byte
+		switch wire {
+		case protoVarint:
+			if v, n = binary.Uvarint(msg); n <= 0 {
+				return errors.New("bad varint")
+			}
+			msg = msg[n:]
+		case protoFixed64, protoFixed32:
+			size := 8
+			if wire == protoFixed32 {
+				size = 4
+			}
+			if len(msg) < size {
+				return errors.New("truncated fixed-size field")
+			}
+			for i := size - 1; i >= 0; i-- {
+				v = v<<8 | uint64(msg[i])
+			}
+			msg = msg[size:]
+		case protoBytes:
+			size, n := binary.Uvarint(msg)
+			if n <= 0 || size > uint64(len(msg)-n) {
+				return errors.New("truncated length-delimited field")
+			}
+			data, msg = msg[n:n+int(size)], msg[n+int(size):]
+		default:
+			return fmt.Errorf("unsupported wire type %d", wire)
+		}
+		fn(num, wire, v, data)
+	}
+	return nil
+}
+
+// serveGRPC runs the gRPC service on addr, over HTTP/2 without TLS, until
+// it fails.
+func serveGRPC(addr string) int {
+	var protocols http.Protocols
+	protocols.SetUnencryptedHTTP2(true)
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           newGRPCHandler(),
+		Protocols:         &protocols,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	log.Printf("serving gRPC on %s", addr)
+	if err := srv.ListenAndServe(); err != nil {
+		log.Print(err)
+		return exitFailure
+	}
+	return 0
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"html"
+	"io"
+	"os"
+
+	"tokenizer"
+)
+
+const highlightCSS = `body { background: #fdfdfd; }
+pre.jl { font-family: monospace; font-size: 14px; line-height: 1.4; }
+.jl .keyword { color: #7a1fa2; font-weight: bold; }
+.jl .type { color: #00796b; }
+.jl .ident { color: #222; }
+.jl .literal { color: #2e7d32; }
+.jl .operator { color: #555; }
+.jl .comment { color: #888; font-style: italic; }
+`
+
+// renderHTML writes src as a standalone HTML page, wrapping every token and
+// comment in a span classed by its TokenCategory.
+func renderHTML(w io.Writer, title, src string, toks, comments []tokenizer.Token) {
+	fmt.Fprintf(w, "<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n<title>%s</title>\n<style>\n%s</style>\n</head>\n<body>\n<pre class=\"jl\">",
+		html.EscapeString(title), highlightCSS)
+	pos := 0
+	for _, t := range tokenizer.MergeTrivia(toks, comments) {
+		if t.Offset < pos || t.End > len(src) {
+			continue
+		}
+		io.WriteString(w, html.EscapeString(src[pos:t.Offset]))
+		fmt.Fprintf(w, `<span class="%s">%s</span>`, tokenizer.TokenCategory(t.Type), html.EscapeString(src[t.Offset:t.End]))
+		pos = t.End
+	}
+	io.WriteString(w, html.EscapeString(src[pos:]))
+	io.WriteString(w, "</pre>\n</body>\n</html>\n")
+}
+
+// runHighlight implements `tokenizer highlight [file]`.
+func runHighlight(args []string) int {
+	fs := flag.NewFlagSet("highlight", flag.ExitOnError)
+	title := fs.String("title", "", "page title (defaults to the file name)")
+	fs.Parse(args)
+
+	data, srcPath, err := readSource(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitFailure
+	}
+	if *title == "" {
+		*title = srcPath
+	}
+	lx := tokenizer.NewLexer(string(data))
+	toks, _ := lx.LexAll()
+	renderHTML(os.Stdout, *title, string(data), toks, lx.Comments())
+	return 0
+}
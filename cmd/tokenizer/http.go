@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// maxRequestBody bounds the source accepted by POST /tokenize.
+const maxRequestBody = 4 << 20
+
+// tokenizeRequest is the JSON form of a POST /tokenize body. Plain-text
+// bodies are taken as the source itself.
+type tokenizeRequest struct {
+	Source string `json:"source"`
+	Parse  bool   `json:"parse"`
+}
+
+func newHTTPHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		io.WriteString(w, "ok\n")
+	})
+	mux.HandleFunc("/tokenize", handleTokenize)
+	return mux
+}
+
+// handleTokenize answers POST /tokenize with the JSON token document. The
+// body is either raw source text or a tokenizeRequest when sent as
+// application/json; ?parse=1 also asks for the AST.
+func handleTokenize(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "use POST", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestBody))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("read body error: %v", err), http.StatusRequestEntityTooLarge)
+		return
+	}
+	req := tokenizeRequest{Source: string(body)}
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		req = tokenizeRequest{}
+		if err := json.Unmarshal(body, &req); err != nil {
+			http.Error(w, fmt.Sprintf("parse request error: %v", err), http.StatusBadRequest)
+			return
+		}
+	}
+	if p := r.URL.Query().Get("parse"); p == "1" || p == "true" {
+		req.Parse = true
+	}
+	doc, err := tokenizeDocument(r.Context(), req.Source, req.Parse)
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		// the client went away or the server is shutting down
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("marshal json error: %v", err), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(append(doc, '\n'))
+}
+
+// serveHTTP runs the tokenization service on addr until it fails.
+func serveHTTP(addr string) int {
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           newHTTPHandler(),
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       30 * time.Second,
+		WriteTimeout:      30 * time.Second,
+	}
+	log.Printf("serving on %s", addr)
+	if err := srv.ListenAndServe(); err != nil {
+		log.Print(err)
+		return exitFailure
+	}
+	return 0
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"io"
+	"os"
+
+	"tokenizer"
+)
+
+// writeDocumentLine writes the document followed by a newline, as printed
+// on stdout.
+func writeDocumentLine(w io.Writer, d *tokenizer.TokenDocument, compact bool) error {
+	if err := d.WriteJSON(w, compact); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// writeDocumentFile writes the document to the file at path.
+func writeDocumentFile(path string, d *tokenizer.TokenDocument, compact bool) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := d.WriteJSON(f, compact); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,575 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"tokenizer"
+)
+
+// LintIssue is one finding of a lint rule.
+type LintIssue struct {
+	Rule    string `json:"rule"`
+	Line    int    `json:"line"`
+	Col     int    `json:"col"`
+	Message string `json:"message"`
+	// for the indent rule, the indentation the line should have and has,
+	// such as "2 tabs" and "4 spaces"; for the naming rules, the suggested
+	// name and the declared one
+	Expected string `json:"expected,omitempty"`
+	Actual   string `json:"actual,omitempty"`
+}
+
+func (is LintIssue) String() string {
+	return fmt.Sprintf("%d:%d: [%s] %s", is.Line, is.Col, is.Rule, is.Message)
+}
+
+// LintConfig holds the settings shared by all rules. It can be loaded from
+// a JSON file with -config and is then overridden by command-line flags.
+type LintConfig struct {
+	Enable         []string `json:"enable"`
+	Disable        []string `json:"disable"`
+	MaxLineLength  int      `json:"maxLineLength"`
+	AllowedNumbers []string `json:"allowedNumbers"`
+	IndentWidth    int      `json:"indentWidth"`  // spaces per level; 0 takes the first indented line's
+	VarNameStyle   string   `json:"varNameStyle"` // camelCase or snake_case, for var-name
+}
+
+var defaultLintConfig = LintConfig{
+	MaxLineLength:  100,
+	AllowedNumbers: []string{"0", "1", "2"},
+	VarNameStyle:   "camelCase",
+}
+
+// Name styles of the naming rules.
+const (
+	styleCamel     = "CamelCase"
+	styleLowCamel  = "camelCase"
+	styleSnake     = "snake_case"
+	styleScreaming = "SCREAMING_SNAKE"
+)
+
+// lintFile is what a rule sees: one lexed file plus a way to report.
+type lintFile struct {
+	Src    string
+	Lines  []string
+	Tokens []tokenizer.Token
+	Config LintConfig
+	rule   string
+	issues []LintIssue
+}
+
+func (f *lintFile) report(line, col int, format string, args ...interface{}) {
+	f.issues = append(f.issues, LintIssue{Rule: f.rule, Line: line, Col: col, Message: fmt.Sprintf(format, args...)})
+}
+
+// LintRule is a named check over the token stream of one file. Rules are
+// registered in lintRules; Default rules run unless disabled.
+type LintRule struct {
+	Name    string
+	Doc     string
+	Default bool
+	Check   func(f *lintFile)
+}
+
+var lintRules = []*LintRule{
+	{Name: "mixed-indent", Doc: "indentation mixes tabs and spaces", Default: true, Check: lintMixedIndent},
+	{Name: "assign-decl", Doc: "'=' at statement start on a name that was never declared; ':=' was likely intended", Default: true, Check: lintAssignDecl},
+	{Name: "line-length", Doc: "line longer than maxLineLength characters", Default: true, Check: lintLineLength},
+	{Name: "magic-number", Doc: "numeric literal outside a cons declaration", Default: false, Check: lintMagicNumber},
+	{Name: "indent", Doc: "indentation that is not a multiple of the indent width or does not follow brace depth", Default: false, Check: lintIndent},
+	{Name: "type-name", Doc: "type name that is not CamelCase", Default: false, Check: lintTypeName},
+	{Name: "var-name", Doc: "variable or parameter name not in varNameStyle (camelCase or snake_case)", Default: false, Check: lintVarName},
+	{Name: "cons-name", Doc: "cons name that is not SCREAMING_SNAKE", Default: false, Check: lintConsName},
+}
+
+// lintMixedIndent flags lines whose indentation mixes tabs and spaces, and
+// lines indented differently from the first indented line of the file.
+func lintMixedIndent(f *lintFile) {
+	style, first := "", 0
+	for i, line := range f.Lines {
+		indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+		if strings.TrimSpace(line) == "" || indent == "" {
+			continue
+		}
+		hasTab, hasSpace := strings.Contains(indent, "\t"), strings.Contains(indent, " ")
+		if hasTab && hasSpace {
+			f.report(i+1, 1, "indentation mixes tabs and spaces")
+			continue
+		}
+		cur := "spaces"
+		if hasTab {
+			cur = "tabs"
+		}
+		if style == "" {
+			style, first = cur, i+1
+		} else if cur != style {
+			f.report(i+1, 1, "indented with %s but line %d is indented with %s", cur, first, style)
+		}
+	}
+}
+
+// lintIndent checks the indentation of the lines that begin with a token
+// against their brace depth, with the conventions of `tokenizer fmt`: a line
+// starting with a closing bracket, case or dft is one level out. Lines
+// continuing an expression, inside parentheses or brackets or after a
+// binary operator, may be indented freely, and lines mixing tabs and spaces
+// are left to mixed-indent. The file's first indented line sets whether
+// levels are tabs or spaces, and how many spaces.
+func lintIndent(f *lintFile) {
+	width, tabs := f.Config.IndentWidth, false
+	styled := false
+	depth, parens := 0, 0
+	var prev *tokenizer.Token
+	for i := range f.Tokens {
+		t := f.Tokens[i]
+		first := prev == nil || tokenizer.LineBreak(*prev, t)
+		continued := prev != nil && (parens > 0 || continuesLine(*prev) && prev.Type != tokenizer.SEMI && prev.Type != tokenizer.LBRACE && prev.Type != tokenizer.RBRACE)
+		if first && !continued && t.Type != tokenizer.DIRECTIVE && t.Type != tokenizer.PRAGMA && t.Line <= len(f.Lines) {
+			level := depth
+			switch t.Type {
+			case tokenizer.RBRACE, tokenizer.KW_CASE, tokenizer.KW_DFT:
+				level--
+			}
+			line := f.Lines[t.Line-1]
+			indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+			hasTab, hasSpace := strings.Contains(indent, "\t"), strings.Contains(indent, " ")
+			if !styled && indent != "" && !(hasTab && hasSpace) && level > 0 {
+				styled, tabs = true, hasTab
+				if !tabs && width <= 0 {
+					width = len(indent) / level
+					if width == 0 {
+						width = len(indent)
+					}
+				}
+			}
+			if styled && !(hasTab && hasSpace) && level >= 0 {
+				lintIndentLine(f, t.Line, indent, level, width, tabs)
+			}
+		}
+		switch t.Type {
+		case tokenizer.LBRACE:
+			depth++
+		case tokenizer.RBRACE:
+			if depth > 0 {
+				depth--
+			}
+		case tokenizer.LPAREN, tokenizer.LBRACK:
+			parens++
+		case tokenizer.RPAREN, tokenizer.RBRACK:
+			if parens > 0 {
+				parens--
+			}
+		}
+		prev = &f.Tokens[i]
+	}
+}
+
+// lintIndentLine reports a line indented with indent that should be level
+// levels deep.
+func lintIndentLine(f *lintFile, line int, indent string, level, width int, tabs bool) {
+	describe := func(n int, unit string) string {
+		if n == 1 {
+			return "1 " + unit
+		}
+		return fmt.Sprintf("%d %ss", n, unit)
+	}
+	expected, actual := describe(level, "tab"), describe(len(indent), "tab")
+	if !tabs {
+		expected, actual = describe(level*width, "space"), describe(len(indent), "space")
+	}
+	switch {
+	case tabs && strings.Contains(indent, " "), !tabs && strings.Contains(indent, "\t"):
+		return // the other style; mixed-indent reports it
+	case !tabs && len(indent)%width != 0:
+		f.report(line, 1, "indented %s, not a multiple of %d", actual, width)
+	case expected != actual:
+		f.report(line, 1, "indented %s, expected %s at brace depth %d", actual, expected, level)
+	default:
+		return
+	}
+	f.issues[len(f.issues)-1].Expected, f.issues[len(f.issues)-1].Actual = expected, actual
+}
+
+// atStmtStart reports whether toks[i] begins a statement: it is the first
+// token, follows ';', '{' or '}', or starts a new line.
+func atStmtStart(toks []tokenizer.Token, i int) bool {
+	if i == 0 {
+		return true
+	}
+	prev := toks[i-1]
+	switch prev.Type {
+	case tokenizer.SEMI, tokenizer.LBRACE, tokenizer.RBRACE:
+		return true
+	}
+	return toks[i].Line > tokenizer.EndLine(prev)
+}
+
+func lintAssignDecl(f *lintFile) {
+	declared := map[string]bool{}
+	toks := f.Tokens
+	// markList marks the identifier list `a, b, c` that ends (step -1) or
+	// starts (step +1) at index i.
+	markList := func(i, step int) {
+		for i >= 0 && i < len(toks) && toks[i].Type == tokenizer.IDENT {
+			declared[toks[i].Lexeme] = true
+			i += step
+			if i < 0 || i >= len(toks) || toks[i].Type != tokenizer.COMMA {
+				return
+			}
+			i += step
+		}
+	}
+	for i, t := range toks {
+		switch t.Type {
+		case tokenizer.KW_VAR, tokenizer.KW_CONS, tokenizer.KW_DEF, tokenizer.KW_TYPE:
+			markList(i+1, 1)
+		case tokenizer.DECL, tokenizer.COLON:
+			markList(i-1, -1)
+		case tokenizer.ASSIGN:
+			if i == 0 || toks[i-1].Type != tokenizer.IDENT {
+				continue
+			}
+			id := toks[i-1]
+			if atStmtStart(toks, i-1) && !declared[id.Lexeme] {
+				f.report(id.Line, id.Column, "assignment to undeclared %q; did you mean ':='?", id.Lexeme)
+				declared[id.Lexeme] = true
+			}
+		}
+	}
+}
+
+func lintLineLength(f *lintFile) {
+	for i, line := range f.Lines {
+		if n := utf8.RuneCountInString(strings.TrimRight(line, "\r")); n > f.Config.MaxLineLength {
+			f.report(i+1, f.Config.MaxLineLength+1, "line is %d characters long (max %d)", n, f.Config.MaxLineLength)
+		}
+	}
+}
+
+func lintMagicNumber(f *lintFile) {
+	allowed := map[string]bool{}
+	for _, n := range f.Config.AllowedNumbers {
+		allowed[n] = true
+	}
+	inCons := false
+	for i, t := range f.Tokens {
+		if atStmtStart(f.Tokens, i) {
+			inCons = t.Type == tokenizer.KW_CONS
+		}
+		if (t.Type == tokenizer.INT_LIT || t.Type == tokenizer.FLOAT_LIT) && !inCons && !allowed[t.Lexeme] {
+			f.report(t.Line, t.Column, "magic number %s; consider a named cons", t.Lexeme)
+		}
+	}
+}
+
+func lintTypeName(f *lintFile) { lintNames(f, tokenizer.KW_TYPE, styleCamel) }
+func lintVarName(f *lintFile)  { lintNames(f, tokenizer.KW_VAR, f.Config.VarNameStyle) }
+func lintConsName(f *lintFile) { lintNames(f, tokenizer.KW_CONS, styleScreaming) }
+
+// lintNames reports the names declared by kind, KW_TYPE, KW_VAR or KW_CONS,
+// that are not written in style, suggesting the name in that style.
+func lintNames(f *lintFile, kind tokenizer.TokenType, style string) {
+	for _, t := range declaredNames(f.Tokens)[kind] {
+		name := tokenizer.NameOf(t)
+		if want := nameInStyle(name, style); want != name {
+			what := map[tokenizer.TokenType]string{tokenizer.KW_TYPE: "type", tokenizer.KW_VAR: "variable", tokenizer.KW_CONS: "constant"}[kind]
+			f.report(t.Line, t.Column, "%s %q is not %s; rename to %q", what, name, style, want)
+			f.issues[len(f.issues)-1].Expected, f.issues[len(f.issues)-1].Actual = want, name
+		}
+	}
+}
+
+// declaredNames returns the name tokens declared in toks by kind: types
+// after `type`, constants after `cons`, and as variables the names after
+// `var`, before `:=` and the parameters of a def.
+func declaredNames(toks []tokenizer.Token) map[tokenizer.TokenType][]tokenizer.Token {
+	names := map[tokenizer.TokenType][]tokenizer.Token{}
+	// list collects the identifier list `a, b, c` that starts (step +1) or
+	// ends (step -1) at index i.
+	list := func(kind tokenizer.TokenType, i, step int) {
+		for i >= 0 && i < len(toks) && toks[i].Type == tokenizer.IDENT {
+			names[kind] = append(names[kind], toks[i])
+			i += step
+			if i < 0 || i >= len(toks) || toks[i].Type != tokenizer.COMMA {
+				return
+			}
+			i += step
+		}
+	}
+	params := -1 // paren depth of a def's parameter list, while in one
+	depth := 0
+	for i, t := range toks {
+		switch t.Type {
+		case tokenizer.KW_TYPE:
+			if i+1 < len(toks) && toks[i+1].Type == tokenizer.IDENT {
+				names[tokenizer.KW_TYPE] = append(names[tokenizer.KW_TYPE], toks[i+1])
+			}
+		case tokenizer.KW_VAR, tokenizer.KW_CONS:
+			list(t.Type, i+1, 1)
+		case tokenizer.DECL:
+			list(tokenizer.KW_VAR, i-1, -1)
+		case tokenizer.KW_DEF:
+			if i+2 < len(toks) && toks[i+2].Type == tokenizer.LPAREN {
+				params = depth + 1
+			}
+		case tokenizer.LPAREN:
+			depth++
+		case tokenizer.RPAREN:
+			if depth == params {
+				params = -1
+			}
+			depth--
+		case tokenizer.COLON:
+			if depth == params {
+				list(tokenizer.KW_VAR, i-1, -1)
+			}
+		}
+	}
+	for kind := range names {
+		sort.SliceStable(names[kind], func(a, b int) bool { return names[kind][a].Offset < names[kind][b].Offset })
+	}
+	return names
+}
+
+// nameInStyle returns name written in style, keeping leading underscores
+// and acronyms: "http_server" is "HttpServer" in CamelCase, "parseURL" is
+// "parse_url" in snake_case. The blank name _ is left as it is.
+func nameInStyle(name, style string) string {
+	rest := strings.TrimLeft(name, "_")
+	if rest == "" {
+		return name
+	}
+	lead := name[:len(name)-len(rest)]
+	words := nameWords(rest)
+	for i, w := range words {
+		switch style {
+		case styleSnake:
+			w = strings.ToLower(w)
+		case styleScreaming:
+			w = strings.ToUpper(w)
+		default:
+			if i == 0 && style == styleLowCamel {
+				w = strings.ToLower(w)
+			} else if strings.ToUpper(w) != w {
+				r, n := utf8.DecodeRuneInString(w)
+				w = string(unicode.ToUpper(r)) + strings.ToLower(w[n:])
+			}
+		}
+		words[i] = w
+	}
+	if style == styleSnake || style == styleScreaming {
+		return lead + strings.Join(words, "_")
+	}
+	return lead + strings.Join(words, "")
+}
+
+// nameWords splits a name into its words, at underscores and at changes of
+// case: "parseHTTPRequest2" is parse, HTTP, Request2.
+func nameWords(name string) []string {
+	var words []string
+	rs := []rune(name)
+	start := 0
+	for i := 0; i <= len(rs); i++ {
+		split := i == len(rs) || rs[i] == '_'
+		if !split && i > start {
+			prev := rs[i-1]
+			split = unicode.IsUpper(rs[i]) && (unicode.IsLower(prev) || unicode.IsDigit(prev) ||
+				unicode.IsUpper(prev) && i+1 < len(rs) && unicode.IsLower(rs[i+1]))
+		}
+		if !split {
+			continue
+		}
+		if i > start {
+			words = append(words, string(rs[start:i]))
+		}
+		if i < len(rs) && rs[i] == '_' {
+			start = i + 1
+		} else {
+			start = i
+		}
+	}
+	return words
+}
+
+// Lint runs the enabled rules over src and returns their findings ordered by
+// position. A `//#pragma:nolint rule, ...` pragma silences the rules named
+// (all of them when none is) on its own line and the next.
+func Lint(src string, toks []tokenizer.Token, cfg LintConfig, enabled map[string]bool) []LintIssue {
+	f := &lintFile{Src: src, Lines: tokenizer.SourceLines(src), Tokens: toks, Config: cfg}
+	for _, r := range lintRules {
+		if enabled[r.Name] {
+			f.rule = r.Name
+			r.Check(f)
+		}
+	}
+	if nolint := nolintLines(toks); len(nolint) > 0 {
+		kept := f.issues[:0]
+		for _, is := range f.issues {
+			if rules := nolint[is.Line]; !rules["all"] && !rules[is.Rule] {
+				kept = append(kept, is)
+			}
+		}
+		f.issues = kept
+	}
+	sort.SliceStable(f.issues, func(i, j int) bool {
+		a, b := f.issues[i], f.issues[j]
+		return a.Line < b.Line || a.Line == b.Line && a.Col < b.Col
+	})
+	return f.issues
+}
+
+// nolintLines maps each line a nolint pragma covers to the rules it
+// silences; "all" silences every rule.
+func nolintLines(toks []tokenizer.Token) map[int]map[string]bool {
+	lines := map[int]map[string]bool{}
+	for _, t := range toks {
+		if t.Type != tokenizer.PRAGMA || *t.Value != "nolint" {
+			continue
+		}
+		rules := strings.FieldsFunc(*t.Arg, func(r rune) bool { return r == ',' || r == ' ' || r == '\t' })
+		if len(rules) == 0 {
+			rules = []string{"all"}
+		}
+		for _, l := range []int{t.Line, t.Line + 1} {
+			if lines[l] == nil {
+				lines[l] = map[string]bool{}
+			}
+			for _, r := range rules {
+				lines[l][r] = true
+			}
+		}
+	}
+	return lines
+}
+
+// enabledRules resolves which rules run: the defaults, plus cfg.Enable,
+// minus cfg.Disable. "all" may be used in either list.
+func enabledRules(cfg LintConfig) (map[string]bool, error) {
+	known := map[string]bool{}
+	on := map[string]bool{}
+	for _, r := range lintRules {
+		known[r.Name] = true
+		on[r.Name] = r.Default
+	}
+	for _, list := range []struct {
+		names []string
+		value bool
+	}{{cfg.Enable, true}, {cfg.Disable, false}} {
+		for _, n := range list.names {
+			if n == "all" {
+				for k := range on {
+					on[k] = list.value
+				}
+				continue
+			}
+			if !known[n] {
+				return nil, fmt.Errorf("unknown lint rule %q", n)
+			}
+			on[n] = list.value
+		}
+	}
+	return on, nil
+}
+
+// splitList splits a comma-separated flag value, dropping empty entries.
+func splitList(s string) []string {
+	var out []string
+	for _, p := range strings.Split(s, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// runLint implements `tokenizer lint [flags] [file ...]`.
+func runLint(args []string) int {
+	fs := flag.NewFlagSet("lint", flag.ExitOnError)
+	configPath := fs.String("config", "", "JSON lint configuration file")
+	enable := fs.String("enable", "", "comma-separated rules to enable (or \"all\")")
+	disable := fs.String("disable", "", "comma-separated rules to disable (or \"all\")")
+	maxLine := fs.Int("max-line-length", 0, "maximum line length (default 100)")
+	indentWidth := fs.Int("indent-width", 0, "spaces per indent level for the indent rule (default: as the file's first indented line)")
+	varStyle := fs.String("var-style", "", "style of variable names for var-name: camelCase or snake_case (default camelCase)")
+	list := fs.Bool("list", false, "list the available rules and exit")
+	stdinName := fs.String("stdin-name", "", "file name to report for input read from stdin")
+	fs.Parse(args)
+
+	if *list {
+		for _, r := range lintRules {
+			state := "off"
+			if r.Default {
+				state = "on"
+			}
+			fmt.Printf("%-14s %-3s %s\n", r.Name, state, r.Doc)
+		}
+		return 0
+	}
+
+	cfg := defaultLintConfig
+	if *configPath != "" {
+		data, err := os.ReadFile(*configPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "read config error: %v\n", err)
+			return exitFailure
+		}
+		if err := json.Unmarshal(data, &cfg); err != nil {
+			fmt.Fprintf(os.Stderr, "parse config error: %v\n", err)
+			return exitFailure
+		}
+	}
+	cfg.Enable = append(cfg.Enable, splitList(*enable)...)
+	cfg.Disable = append(cfg.Disable, splitList(*disable)...)
+	if *maxLine > 0 {
+		cfg.MaxLineLength = *maxLine
+	}
+	if *indentWidth > 0 {
+		cfg.IndentWidth = *indentWidth
+	}
+	if *varStyle != "" {
+		cfg.VarNameStyle = *varStyle
+	}
+	if cfg.VarNameStyle != styleLowCamel && cfg.VarNameStyle != styleSnake {
+		fmt.Fprintf(os.Stderr, "unknown varNameStyle %q (want camelCase or snake_case)\n", cfg.VarNameStyle)
+		return exitFailure
+	}
+	enabled, err := enabledRules(cfg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitFailure
+	}
+
+	paths := fs.Args()
+	if len(paths) == 0 {
+		paths = []string{"-"}
+	}
+	status := 0
+	for _, path := range paths {
+		data, name, err := readSource(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			status = exitFailure
+			continue
+		}
+		if name == "-" && *stdinName != "" {
+			name = *stdinName
+		}
+		toks, errs := tokenizer.NewLexer(string(data)).LexAll()
+		for _, e := range errs {
+			fmt.Printf("%s: %s\n", name, e)
+			status = max(status, exitErrors)
+		}
+		for _, is := range Lint(string(data), toks, cfg, enabled) {
+			fmt.Printf("%s:%s\n", name, is)
+			status = max(status, exitErrors)
+		}
+	}
+	return status
+}
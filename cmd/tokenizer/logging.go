@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"time"
+
+	"tokenizer"
+)
+
+// newLogger returns the logger of --verbose: records at level and above,
+// written to w as text or JSON lines. With level "" nothing is logged, and
+// records are not even formatted.
+func newLogger(w io.Writer, level, format string) (*slog.Logger, error) {
+	if level == "" {
+		return slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{Level: slog.LevelError + 1})), nil
+	}
+	var lv slog.Level
+	if err := lv.UnmarshalText([]byte(level)); err != nil {
+		return nil, fmt.Errorf("unknown log level %q (want debug, info, warn or error)", level)
+	}
+	opts := &slog.HandlerOptions{Level: lv}
+	switch strings.ToLower(format) {
+	case "text":
+		return slog.New(slog.NewTextHandler(w, opts)), nil
+	case "json":
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	}
+	return nil, fmt.Errorf("unknown log format %q (want text or json)", format)
+}
+
+// logInput logs the outcome of one input: at info level its size, tokens,
+// errors and how long lexing took, and also at warn level when it has
+// errors, so --log-level warn lists just the inputs that failed.
+func logInput(log *slog.Logger, file string, bytes, tokens, errors int, elapsed time.Duration) {
+	level := slog.LevelInfo
+	if errors > 0 {
+		level = slog.LevelWarn
+	}
+	log.Log(context.Background(), level, "lexed", "file", file, "bytes", bytes, "tokens", tokens, "errors", errors, "elapsed", elapsed)
+}
+
+// logRecoveries logs at debug level each error the lexer or parser of file
+// recovered from to carry on.
+func logRecoveries(log *slog.Logger, file string, diags []tokenizer.Diagnostic) {
+	for _, d := range diags {
+		log.Debug("recovered", "file", file, "phase", d.Phase, "code", d.Code, "line", d.Line, "col", d.Col, "message", d.Message)
+	}
+}
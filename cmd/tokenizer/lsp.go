@@ -0,0 +1,306 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/textproto"
+	"os"
+	"strconv"
+
+	"tokenizer"
+)
+
+// rpcMessage is a JSON-RPC 2.0 request, notification or response.
+type rpcMessage struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+const (
+	rpcParseError     = -32700
+	rpcMethodNotFound = -32601
+	rpcInvalidParams  = -32602
+	rpcInternalError  = -32603
+)
+
+type lspPosition struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+type lspRange struct {
+	Start lspPosition `json:"start"`
+	End   lspPosition `json:"end"`
+}
+
+type lspDiagnostic struct {
+	Range    lspRange `json:"range"`
+	Severity int      `json:"severity"`
+	Code     string   `json:"code,omitempty"`
+	Source   string   `json:"source"`
+	Message  string   `json:"message"`
+}
+
+type lspTextDocument struct {
+	URI  string `json:"uri"`
+	Text string `json:"text"`
+}
+
+// lspServer holds the open documents of one client connection.
+type lspServer struct {
+	r        *bufio.Reader
+	w        io.Writer
+	legend   tokenizer.SemanticLegend
+	docs     map[string]string
+	shutdown bool
+}
+
+// positionAt converts a byte offset in src to a zero-based LSP position with
+// the character counted in UTF-16 code units.
+func positionAt(src string, off int) lspPosition {
+	if off > len(src) {
+		off = len(src)
+	}
+	lineStart := tokenizer.LineStartOf(src, off)
+	return lspPosition{
+		Line:      tokenizer.CountLineBreaks(src[:lineStart]),
+		Character: tokenizer.ColumnsOf(src, lineStart, off).UTF16 - 1,
+	}
+}
+
+// maxLSPMessage bounds the Content-Length a client may announce, so a bad
+// header cannot make the server allocate an arbitrary amount of memory.
+const maxLSPMessage = 64 << 20
+
+// readMessage reads one Content-Length framed message. A body that is not
+// valid JSON is consumed and reported as a parse error rather than a read
+// error, so the session can answer it and carry on.
+func (s *lspServer) readMessage() (*rpcMessage, *rpcError, error) {
+	hdr, err := textproto.NewReader(s.r).ReadMIMEHeader()
+	if err != nil {
+		return nil, nil, err
+	}
+	n, err := strconv.Atoi(hdr.Get("Content-Length"))
+	if err != nil {
+		return nil, nil, fmt.Errorf("bad Content-Length header: %w", err)
+	}
+	if n < 0 || n > maxLSPMessage {
+		return nil, nil, fmt.Errorf("bad Content-Length header: %d is outside 0..%d", n, maxLSPMessage)
+	}
+	body := make([]byte, n)
+	if _, err := io.ReadFull(s.r, body); err != nil {
+		return nil, nil, err
+	}
+	var msg rpcMessage
+	if err := json.Unmarshal(body, &msg); err != nil {
+		return nil, &rpcError{rpcParseError, "parse error: " + err.Error()}, nil
+	}
+	return &msg, nil, nil
+}
+
+func (s *lspServer) write(msg rpcMessage) error {
+	msg.JSONRPC = "2.0"
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(s.w, "Content-Length: %d\r\n\r\n%s", len(body), body)
+	return err
+}
+
+func (s *lspServer) notify(method string, params interface{}) error {
+	raw, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	return s.write(rpcMessage{Method: method, Params: raw})
+}
+
+// publishDiagnostics lexes the document and sends its lexical errors.
+func (s *lspServer) publishDiagnostics(uri string) error {
+	src := s.docs[uri]
+	lx := tokenizer.NewLexer(src)
+	lx.LexAll()
+	diags := []lspDiagnostic{}
+	for _, d := range lx.Diagnostics() {
+		diags = append(diags, lspDiagnostic{
+			Range:    lspRange{Start: positionAt(src, d.Offset), End: positionAt(src, d.End)},
+			Severity: 1,
+			Code:     d.Code,
+			Source:   "tokenizer",
+			Message:  d.Message,
+		})
+	}
+	return s.notify("textDocument/publishDiagnostics", map[string]interface{}{
+		"uri":         uri,
+		"diagnostics": diags,
+	})
+}
+
+// handle dispatches one message. The returned result is sent back for
+// requests and ignored for notifications.
+func (s *lspServer) handle(msg *rpcMessage) (interface{}, *rpcError) {
+	switch msg.Method {
+	case "initialize":
+		return map[string]interface{}{
+			"capabilities": map[string]interface{}{
+				"textDocumentSync": 1, // full
+				"semanticTokensProvider": map[string]interface{}{
+					"legend": map[string][]string{
+						"tokenTypes":     s.legend.TokenTypes,
+						"tokenModifiers": s.legend.TokenModifiers,
+					},
+					"full": true,
+				},
+			},
+			"serverInfo": map[string]string{"name": "tokenizer"},
+		}, nil
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+	case "textDocument/didOpen":
+		var p struct {
+			TextDocument lspTextDocument `json:"textDocument"`
+		}
+		if err := json.Unmarshal(msg.Params, &p); err != nil {
+			return nil, &rpcError{rpcInvalidParams, err.Error()}
+		}
+		s.docs[p.TextDocument.URI] = p.TextDocument.Text
+		s.publishDiagnostics(p.TextDocument.URI)
+		return nil, nil
+	case "textDocument/didChange":
+		var p struct {
+			TextDocument   lspTextDocument `json:"textDocument"`
+			ContentChanges []struct {
+				Text string `json:"text"`
+			} `json:"contentChanges"`
+		}
+		if err := json.Unmarshal(msg.Params, &p); err != nil {
+			return nil, &rpcError{rpcInvalidParams, err.Error()}
+		}
+		// full sync: the last change holds the whole document
+		if n := len(p.ContentChanges); n > 0 {
+			s.docs[p.TextDocument.URI] = p.ContentChanges[n-1].Text
+		}
+		s.publishDiagnostics(p.TextDocument.URI)
+		return nil, nil
+	case "textDocument/didClose":
+		var p struct {
+			TextDocument lspTextDocument `json:"textDocument"`
+		}
+		if err := json.Unmarshal(msg.Params, &p); err != nil {
+			return nil, &rpcError{rpcInvalidParams, err.Error()}
+		}
+		delete(s.docs, p.TextDocument.URI)
+		s.notify("textDocument/publishDiagnostics", map[string]interface{}{
+			"uri":         p.TextDocument.URI,
+			"diagnostics": []lspDiagnostic{},
+		})
+		return nil, nil
+	case "textDocument/semanticTokens/full":
+		var p struct {
+			TextDocument lspTextDocument `json:"textDocument"`
+		}
+		if err := json.Unmarshal(msg.Params, &p); err != nil {
+			return nil, &rpcError{rpcInvalidParams, err.Error()}
+		}
+		src := s.docs[p.TextDocument.URI]
+		lx := tokenizer.NewLexer(src)
+		toks, _ := lx.LexAll()
+		return map[string]interface{}{
+			"data": tokenizer.EncodeSemanticTokens(src, tokenizer.MergeTrivia(toks, lx.Comments()), s.legend),
+		}, nil
+	case "initialized", "$/cancelRequest", "$/setTrace":
+		return nil, nil
+	}
+	return nil, &rpcError{rpcMethodNotFound, "method not found: " + msg.Method}
+}
+
+// serveLSP runs the language server until the client sends exit or closes
+// the stream. It returns the process exit code required by the protocol.
+func serveLSP(r io.Reader, w io.Writer, legend tokenizer.SemanticLegend) int {
+	s := &lspServer{r: bufio.NewReader(r), w: w, legend: legend, docs: map[string]string{}}
+	for {
+		msg, perr, err := s.readMessage()
+		if err != nil {
+			if err != io.EOF {
+				fmt.Fprintf(os.Stderr, "lsp: %v\n", err)
+			}
+			return exitFailure
+		}
+		if perr != nil {
+			// JSON-RPC answers an unparsable request with a null id.
+			if err := s.write(rpcMessage{ID: json.RawMessage("null"), Error: perr}); err != nil {
+				fmt.Fprintf(os.Stderr, "lsp: %v\n", err)
+				return exitFailure
+			}
+			continue
+		}
+		if msg.Method == "exit" {
+			if s.shutdown {
+				return 0
+			}
+			return 1
+		}
+		result, rerr := s.handle(msg)
+		if len(msg.ID) == 0 {
+			continue // notification
+		}
+		resp := rpcMessage{ID: msg.ID, Error: rerr}
+		if rerr == nil {
+			if resp.Result, err = json.Marshal(result); err != nil {
+				resp.Error = &rpcError{rpcInternalError, err.Error()}
+			}
+		}
+		if err := s.write(resp); err != nil {
+			fmt.Fprintf(os.Stderr, "lsp: %v\n", err)
+			return exitFailure
+		}
+	}
+}
+
+// runServe implements `tokenizer serve --lsp` and `tokenizer serve --http addr`.
+func runServe(args []string) int {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	lsp := fs.Bool("lsp", false, "speak the Language Server Protocol over stdin/stdout")
+	httpAddr := fs.String("http", "", "serve POST /tokenize and /healthz over HTTP on this address, e.g. :8080")
+	grpcAddr := fs.String("grpc", "", "serve the gRPC Tokenizer service of proto/tokenizer.proto on this address, e.g. :50051")
+	legendPath := fs.String("legend", "", "JSON semantic-token legend")
+	fs.Parse(args)
+
+	if *httpAddr != "" && *grpcAddr != "" {
+		fmt.Fprintln(os.Stderr, "serve: choose one of --http and --grpc")
+		return exitFailure
+	}
+	if *httpAddr != "" {
+		return serveHTTP(*httpAddr)
+	}
+	if *grpcAddr != "" {
+		return serveGRPC(*grpcAddr)
+	}
+	if !*lsp {
+		fmt.Fprintln(os.Stderr, "serve: choose a protocol, e.g. --lsp, --http :8080 or --grpc :50051")
+		return exitFailure
+	}
+	legend := tokenizer.DefaultSemanticLegend
+	if *legendPath != "" {
+		var err error
+		if legend, err = loadSemanticLegend(*legendPath); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitFailure
+		}
+	}
+	return serveLSP(os.Stdin, os.Stdout, legend)
+}
@@ -0,0 +1,674 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"tokenizer"
+	"tokenizer/parser"
+)
+
+// readSource reads a named file, an http(s) URL, or stdin when path is empty or "-".
+// It returns the data along with the name used for output files.
+func readSource(path string) ([]byte, string, error) {
+	if isRemote(path) {
+		data, err := fetchSource(path)
+		return data, path, err
+	}
+	if path != "" && path != "-" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, path, fmt.Errorf("read file error: %w", err)
+		}
+		return data, path, nil
+	}
+	data, err := io.ReadAll(bufio.NewReader(os.Stdin))
+	if err != nil {
+		return nil, "-", fmt.Errorf("read stdin error: %w", err)
+	}
+	return data, "-", nil
+}
+
+// commands maps subcommand names to their entry points. Each receives the
+// arguments after the subcommand name and returns the process exit code.
+var commands = map[string]func(args []string) int{
+	"bpe":         runBPE,
+	"check":       runCheck,
+	"clones":      runClones,
+	"compare":     runCompare,
+	"complexity":  runComplexity,
+	"decode":      runDecode,
+	"diff":        runDiff,
+	"docs":        runDocs,
+	"fmt":         runFmt,
+	"grep":        runGrep,
+	"highlight":   runHighlight,
+	"lint":        runLint,
+	"metrics":     runMetrics,
+	"minify":      runMinify,
+	"ngrams":      runNgrams,
+	"serve":       runServe,
+	"spec":        runSpec,
+	"stats":       runStats,
+	"symbols":     runSymbols,
+	"todos":       runTodos,
+	"train-vocab": runTrainVocab,
+	"unlex":       runUnlex,
+}
+
+// tokenizeDocument lexes src, and parses it too if parse is set, into a
+// compact JSON TokenDocument. It fails when ctx is cancelled while lexing.
+func tokenizeDocument(ctx context.Context, src string, parse bool) ([]byte, error) {
+	toks, errs, err := tokenizer.NewLexer(src).LexAllContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	doc := tokenizer.TokenDocument{Tokens: toks, Errors: errs}
+	if parse {
+		file, syntaxErrs := parser.New(toks).ParseFile()
+		ast, err := parser.MarshalAST(file)
+		if err != nil {
+			return nil, err
+		}
+		doc.AST = ast
+		doc.Errors = append(doc.Errors, syntaxErrs...)
+	}
+	return json.Marshal(doc)
+}
+
+// outputFileName returns the path, relative to --out-dir, of the JSON
+// output for the input named name. It mirrors the input's path, so
+// a/main.jl and b/main.jl do not collide: a/main.jl -> a/main.json. Absolute
+// paths and paths above the working directory keep only the part below the
+// root or the last "..", and a URL maps to host/path.
+func outputFileName(name string) string {
+	if name == "" || name == "-" {
+		return "stdin.json"
+	}
+	if u, err := url.Parse(name); err == nil && isRemote(name) {
+		name = u.Host + "/" + u.Path
+	} else if filepath.IsAbs(name) {
+		if wd, err := os.Getwd(); err == nil {
+			if rel, err := filepath.Rel(wd, name); err == nil {
+				name = rel
+			}
+		}
+	}
+	name = filepath.Clean(name)
+	name = filepath.ToSlash(strings.TrimPrefix(name, filepath.VolumeName(name)))
+	for strings.HasPrefix(name, "../") {
+		name = name[3:]
+	}
+	name = strings.TrimLeft(name, "/")
+	return filepath.FromSlash(strings.TrimSuffix(name, path.Ext(name)) + ".json")
+}
+
+// Exit statuses of the main command.
+const (
+	exitClean   = 0 // no errors
+	exitErrors  = 1 // the input has lexical (or, with --parse, syntax) errors
+	exitFailure = 2 // bad usage, unreadable input or unwritable output
+)
+
+func main() {
+	if platformMain() {
+		return
+	}
+	if len(os.Args) > 1 {
+		if cmd, ok := commands[os.Args[1]]; ok {
+			os.Exit(cmd(os.Args[2:]))
+		}
+	}
+
+	var o cliOptions
+	flag.StringVar(&o.format, "format", "json", "stdout format: json, table, lsp-semantic, gcc (file:line:col: error: message lines) or ids (a JSON array of token ids)")
+	legendPath := flag.String("legend", "", "JSON semantic-token legend for --format lsp-semantic")
+	vocabPath := flag.String("vocab", "", "lexical vocabulary (see `tokenizer train-vocab`) giving names and literals their own ids in --format ids")
+	flag.Var(&o.color, "color", "print the source with ANSI highlighting instead (auto, always or never)")
+	repl := flag.Bool("repl", false, "tokenize stdin interactively, one line or block at a time")
+	flag.BoolVar(&o.parse, "parse", false, "also parse the tokens and include the AST in the JSON output")
+	flag.BoolVar(&o.mmap, "mmap", false, "memory-map the input file instead of reading it into memory")
+	flag.BoolVar(&o.verify, "verify", false, "check that the tokens and comments rebuild the input byte-for-byte")
+	flag.BoolVar(&o.trivia, "trivia", false, "also emit comments, whitespace and rejected input as tokens, so `tokenizer unlex` can rebuild the source")
+	flag.BoolVar(&o.anonymize, "anonymize", false, "rename identifiers to id1, id2, ... and replace the text of strings and comments, keeping positions")
+	flag.BoolVar(&o.matchBrackets, "match-brackets", false, "add to each matched bracket token the index of its partner as \"match\"")
+	flag.BoolVar(&o.checkBrackets, "check-brackets", false, "also report (), {} and [] that are unbalanced or badly nested")
+	flag.StringVar(&o.fixPunct, "fix-unicode-punct", "", "lex smart quotes and dashes the lexer rejects as their ASCII equivalents, with a warning each (warn), or also rewrite the file with them (write)")
+	flag.BoolVar(&o.checkSpans, "check-spans", false, "check that each token's offsets slice its lexeme out of the input (a debugging aid)")
+	flag.StringVar(&o.sourceMap, "sourcemap", "", "also write a token → source position map as JSON to this file")
+	columns := flag.String("columns", "runes", "unit of token and error columns: bytes, runes or utf16")
+	flag.IntVar(&o.tabWidth, "tab-width", 0, "count a tab in columns as reaching the next multiple of this many columns (0 counts it as one)")
+	flag.StringVar(&o.diagStyle, "diagnostics", diagPretty, "how errors are printed to stderr: pretty, short or json")
+	flag.IntVar(&o.limits.MaxInput, "max-input-bytes", 0, "lex at most this many bytes of each input and report the rest (0 means no limit)")
+	flag.IntVar(&o.limits.MaxLexeme, "max-token-bytes", 0, "keep at most this many bytes of a token's text, reporting longer tokens (0 means no limit)")
+	flag.IntVar(&o.limits.MaxTokens, "max-tokens", 0, "stop lexing an input after this many tokens, reporting it (0 means no limit)")
+	flag.IntVar(&o.maxErrors, "max-errors", 0, "report at most this many errors and summarize the rest (0 means no limit)")
+	suppress := flag.String("suppress", "", "comma-separated error codes to silence, e.g. E0007,E0100")
+	verbose := flag.Bool("verbose", false, "log each input's size, token and error counts and timing to stderr")
+	logLevel := flag.String("log-level", "", "log records of at least this level, implying --verbose: debug (also each error recovered from), info, warn (only inputs with errors) or error")
+	logFormat := flag.String("log-format", "text", "format of the --verbose log: text or json lines")
+	progress := flag.Bool("progress", false, "show how far the lexing of a large input has come on stderr")
+	cpuProfile := flag.String("cpuprofile", "", "write a CPU profile of the run to this file, for go tool pprof")
+	memProfile := flag.String("memprofile", "", "write a heap allocation profile of the run to this file, for go tool pprof")
+	traceOut := flag.String("trace", "", "write an execution trace of the run to this file, for go tool trace")
+	timings := flag.Bool("timings", false, "report the wall time, tokens and MB per second and heap allocations of lexing each input, and in total, on stderr (a JSON object with --format json)")
+	exitZero := flag.Bool("exit-zero", false, "exit with status 0 even when the input has errors")
+	flag.BoolVar(&o.quiet, "quiet", false, "print nothing to stdout and no progress notes to stderr; errors are still reported")
+	flag.StringVar(&o.outPath, "o", "", "also write the JSON output to this file (- for stdout, replacing the --format output)")
+	flag.StringVar(&o.outDir, "out-dir", "", "write the JSON output of each input to this directory, mirroring the input paths with a .json extension")
+	archive := flag.String("archive", "", "tokenize the matching files inside this .zip, .tar, .tar.gz or .tgz archive")
+	ext := flag.String("ext", ".jl", "with --archive, --git-staged or --git-diff, only tokenize files with this extension (empty for all)")
+	gitStaged := flag.Bool("git-staged", false, "tokenize the files staged in git (as staged), e.g. from a pre-commit hook")
+	gitDiff := flag.String("git-diff", "", "tokenize the files changed in the working tree since this git ref")
+	only := flag.String("only", "", "comma-separated token types to emit, e.g. IDENT,STRING_LIT (COMMENT adds comments)")
+	exclude := flag.String("exclude", "", "comma-separated token types to leave out, e.g. SEMI")
+	contextual := flag.String("contextual", "", "comma-separated keywords that may also be used as names, e.g. type,range,select")
+	flag.BoolVar(&o.continuation, "line-continuation", false, "treat a \\ right before a line break as a line continuation")
+	flag.StringVar(&o.stdinName, "stdin-name", "", "file name to report for input read from stdin, e.g. src/main.jl")
+	flag.BoolVar(&o.compact, "compact", false, "write JSON output without whitespace, for machine consumers")
+	flag.BoolVar(&o.preprocess, "preprocess", false, "run the preprocessor: expand #include \"file\" directives and #define macros")
+	flag.Var((*listFlag)(&o.includePath), "I", "directory searched for included files after the including file's own (repeatable)")
+	flag.Var((*listFlag)(&o.defines), "D", "with --preprocess, define the macro NAME, or NAME=value (repeatable)")
+	flag.BoolVar(&o.checkInactive, "check-inactive", false, "with --preprocess, also report lexical errors in branches #if leaves out")
+	flag.BoolVar(&o.concat, "concat", false, "lex all inputs into one stream, each delimited by FILE_BEGIN and FILE_END tokens")
+	pretty := flag.Bool("pretty", false, "write JSON output indented by 2 spaces (the default)")
+	specPath := flag.String("spec", "", "tokenize another language described by this JSON or YAML lexer spec (see `tokenizer spec`)")
+	normalize := flag.Bool("normalize-names", true, "give names not written in Unicode NFC their NFC form as \"value\", so both spellings are one name")
+	flag.BoolVar(&o.verbosePos, "verbose-positions", false, "add each token's column in bytes, runes and UTF-16 units as \"cols\"")
+	flag.Parse()
+	o.rawNames = !*normalize
+
+	if *repl {
+		runREPL(os.Stdin, os.Stdout)
+		return
+	}
+
+	usage := func(format string, args ...interface{}) {
+		fmt.Fprintf(os.Stderr, format+"\n", args...)
+		os.Exit(exitFailure)
+	}
+	paths := flag.Args()
+	gitMode := *gitStaged || *gitDiff != ""
+	if len(paths) == 0 && *archive == "" && !gitMode {
+		paths = []string{"-"}
+	}
+	var err error
+	if o.unit, err = tokenizer.ParseColumnUnit(*columns); err != nil {
+		usage("%v", err)
+	}
+	switch {
+	case o.color == "" && o.format != "json" && o.format != "table" && o.format != "lsp-semantic" && o.format != "gcc" && o.format != "ids":
+		usage("unknown format %q", o.format)
+	case o.diagStyle != diagPretty && o.diagStyle != diagShort && o.diagStyle != diagJSON:
+		usage("unknown diagnostics style %q (want pretty, short or json)", o.diagStyle)
+	case o.fixPunct != "" && o.fixPunct != fixPunctWarn && o.fixPunct != fixPunctWrite:
+		usage("unknown --fix-unicode-punct mode %q (want warn or write)", o.fixPunct)
+	case o.mmap && o.format != "json" && o.format != "table":
+		usage("--mmap writes span tokens: use --format json or table")
+	case o.mmap && (o.color != "" || o.parse || o.verify || o.checkSpans || o.checkBrackets || o.matchBrackets || o.trivia || o.anonymize ||
+		o.sourceMap != "" || o.fixPunct != "" || o.preprocess || o.concat || o.continuation || o.verbosePos || *progress || *specPath != "" ||
+		*contextual != "" || *columns != string(tokenizer.ColumnRunes) || o.tabWidth != 0 || o.limits != (tokenizer.Limits{}) || !*normalize):
+		usage("--mmap lexes span tokens, which carry only type and position: it cannot be combined with --color, --parse, --verify, " +
+			"--check-spans, --check-brackets, --match-brackets, --trivia, --anonymize, --sourcemap, --fix-unicode-punct, --preprocess, " +
+			"--concat, --line-continuation, --verbose-positions, --progress, --spec, --contextual, --columns, --tab-width, " +
+			"--max-input-bytes, --max-token-bytes, --max-tokens or --normalize-names=false")
+	case o.fixPunct == fixPunctWrite && (*archive != "" || gitMode):
+		usage("--fix-unicode-punct write cannot be combined with --archive, --git-staged or --git-diff")
+	case o.limits.MaxInput < 0 || o.limits.MaxLexeme < 0 || o.limits.MaxTokens < 0:
+		usage("--max-input-bytes, --max-token-bytes and --max-tokens cannot be negative")
+	case *progress && o.quiet:
+		usage("--progress cannot be combined with --quiet")
+	case o.outPath != "" && o.outDir != "":
+		usage("-o and --out-dir cannot be combined")
+	case o.compact && *pretty:
+		usage("--compact and --pretty cannot be combined")
+	case *gitStaged && *gitDiff != "":
+		usage("--git-staged and --git-diff cannot be combined")
+	case o.anonymize && (o.color != "" || o.concat):
+		usage("--anonymize cannot be combined with --color or --concat")
+	case o.trivia && (o.preprocess || o.concat):
+		usage("--trivia cannot be combined with --preprocess or --concat")
+	case o.preprocess && (o.concat || o.color != "" || o.format == "lsp-semantic"):
+		usage("--preprocess cannot be combined with --concat, --color or --format lsp-semantic")
+	case o.concat && (o.parse || o.color != "" || o.format == "lsp-semantic" || o.outDir != "" || o.sourceMap != ""):
+		usage("--concat cannot be combined with --parse, --color, --format lsp-semantic, --out-dir or --sourcemap")
+	case !o.concat && (len(paths) > 1 || *archive != "" || gitMode) && o.outPath != "" && o.outPath != "-":
+		usage("-o names a single file; use --out-dir for several inputs")
+	case (len(paths) > 1 || *archive != "" || gitMode) && o.sourceMap != "":
+		usage("--sourcemap takes a single input")
+	case o.format == "ids" && *specPath != "":
+		usage("--format ids cannot be combined with --spec")
+	case *vocabPath != "" && o.format != "ids":
+		usage("--vocab requires --format ids")
+	}
+	if *suppress != "" {
+		o.suppress = strings.Split(*suppress, ",")
+	}
+	o.only, o.exclude = splitList(*only), splitList(*exclude)
+	o.contextual = splitList(*contextual)
+	if err := tokenizer.NewLexer("").SetContextualKeywords(o.contextual...); err != nil {
+		usage("--contextual: %v", err)
+	}
+	if *specPath != "" {
+		if o.parse {
+			usage("--parse cannot be combined with --spec")
+		}
+		if o.spec, err = tokenizer.LoadLexSpec(*specPath); err == nil {
+			err = tokenizer.NewLexer("").SetSpec(o.spec)
+		}
+		if err != nil {
+			usage("--spec: %v", err)
+		}
+	}
+	o.legend = tokenizer.DefaultSemanticLegend
+	if *legendPath != "" {
+		if o.legend, err = loadSemanticLegend(*legendPath); err != nil {
+			usage("%v", err)
+		}
+	}
+	if *vocabPath != "" {
+		if o.vocab, err = tokenizer.LoadVocab(*vocabPath); err == nil && o.vocab.Kind != tokenizer.VocabLexical {
+			err = fmt.Errorf("%s is a %s vocabulary; --format ids needs a lexical one", *vocabPath, o.vocab.Kind)
+		}
+		if err != nil {
+			usage("--vocab: %v", err)
+		}
+	}
+	if o.outDir != "" {
+		if err := os.MkdirAll(o.outDir, 0755); err != nil {
+			usage("create output directory error: %v", err)
+		}
+	}
+	if *progress {
+		o.progress = newProgressBar()
+	}
+	if *timings {
+		o.timings = &timingReport{}
+		// json is also the default format; only asking for it gets JSON
+		flag.Visit(func(f *flag.Flag) {
+			if f.Name == "format" && o.format == "json" {
+				o.timings.asJSON = true
+			}
+		})
+	}
+	if *verbose && *logLevel == "" {
+		*logLevel = "info"
+	}
+	if o.log, err = newLogger(os.Stderr, *logLevel, *logFormat); err != nil {
+		usage("%v", err)
+	}
+	stopProfiles, err := startProfiles(*cpuProfile, *memProfile, *traceOut)
+	if err != nil {
+		usage("%v", err)
+	}
+
+	status := exitClean
+	for _, path := range paths {
+		if st := runFile(path, &o); st > status {
+			status = st
+		}
+	}
+	if *archive != "" {
+		err := walkArchive(*archive, *ext, func(name string, data []byte) {
+			// reported as archive.zip/dir/file.jl, which still reads as a path
+			if st := runSource(string(data), filepath.Join(*archive, path.Clean(name)), &o); st > status {
+				status = st
+			}
+		})
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			status = exitFailure
+		}
+	}
+	if gitMode {
+		if st := runGitChanged(*gitDiff, *ext, &o); st > status {
+			status = st
+		}
+	}
+	if o.concat {
+		if st := runConcat(&o); st > status {
+			status = st
+		}
+	}
+	if err := stopProfiles(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		status = exitFailure
+	}
+	if o.timings != nil {
+		if err := o.timings.write(os.Stderr); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			status = exitFailure
+		}
+	}
+	if status == exitErrors && *exitZero {
+		status = exitClean
+	}
+	os.Exit(status)
+}
+
+// cliOptions holds the main command's flags after validation.
+type cliOptions struct {
+	format        string
+	legend        tokenizer.SemanticLegend
+	vocab         *tokenizer.Vocab // for --format ids
+	color         colorFlag
+	parse         bool
+	mmap          bool
+	verify        bool
+	checkSpans    bool
+	checkBrackets bool
+	matchBrackets bool // set Token.Match
+	trivia        bool // emit a lossless stream (WithTrivia)
+	anonymize     bool // rename identifiers and blank out strings and comments
+	sourceMap     string
+	unit          tokenizer.ColumnUnit
+	tabWidth      int
+	verbosePos    bool
+	diagStyle     string
+	maxErrors     int
+	limits        tokenizer.Limits
+	progress      *progressBar  // --progress
+	timings       *timingReport // --timings
+	log           *slog.Logger  // --verbose; discards everything without it
+	suppress      []string
+	quiet         bool
+	outPath       string // -o: a file, or "-" for stdout
+	outDir        string
+	stdinName     string // reported instead of "-" for stdin
+	only          []string
+	exclude       []string
+	contextual    []string
+	continuation  bool // `\` before a line break continues the line
+	spec          *tokenizer.LexSpec
+	compact       bool // JSON without whitespace
+	concat        bool // gather the inputs in sources for one report
+	preprocess    bool // expand #include directives
+	includePath   []string
+	defines       []string // -D NAME or NAME=value
+	checkInactive bool
+	rawNames      bool               // --normalize-names=false
+	fixPunct      string             // --fix-unicode-punct: "", fixPunctWarn or fixPunctWrite
+	sources       []tokenizer.Source // with concat, in command-line order
+}
+
+// runFile tokenizes one input named on the command line, prints it in the
+// selected format and writes the requested output file. It returns the
+// input's exit status.
+func runFile(path string, o *cliOptions) int {
+	if o.mmap && path != "" && path != "-" && !isRemote(path) {
+		return runMapped(path, o)
+	}
+	if o.fixPunct == fixPunctWrite && (path == "" || path == "-" || isRemote(path)) {
+		fmt.Fprintln(os.Stderr, "--fix-unicode-punct write needs a file to rewrite, not stdin or a URL")
+		return exitFailure
+	}
+	data, name, err := readSource(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitFailure
+	}
+	src, srcPath := string(data), name
+	if name == "-" && o.stdinName != "" {
+		srcPath = o.stdinName
+	}
+	return runSource(src, srcPath, o)
+}
+
+// newLexer returns a lexer for src configured by the command-line options.
+func (o *cliOptions) newLexer(src string) *tokenizer.Lexer {
+	return tokenizer.NewLexer(src, o.lexOptions()...)
+}
+
+// lexOptions returns the lexer options the command-line options select.
+func (o *cliOptions) lexOptions() []tokenizer.Option {
+	opts := []tokenizer.Option{
+		tokenizer.WithColumnUnit(o.unit),
+		tokenizer.WithMaxErrors(o.maxErrors),
+		tokenizer.WithSuppressed(o.suppress...),
+		tokenizer.WithContextualKeywords(o.contextual...),
+		tokenizer.WithTabWidth(o.tabWidth),
+		tokenizer.WithNameNormalization(!o.rawNames),
+		tokenizer.WithLimits(o.limits),
+	}
+	if o.progress != nil {
+		opts = append(opts, tokenizer.WithProgress(o.progress.update))
+	}
+	if o.verbosePos {
+		opts = append(opts, tokenizer.WithVerbosePositions())
+	}
+	if o.continuation {
+		opts = append(opts, tokenizer.WithLineContinuation())
+	}
+	if o.spec != nil {
+		opts = append(opts, tokenizer.WithDialect(o.spec))
+	}
+	return opts
+}
+
+// writeOutputFile writes the JSON report for srcPath where -o or --out-dir
+// asks for it.
+func writeOutputFile(out *tokenizer.TokenDocument, srcPath string, o *cliOptions) int {
+	outPath := o.outPath
+	if o.outDir != "" {
+		outPath = filepath.Join(o.outDir, outputFileName(srcPath))
+		if err := os.MkdirAll(filepath.Dir(outPath), 0755); err != nil {
+			fmt.Fprintf(os.Stderr, "create output directory error: %v\n", err)
+			return exitFailure
+		}
+	}
+	switch outPath {
+	case "":
+	case "-":
+		if err := writeDocumentLine(os.Stdout, out, o.compact); err != nil {
+			fmt.Fprintf(os.Stderr, "write json error: %v\n", err)
+			return exitFailure
+		}
+	default:
+		if err := writeDocumentFile(outPath, out, o.compact); err != nil {
+			fmt.Fprintf(os.Stderr, "write output file error: %v\n", err)
+			return exitFailure
+		}
+		if !o.quiet {
+			fmt.Fprintf(os.Stderr, "wrote %s\n", outPath)
+		}
+	}
+	return exitClean
+}
+
+// runSource is runFile for source already in memory; name is used in
+// diagnostics and output file names.
+func runSource(src, srcPath string, o *cliOptions) int {
+	var err error
+	if o.progress != nil {
+		o.progress.begin(srcPath)
+	}
+	if o.fixPunct != "" {
+		if src, err = fixPunct(src, srcPath, o); err != nil {
+			fmt.Fprintf(os.Stderr, "fix unicode punctuation: %v\n", err)
+			return exitFailure
+		}
+	}
+	if o.concat {
+		o.sources = append(o.sources, tokenizer.Source{Name: srcPath, Text: src})
+		return exitClean
+	}
+	lx := o.newLexer(src)
+	timer := startTimer(o.timings != nil)
+	toks, errs := lx.LexAll()
+	tm := timer.stop(srcPath, len(src), len(toks))
+	if o.timings != nil {
+		o.timings.add(tm)
+	}
+	logInput(o.log, srcPath, len(src), len(toks), len(errs), tm.Elapsed)
+	logRecoveries(o.log, srcPath, lx.Diagnostics())
+	if o.verify {
+		if err := tokenizer.VerifyRoundTrip(src, toks, lx.Comments(), lx.Diagnostics(), lx.Continuations()); err != nil {
+			fmt.Fprintf(os.Stderr, "verify failed: %s: %v\n", srcPath, err)
+			return exitFailure
+		}
+	}
+	if o.checkSpans {
+		if err := tokenizer.VerifySpans(src, toks, lx.Comments()); err != nil {
+			fmt.Fprintf(os.Stderr, "span check failed: %s: %v\n", srcPath, err)
+			return exitFailure
+		}
+	}
+	if o.sourceMap != "" {
+		if err := tokenizer.NewSourceMap(srcPath, src, toks).WriteFile(o.sourceMap); err != nil {
+			fmt.Fprintf(os.Stderr, "write source map error: %v\n", err)
+			return exitFailure
+		}
+		if !o.quiet {
+			fmt.Fprintf(os.Stderr, "wrote %s\n", o.sourceMap)
+		}
+	}
+
+	files := []tokenizer.Source{{Name: srcPath, Text: src}}
+	diags := lx.Diagnostics()
+	if o.preprocess {
+		pp := tokenizer.NewPreprocessor(o.includePath, o.newLexer)
+		pp.Suppress(o.suppress...)
+		pp.SetCheckInactive(o.checkInactive)
+		for _, def := range o.defines {
+			name, value, ok := strings.Cut(def, "=")
+			if !ok {
+				value = "1"
+			}
+			if err := pp.Define(name, value); err != nil {
+				fmt.Fprintf(os.Stderr, "-D %s: %v\n", def, err)
+				return exitFailure
+			}
+		}
+		toks = pp.Expand(srcPath, src, toks)
+		files = pp.Files()
+		var kept []tokenizer.Diagnostic
+		var keptErrs []string
+		for i, d := range diags {
+			if !pp.Skipped(d) {
+				kept, keptErrs = append(kept, d), append(keptErrs, errs[i])
+			}
+		}
+		diags = append(kept, pp.Diagnostics()...)
+		errs = append(keptErrs, pp.Errors()...)
+	}
+	if o.checkBrackets {
+		for _, d := range tokenizer.CheckBrackets(toks) {
+			if !slices.Contains(o.suppress, d.Code) {
+				diags, errs = append(diags, d), append(errs, d.String())
+			}
+		}
+	}
+	comments := lx.Comments()
+	if o.anonymize {
+		an := tokenizer.NewAnonymizer()
+		toks, comments = an.Tokens(toks), an.Tokens(comments)
+	}
+	out := tokenizer.TokenDocument{Tokens: toks, Errors: errs}
+	for _, f := range files {
+		out.Files = append(out.Files, f.Name)
+	}
+	if o.parse {
+		p := parser.New(toks)
+		p.Suppress(o.suppress...)
+		start := time.Now()
+		file, syntaxErrs := p.ParseFile()
+		level := slog.LevelInfo
+		if len(syntaxErrs) > 0 {
+			level = slog.LevelWarn
+		}
+		o.log.Log(context.Background(), level, "parsed", "file", srcPath, "errors", len(syntaxErrs), "elapsed", time.Since(start))
+		logRecoveries(o.log, srcPath, p.Diagnostics())
+		if out.AST, err = parser.MarshalAST(file); err != nil {
+			fmt.Fprintf(os.Stderr, "marshal ast error: %v\n", err)
+			return exitFailure
+		}
+		errs = append(errs, syntaxErrs...)
+		out.Errors = errs
+		diags = append(diags, p.Diagnostics()...)
+	}
+	omitted := lx.OmittedErrors()
+	if o.maxErrors > 0 && len(diags) > o.maxErrors {
+		// the lexer stopped at the limit; syntax errors share what is left of it
+		omitted += len(diags) - o.maxErrors
+		diags, errs = diags[:o.maxErrors], errs[:o.maxErrors]
+		out.Errors = errs
+	}
+	if o.format != "gcc" { // there stdout already lists them
+		writeFileDiagnostics(os.Stderr, files, diags, o.diagStyle, o.color != "never" && useColor(colorModeAuto, os.Stderr))
+	}
+	if omitted > 0 && o.diagStyle != diagJSON {
+		fmt.Fprintf(os.Stderr, "%d more errors not shown (--max-errors %d)\n", omitted, o.maxErrors)
+	}
+
+	emitted, semantic := toks, tokenizer.MergeTrivia(toks, comments)
+	if o.trivia {
+		emitted = tokenizer.WithTrivia(src, toks, comments, o.unit)
+		out.Tokens = emitted
+	}
+	if keep := typeFilter(o.only, o.exclude); keep != nil {
+		if wantsTrivia(o.only) && !o.trivia {
+			emitted = semantic
+		}
+		emitted, semantic = tokenizer.Filter(emitted, keep), tokenizer.Filter(semantic, keep)
+		out.Tokens = emitted
+	}
+	if o.matchBrackets {
+		tokenizer.MatchBrackets(emitted)
+	}
+	var stdout io.Writer = os.Stdout
+	if o.quiet || o.outPath == "-" {
+		stdout = io.Discard
+	}
+	switch {
+	case o.color != "":
+		writeColored(stdout, src, toks, lx.Comments(), lx.Diagnostics(), useColor(o.color, os.Stdout))
+	case o.format == "json":
+		if err := writeDocumentLine(stdout, &out, o.compact); err != nil {
+			fmt.Fprintf(os.Stderr, "write json error: %v\n", err)
+			return exitFailure
+		}
+	case o.format == "table":
+		writeTable(stdout, emitted, errs)
+	case o.format == "gcc":
+		writeFileGCC(stdout, files, diags)
+	case o.format == "lsp-semantic":
+		sem := struct {
+			Legend tokenizer.SemanticLegend `json:"legend"`
+			Data   []uint32                 `json:"data"`
+		}{o.legend, tokenizer.EncodeSemanticTokens(src, semantic, o.legend)}
+		semBytes, err := json.Marshal(sem)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "marshal json error: %v\n", err)
+			return exitFailure
+		}
+		stdout.Write(semBytes)
+		stdout.Write([]byte("\n"))
+	case o.format == "ids":
+		ids, err := json.Marshal(tokenizer.TokenIDs(emitted, o.vocab))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "marshal json error: %v\n", err)
+			return exitFailure
+		}
+		stdout.Write(append(ids, '\n'))
+	}
+
+	if st := writeOutputFile(&out, srcPath, o); st != exitClean {
+		return st
+	}
+	if len(errs) > 0 || omitted > 0 {
+		return exitErrors
+	}
+	return exitClean
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"tokenizer"
+)
+
+func TestFormatBraces(t *testing.T) {
+	tests := []struct{ src, want string }{
+		{"def f() { ret 1 }", "def f() {\n\tret 1\n}\n"},
+		{"def f() {\n\tif x { a() } }", "def f() {\n\tif x {\n\t\ta()\n\t}\n}\n"},
+		{"if x { a() } else { b() } // c", "if x {\n\ta()\n} else {\n\tb()\n} // c\n"},
+		{"def f() {} // empty", "def f() {} // empty\n"},
+		{"xs := []i32{1, 2}", "xs := []i32{1, 2}\n"},
+		{"h := def() { ret 1 }()", "h := def () {\n\tret 1\n}()\n"},
+	}
+	for _, tt := range tests {
+		lx := tokenizer.NewLexer(tt.src)
+		toks, errs := lx.LexAll()
+		if len(errs) > 0 {
+			t.Fatalf("%q: %v", tt.src, errs)
+		}
+		got := formatSource(toks, lx.Comments())
+		if got != tt.want {
+			t.Errorf("%q: got %q, want %q", tt.src, got, tt.want)
+		}
+		lx = tokenizer.NewLexer(got)
+		toks, _ = lx.LexAll()
+		if again := formatSource(toks, lx.Comments()); again != got {
+			t.Errorf("%q: not stable: %q", tt.src, again)
+		}
+	}
+}
+
+func TestFmtKeepsMode(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "x.jl")
+	if err := os.WriteFile(path, []byte("def f() { ret 1 }\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if status := runFmt([]string{"-w", path}); status != 0 {
+		t.Fatalf("fmt -w: status %d", status)
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm() != 0600 {
+		t.Errorf("mode %v after fmt -w, want 0600", fi.Mode().Perm())
+	}
+}
+
+func TestGitRefOption(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "out")
+	if _, err := gitChangedFiles("--output="+out, ""); err == nil || !strings.Contains(err.Error(), "invalid git ref") {
+		t.Errorf("got %v, want an invalid ref error", err)
+	}
+	if _, err := os.Stat(out); err == nil {
+		t.Errorf("git wrote %s", out)
+	}
+}
+
+func TestLSPFraming(t *testing.T) {
+	frame := func(body string) string {
+		return fmt.Sprintf("Content-Length: %d\r\n\r\n%s", len(body), body)
+	}
+	for _, hdr := range []string{"Content-Length: -1\r\n\r\n", "Content-Length: 1099511627776\r\n\r\n"} {
+		var out bytes.Buffer
+		if status := serveLSP(strings.NewReader(hdr), &out, tokenizer.DefaultSemanticLegend); status != exitFailure {
+			t.Errorf("%q: status %d, want %d", hdr, status, exitFailure)
+		}
+	}
+
+	in := frame("{not json") +
+		frame(`{"jsonrpc":"2.0","id":1,"method":"shutdown"}`) +
+		frame(`{"jsonrpc":"2.0","method":"exit"}`)
+	var out bytes.Buffer
+	if status := serveLSP(strings.NewReader(in), &out, tokenizer.DefaultSemanticLegend); status != 0 {
+		t.Fatalf("status %d, want 0; output %q", status, out.String())
+	}
+	got := out.String()
+	if !strings.Contains(got, `"id":null,"error":{"code":-32700`) {
+		t.Errorf("no parse error response in %q", got)
+	}
+	if !strings.Contains(got, `"id":1,"result":null`) {
+		t.Errorf("no shutdown response after the parse error in %q", got)
+	}
+}
+
+func TestMmapInput(t *testing.T) {
+	dir := t.TempDir()
+	src, out := filepath.Join(dir, "x.jl"), filepath.Join(dir, "x.json")
+	if err := os.WriteFile(src, []byte("x := \"é\" + 1\ny := [2]\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	o := &cliOptions{mmap: true, format: "json", quiet: true, outPath: out, log: slog.New(slog.DiscardHandler)}
+	if status := runFile(src, o); status != exitClean {
+		t.Fatalf("status %d", status)
+	}
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc tokenizer.TokenDocument
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatal(err)
+	}
+	want, _ := tokenizer.Lex("x := \"é\" + 1\ny := [2]\n")
+	if len(doc.Tokens) != len(want) {
+		t.Fatalf("got %d tokens, want %d", len(doc.Tokens), len(want))
+	}
+	for i, tok := range doc.Tokens {
+		w := want[i]
+		if tok.Type != w.Type || tok.Lexeme != w.Lexeme || tok.Line != w.Line || tok.Column != w.Column || tok.Offset != w.Offset || tok.End != w.End {
+			t.Errorf("token %d: got %+v, want %+v", i, tok, w)
+		}
+	}
+}
+
+func TestConcatTimings(t *testing.T) {
+	o := &cliOptions{
+		concat:  true,
+		quiet:   true,
+		format:  "json",
+		log:     slog.New(slog.DiscardHandler),
+		timings: &timingReport{},
+		sources: []tokenizer.Source{{Name: "a.jl", Text: "x := 1\n"}, {Name: "b.jl", Text: "y := [1, 2]\n"}},
+	}
+	if status := runConcat(o); status != exitClean {
+		t.Fatalf("status %d", status)
+	}
+	files := o.timings.Files
+	if len(files) != 2 || files[0].File != "a.jl" || files[0].Tokens != 3 || files[1].File != "b.jl" || files[1].Tokens != 7 {
+		t.Errorf("got timings %+v, want a.jl with 3 tokens and b.jl with 7", files)
+	}
+}
+
+// grpcFrame frames a TokenizeRequest for id and src.
+func grpcFrame(id, src string) []byte {
+	msg := appendProtoString(appendProtoString(nil, 1, id), 2, src)
+	return append(binary.BigEndian.AppendUint32([]byte{0}, uint32(len(msg))), msg...)
+}
+
+// readGRPCResponse reads a TokenizeResponse and returns its id and the
+// types and lexemes of its tokens and the codes of its diagnostics.
+func readGRPCResponse(t *testing.T, r io.Reader) (id string, toks, codes []string) {
+	t.Helper()
+	msg, err := readGRPCMessage(r)
+	if err != nil {
+		t.Fatalf("read response: %v", err)
+	}
+	err = eachProtoField(msg, func(num, _ int, _ uint64, data []byte) {
+		var a, b string
+		eachProtoField(data, func(num, _ int, _ uint64, data []byte) {
+			switch num {
+			case 1:
+				a = string(data)
+			case 2, 7:
+				b = string(data)
+			}
+		})
+		switch num {
+		case 1:
+			id = string(data)
+		case 2:
+			toks = append(toks, a+" "+b)
+		case 3:
+			codes = append(codes, b)
+		}
+	})
+	if err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	return id, toks, codes
+}
+
+func TestGRPC(t *testing.T) {
+	srv := httptest.NewUnstartedServer(newGRPCHandler())
+	srv.Config.Protocols = new(http.Protocols)
+	srv.Config.Protocols.SetUnencryptedHTTP2(true)
+	srv.Start()
+	defer srv.Close()
+	client := &http.Client{Transport: &http.Transport{Protocols: srv.Config.Protocols}}
+	call := func(method string, body io.Reader) *http.Response {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodPost, srv.URL+method, body)
+		req.Header.Set("Content-Type", "application/grpc")
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+	status := func(resp *http.Response) string {
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		return resp.Trailer.Get("Grpc-Status")
+	}
+
+	resp := call(grpcTokenize, bytes.NewReader(grpcFrame("a.jl", "var x = 1 $")))
+	id, toks, codes := readGRPCResponse(t, resp.Body)
+	if want := []string{"KW_VAR var", "IDENT x", "ASSIGN =", "INT_LIT 1"}; id != "a.jl" || fmt.Sprint(toks) != fmt.Sprint(want) || fmt.Sprint(codes) != "[E0001]" {
+		t.Errorf("Tokenize: got %q %q %q, want a.jl %q [E0001]", id, toks, codes, want)
+	}
+	if st := status(resp); st != "0" {
+		t.Errorf("Tokenize: grpc-status %s, want 0", st)
+	}
+
+	// each request is answered before the next is sent
+	pr, pw := io.Pipe()
+	resp = call(grpcTokenizeStream, pr)
+	for i, src := range []string{"pkg main", "", "ret `raw`"} {
+		pw.Write(grpcFrame(fmt.Sprint(i), src))
+		id, toks, _ := readGRPCResponse(t, resp.Body)
+		if id != fmt.Sprint(i) || len(toks) != len(strings.Fields(src)) {
+			t.Errorf("TokenizeStream %d: got %q %q for %q", i, id, toks, src)
+		}
+	}
+	pw.Close()
+	if st := status(resp); st != "0" {
+		t.Errorf("TokenizeStream: grpc-status %s, want 0", st)
+	}
+
+	for _, c := range []struct {
+		method string
+		body   []byte
+		status string
+	}{
+		{grpcTokenize, nil, "3"},
+		{grpcTokenize, append(grpcFrame("1", "x"), grpcFrame("2", "y")...), "3"},
+		{grpcTokenize, []byte{1, 0, 0, 0, 0}, "12"},
+		{grpcTokenize, []byte{0, 0xff, 0, 0, 0}, "8"},
+		{"/tokenizer.v1.Tokenizer/Parse", grpcFrame("1", "x"), "12"},
+	} {
+		if st := status(call(c.method, bytes.NewReader(c.body))); st != c.status {
+			t.Errorf("%s %x: grpc-status %s, want %s", c.method, c.body, st, c.status)
+		}
+	}
+}
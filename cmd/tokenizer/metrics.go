@@ -0,0 +1,291 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"tokenizer"
+)
+
+// Metrics are size and complexity measures of one or more files, computed
+// from their tokens.
+type Metrics struct {
+	File          string  `json:"file,omitempty"`
+	PhysicalLines int     `json:"physicalLines"`
+	BlankLines    int     `json:"blankLines"`
+	CodeLines     int     `json:"codeLines"`    // lines holding a token
+	CommentLines  int     `json:"commentLines"` // lines holding a comment
+	LogicalLines  int     `json:"logicalLines"` // statements and declaration headers
+	CommentRatio  float64 `json:"commentRatio"` // comment lines / non-blank lines
+
+	DistinctIdentifiers int     `json:"distinctIdentifiers"`
+	AvgIdentifierLength float64 `json:"avgIdentifierLength"` // in characters, over distinct names
+	OperatorDensity     float64 `json:"operatorDensity"`     // operators / tokens
+
+	Halstead Halstead `json:"halstead"`
+}
+
+// Halstead holds Halstead's software science measures. Operands are names,
+// types and literals; operators are keywords, operators and brackets, a
+// bracket pair counting once.
+type Halstead struct {
+	DistinctOperators int     `json:"distinctOperators"` // n1
+	DistinctOperands  int     `json:"distinctOperands"`  // n2
+	Operators         int     `json:"operators"`         // N1
+	Operands          int     `json:"operands"`          // N2
+	Vocabulary        int     `json:"vocabulary"`        // n1 + n2
+	Length            int     `json:"length"`            // N1 + N2
+	Volume            float64 `json:"volume"`            // length * log2(vocabulary)
+	Difficulty        float64 `json:"difficulty"`        // n1/2 * N2/n2
+	Effort            float64 `json:"effort"`            // difficulty * volume
+}
+
+// metricsCounter accumulates the counts Metrics are computed from.
+type metricsCounter struct {
+	m         Metrics
+	tokens    int
+	idents    map[string]bool
+	operators map[string]bool
+	operands  map[string]bool
+}
+
+func newMetricsCounter() *metricsCounter {
+	return &metricsCounter{idents: map[string]bool{}, operators: map[string]bool{}, operands: map[string]bool{}}
+}
+
+// isOperand reports whether t counts as a Halstead operand.
+func isOperand(t tokenizer.Token) bool {
+	switch t.Type {
+	case tokenizer.IDENT, tokenizer.TYPE_NAME, tokenizer.INT_LIT, tokenizer.FLOAT_LIT, tokenizer.STRING_LIT, tokenizer.CHAR_LIT, tokenizer.STRING_SEGMENT:
+		return true
+	}
+	return t.AsIdent
+}
+
+// add accumulates one lexed file.
+func (mc *metricsCounter) add(src string, toks, comments []tokenizer.Token) {
+	lines := splitLines(tokenizer.NormalizeLineBreaks(src))
+	mc.m.PhysicalLines += len(lines)
+	for _, l := range lines {
+		if strings.TrimSpace(l) == "" {
+			mc.m.BlankLines++
+		}
+	}
+	mc.m.CodeLines += countLines(toks)
+	mc.m.CommentLines += countLines(comments)
+	mc.m.LogicalLines += logicalLines(toks)
+
+	for _, t := range toks {
+		switch {
+		case t.Type == tokenizer.DIRECTIVE || t.Type == tokenizer.PRAGMA || t.Type == tokenizer.INTERP_START || t.Type == tokenizer.INTERP_END:
+			continue // not part of the program's expressions
+		case t.Type == tokenizer.RPAREN || t.Type == tokenizer.RBRACK || t.Type == tokenizer.RBRACE:
+			continue // counted with the opening bracket
+		case isOperand(t):
+			mc.m.Halstead.Operands++
+			mc.operands[t.Lexeme] = true
+			if t.Type == tokenizer.IDENT || t.AsIdent {
+				mc.idents[t.Lexeme] = true
+			}
+		default:
+			mc.m.Halstead.Operators++
+			mc.operators[t.Lexeme] = true
+		}
+		mc.tokens++
+	}
+}
+
+// countLines returns how many lines the tokens toks cover.
+func countLines(toks []tokenizer.Token) int {
+	n, last := 0, 0
+	for _, t := range toks {
+		from := t.Line
+		if from <= last {
+			from = last + 1
+		}
+		if end := tokenizer.EndLine(t); end >= from {
+			n += end - from + 1
+			last = end
+		}
+	}
+	return n
+}
+
+// logicalLines counts statements and declaration headers: the runs of
+// tokens separated by ';', by block braces and by line breaks that can end
+// a statement, as the parser sees them.
+func logicalLines(toks []tokenizer.Token) int {
+	n := 0
+	depth := 0 // open parentheses, brackets and literal braces
+	var literal []bool
+	header, open := false, false // a header awaits its '{'; a run is open
+	for i, t := range toks {
+		if t.Type == tokenizer.DIRECTIVE || t.Type == tokenizer.PRAGMA {
+			continue
+		}
+		if open && i > 0 && depth == 0 && tokenizer.LineBreak(toks[i-1], t) && keepsLineBreak(toks[i-1], t) && !continuesLine(toks[i-1]) {
+			open = false
+		}
+		switch t.Type {
+		case tokenizer.SEMI:
+			if depth == 0 {
+				open = false
+				continue
+			}
+		case tokenizer.LBRACE:
+			lit := !header && i > 0 && (toks[i-1].Type == tokenizer.IDENT || toks[i-1].Type == tokenizer.TYPE_NAME || toks[i-1].Type == tokenizer.RBRACK)
+			literal = append(literal, lit)
+			header = false
+			if lit {
+				depth++
+				break
+			}
+			open = false
+			continue
+		case tokenizer.RBRACE:
+			lit := len(literal) > 0 && literal[len(literal)-1]
+			if len(literal) > 0 {
+				literal = literal[:len(literal)-1]
+			}
+			if lit {
+				depth--
+				break
+			}
+			open = false
+			continue
+		case tokenizer.LPAREN, tokenizer.LBRACK:
+			depth++
+		case tokenizer.RPAREN, tokenizer.RBRACK:
+			if depth > 0 {
+				depth--
+			}
+		}
+		if opensHeader(t.Type) {
+			header = true
+		}
+		if !open {
+			n++
+			open = true
+		}
+	}
+	return n
+}
+
+// continuesLine reports whether t, ending a line, leaves its statement
+// unfinished: a binary operator, an assignment or a comma.
+func continuesLine(t tokenizer.Token) bool {
+	if endsOperand(t) || strings.HasPrefix(string(t.Type), "KW_") {
+		return false
+	}
+	switch t.Type {
+	case tokenizer.INC, tokenizer.DEC, tokenizer.TYPE_NAME, tokenizer.ANNOTATION:
+		return false
+	}
+	return true
+}
+
+// result computes the derived measures.
+func (mc *metricsCounter) result() Metrics {
+	m := mc.m
+	if nonBlank := m.PhysicalLines - m.BlankLines; nonBlank > 0 {
+		m.CommentRatio = float64(m.CommentLines) / float64(nonBlank)
+	}
+	m.DistinctIdentifiers = len(mc.idents)
+	if len(mc.idents) > 0 {
+		total := 0
+		for name := range mc.idents {
+			total += utf8.RuneCountInString(name)
+		}
+		m.AvgIdentifierLength = float64(total) / float64(len(mc.idents))
+	}
+	if mc.tokens > 0 {
+		m.OperatorDensity = float64(m.Halstead.Operators) / float64(mc.tokens)
+	}
+	h := &m.Halstead
+	h.DistinctOperators, h.DistinctOperands = len(mc.operators), len(mc.operands)
+	h.Vocabulary = h.DistinctOperators + h.DistinctOperands
+	h.Length = h.Operators + h.Operands
+	if h.Vocabulary > 0 {
+		h.Volume = float64(h.Length) * math.Log2(float64(h.Vocabulary))
+	}
+	if h.DistinctOperands > 0 {
+		h.Difficulty = float64(h.DistinctOperators) / 2 * float64(h.Operands) / float64(h.DistinctOperands)
+	}
+	h.Effort = h.Difficulty * h.Volume
+	return m
+}
+
+func (m Metrics) writeText(w io.Writer) {
+	h := m.Halstead
+	fmt.Fprintf(w, "physical lines:   %d (%d blank, %d code, %d comment)\n", m.PhysicalLines, m.BlankLines, m.CodeLines, m.CommentLines)
+	fmt.Fprintf(w, "logical lines:    %d\n", m.LogicalLines)
+	fmt.Fprintf(w, "comment ratio:    %.1f%%\n", m.CommentRatio*100)
+	fmt.Fprintf(w, "identifiers:      %d distinct, %.1f characters on average\n", m.DistinctIdentifiers, m.AvgIdentifierLength)
+	fmt.Fprintf(w, "operator density: %.1f%%\n", m.OperatorDensity*100)
+	fmt.Fprintf(w, "halstead:         n1=%d n2=%d N1=%d N2=%d volume=%.1f difficulty=%.1f effort=%.1f\n",
+		h.DistinctOperators, h.DistinctOperands, h.Operators, h.Operands, h.Volume, h.Difficulty, h.Effort)
+}
+
+// runMetrics implements `tokenizer metrics [-text] [path ...]`: a JSON
+// report with the metrics of each file and of all of them together.
+func runMetrics(args []string) int {
+	fs := flag.NewFlagSet("metrics", flag.ExitOnError)
+	asText := fs.Bool("text", false, "print a readable report instead of JSON")
+	ext := fs.String("ext", ".jl", "extension of the files read from directories")
+	fs.Parse(args)
+
+	paths := fs.Args()
+	if len(paths) == 0 {
+		paths = []string{"-"}
+	}
+	paths, err := expandPaths(paths, *ext)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitFailure
+	}
+	report := struct {
+		Files []Metrics `json:"files"`
+		Total Metrics   `json:"total"`
+	}{Files: []Metrics{}}
+	total := newMetricsCounter()
+	for _, path := range paths {
+		data, name, err := readSource(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitFailure
+		}
+		lx := tokenizer.NewLexer(string(data))
+		toks, _ := lx.LexAll()
+		mc := newMetricsCounter()
+		mc.add(string(data), toks, lx.Comments())
+		total.add(string(data), toks, lx.Comments())
+		m := mc.result()
+		m.File = name
+		report.Files = append(report.Files, m)
+	}
+	report.Total = total.result()
+
+	if *asText {
+		for _, m := range report.Files {
+			fmt.Printf("%s:\n", m.File)
+			m.writeText(os.Stdout)
+			fmt.Println()
+		}
+		fmt.Println("total:")
+		report.Total.writeText(os.Stdout)
+		return 0
+	}
+	b, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "marshal json error: %v\n", err)
+		return exitFailure
+	}
+	os.Stdout.Write(append(b, '\n'))
+	return 0
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"tokenizer"
+)
+
+// glues reports whether writing b right after a would lex differently from
+// the two tokens, as `+` `+` becomes `++` and `x` `1` becomes `x1`.
+func glues(a, b tokenizer.Token) bool {
+	if a.Type == tokenizer.INTERP_START || b.Type == tokenizer.INTERP_END {
+		return false
+	}
+	// the outer end of an interpolated string is a quote, like the end of ""
+	if a.Type == tokenizer.STRING_SEGMENT {
+		a = tokenizer.Token{Type: tokenizer.STRING_LIT, Lexeme: `""`}
+	}
+	if b.Type == tokenizer.STRING_SEGMENT {
+		b = tokenizer.Token{Type: tokenizer.STRING_LIT, Lexeme: `""`}
+	}
+	toks, errs := tokenizer.NewLexer(a.Lexeme + b.Lexeme).LexAll()
+	return len(errs) > 0 || len(toks) != 2 ||
+		toks[0].Type != a.Type || toks[0].Lexeme != a.Lexeme ||
+		toks[1].Type != b.Type || toks[1].Lexeme != b.Lexeme
+}
+
+// keepsLineBreak reports whether a line break between prev and cur may
+// matter to the parser: it ends statements, and a '(' or '[' on a new line
+// is not a call or index. After an opening bracket, a comma or a ';', and
+// before a closing bracket, it never does.
+func keepsLineBreak(prev, cur tokenizer.Token) bool {
+	switch prev.Type {
+	case tokenizer.LBRACE, tokenizer.LPAREN, tokenizer.LBRACK, tokenizer.COMMA, tokenizer.SEMI:
+		return false
+	}
+	switch cur.Type {
+	case tokenizer.RBRACE, tokenizer.RPAREN, tokenizer.RBRACK:
+		return false
+	}
+	return true
+}
+
+// minifySource rewrites a file from its tokens with the comments dropped
+// and the least whitespace that keeps the same tokens and statements: no
+// space unless two tokens would run together, and a line break only where
+// the input had one that may end a statement. Text the tokens hold, such as
+// strings, is copied as lexed. A shebang is kept.
+func minifySource(toks, comments []tokenizer.Token) string {
+	var b strings.Builder
+	if len(comments) > 0 && comments[0].Type == tokenizer.SHEBANG {
+		b.WriteString(strings.TrimRight(comments[0].Lexeme, "\r"))
+		b.WriteByte('\n')
+	}
+	for i, t := range toks {
+		if i > 0 {
+			prev := toks[i-1]
+			switch {
+			case prev.Type == tokenizer.DIRECTIVE || prev.Type == tokenizer.PRAGMA || t.Type == tokenizer.DIRECTIVE:
+				b.WriteByte('\n') // they take up the rest of their line
+			case tokenizer.LineBreak(prev, t) && keepsLineBreak(prev, t):
+				b.WriteByte('\n')
+			case t.Offset > prev.End && glues(prev, t):
+				b.WriteByte(' ')
+			}
+		}
+		b.WriteString(t.Lexeme)
+	}
+	if b.Len() > 0 {
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// runMinify implements `tokenizer minify [-o file] [file]`.
+func runMinify(args []string) int {
+	fs := flag.NewFlagSet("minify", flag.ExitOnError)
+	outPath := fs.String("o", "", "write the result to this file instead of stdout")
+	fs.Parse(args)
+	if fs.NArg() > 1 {
+		fmt.Fprintln(os.Stderr, "usage: tokenizer minify [-o file] [file]")
+		return exitFailure
+	}
+
+	data, name, err := readSource(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitFailure
+	}
+	lx := tokenizer.NewLexer(string(data))
+	toks, errs := lx.LexAll()
+	if len(errs) > 0 {
+		for _, e := range errs {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", name, e)
+		}
+		return 1
+	}
+	out := minifySource(toks, lx.Comments())
+	if *outPath == "" {
+		os.Stdout.WriteString(out)
+		return 0
+	}
+	if err := os.WriteFile(*outPath, []byte(out), 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "write file error: %v\n", err)
+		return exitFailure
+	}
+	return 0
+}
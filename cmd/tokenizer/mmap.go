@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"tokenizer"
+)
+
+// runMapped is runFile for --mmap: the file is lexed straight from its
+// mapping into span tokens, and the mapping is released once this input's
+// output has been written. Lexemes are sliced from the mapping, never copied.
+func runMapped(path string, o *cliOptions) (status int) {
+	src, release, err := mmapSource(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitFailure
+	}
+	defer func() {
+		if err := release(); err != nil {
+			fmt.Fprintf(os.Stderr, "munmap error: %s: %v\n", path, err)
+			status = exitFailure
+		}
+	}()
+
+	timer := startTimer(o.timings != nil)
+	b := tokenizer.LexSpans(src)
+	defer b.Release()
+	tm := timer.stop(path, len(src), len(b.Tokens))
+	if o.timings != nil {
+		o.timings.add(tm)
+	}
+
+	filter := tokenizer.ErrorFilter{Max: o.maxErrors}
+	filter.Suppress(o.suppress...)
+	var (
+		diags []tokenizer.Diagnostic
+		errs  []string
+	)
+	for _, d := range b.Diagnostics {
+		if filter.Admit(d.Code) {
+			diags, errs = append(diags, d), append(errs, d.String())
+		}
+	}
+	logInput(o.log, path, len(src), len(b.Tokens), len(diags), tm.Elapsed)
+	logRecoveries(o.log, path, diags)
+	files := []tokenizer.Source{{Name: path, Text: src}}
+	writeFileDiagnostics(os.Stderr, files, diags, o.diagStyle, o.color != "never" && useColor(colorModeAuto, os.Stderr))
+	if n := filter.Omitted(); n > 0 && o.diagStyle != diagJSON {
+		fmt.Fprintf(os.Stderr, "%d more errors not shown (--max-errors %d)\n", n, o.maxErrors)
+	}
+
+	toks := make([]tokenizer.Token, len(b.Tokens))
+	for i, t := range b.Tokens {
+		toks[i] = tokenizer.Token{
+			Type: t.Type, Lexeme: t.Lexeme(src),
+			Line: t.Line, Column: t.Column,
+			Offset: t.Offset, End: t.Offset + t.Len,
+		}
+	}
+	if keep := typeFilter(o.only, o.exclude); keep != nil {
+		toks = tokenizer.Filter(toks, keep)
+	}
+	out := tokenizer.TokenDocument{Tokens: toks, Errors: errs, Files: []string{path}}
+	var stdout io.Writer = os.Stdout
+	if o.quiet || o.outPath == "-" {
+		stdout = io.Discard
+	}
+	switch o.format {
+	case "json":
+		if err := writeDocumentLine(stdout, &out, o.compact); err != nil {
+			fmt.Fprintf(os.Stderr, "write json error: %v\n", err)
+			return exitFailure
+		}
+	case "table":
+		writeTable(stdout, toks, errs)
+	}
+	if st := writeOutputFile(&out, path, o); st != exitClean {
+		return st
+	}
+	if len(errs) > 0 || filter.Omitted() > 0 {
+		return exitErrors
+	}
+	return exitClean
+}
@@ -0,0 +1,18 @@
+//go:build !unix
+
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// mmapSource falls back to reading the whole file on platforms without
+// mmap support.
+func mmapSource(path string) (src string, release func() error, err error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", nil, fmt.Errorf("read file error: %w", err)
+	}
+	return string(data), func() error { return nil }, nil
+}
@@ -0,0 +1,38 @@
+//go:build unix
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// mmapSource maps the file at path read-only and returns its contents as a
+// string backed directly by the mapping, so lexemes sliced from it share the
+// file's pages instead of a heap copy. The string must not be used after
+// release is called.
+func mmapSource(path string) (src string, release func() error, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", nil, fmt.Errorf("read file error: %w", err)
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return "", nil, fmt.Errorf("read file error: %w", err)
+	}
+	size := fi.Size()
+	if size == 0 {
+		return "", func() error { return nil }, nil
+	}
+	if int64(int(size)) != size {
+		return "", nil, fmt.Errorf("read file error: %s is too large to map", path)
+	}
+	data, err := syscall.Mmap(int(f.Fd()), 0, int(size), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return "", nil, fmt.Errorf("mmap error: %w", err)
+	}
+	return unsafe.String(&data[0], len(data)), func() error { return syscall.Munmap(data) }, nil
+}
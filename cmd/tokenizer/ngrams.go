@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"tokenizer"
+)
+
+// ngramCounter counts the n-grams of token types, or of types and lexemes,
+// over any number of files. Only the counts are kept, so a corpus is read
+// one file at a time whatever its size; n-grams never span two files.
+type ngramCounter struct {
+	n       int
+	lexemes bool
+	counts  map[string]int // key: the elements joined by ngramSep
+}
+
+// ngramSep separates the elements of a key; no type or lexeme holds it.
+const ngramSep = "\x00"
+
+func newNgramCounter(n int, lexemes bool) *ngramCounter {
+	return &ngramCounter{n: n, lexemes: lexemes, counts: map[string]int{}}
+}
+
+// add counts the n-grams of one file's tokens.
+func (nc *ngramCounter) add(toks []tokenizer.Token) {
+	elems := make([]string, len(toks))
+	for i, t := range toks {
+		elems[i] = string(t.Type)
+		if nc.lexemes {
+			elems[i] += ngramSep + t.Lexeme
+		}
+	}
+	for i := 0; i+nc.n <= len(elems); i++ {
+		nc.counts[strings.Join(elems[i:i+nc.n], ngramSep)]++
+	}
+}
+
+// ngram is one counted n-gram; Lexemes is set when they were counted.
+type ngram struct {
+	Count   int      `json:"count"`
+	Types   []string `json:"types"`
+	Lexemes []string `json:"lexemes,omitempty"`
+}
+
+// sorted returns the n-grams seen at least min times, the most frequent
+// first, ties in key order so the output is stable.
+func (nc *ngramCounter) sorted(min int) []ngram {
+	keys := make([]string, 0, len(nc.counts))
+	for k, c := range nc.counts {
+		if c >= min {
+			keys = append(keys, k)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if ci, cj := nc.counts[keys[i]], nc.counts[keys[j]]; ci != cj {
+			return ci > cj
+		}
+		return keys[i] < keys[j]
+	})
+	out := make([]ngram, len(keys))
+	for i, k := range keys {
+		parts := strings.Split(k, ngramSep)
+		g := ngram{Count: nc.counts[k]}
+		if nc.lexemes {
+			for j := 0; j < len(parts); j += 2 {
+				g.Types = append(g.Types, parts[j])
+				g.Lexemes = append(g.Lexemes, parts[j+1])
+			}
+		} else {
+			g.Types = parts
+		}
+		out[i] = g
+	}
+	return out
+}
+
+// runNgrams implements `tokenizer ngrams [-n N] [-lexemes] [-json] [path ...]`.
+// The default output is TSV: the count, then one column per token, TYPE or
+// TYPE="lexeme" with the lexeme quoted as in Go. -json writes one object per
+// line (JSON Lines).
+func runNgrams(args []string) int {
+	fs := flag.NewFlagSet("ngrams", flag.ExitOnError)
+	n := fs.Int("n", 3, "tokens per n-gram")
+	lexemes := fs.Bool("lexemes", false, "count type and lexeme pairs instead of types alone")
+	min := fs.Int("min", 1, "leave out n-grams seen fewer times than this")
+	comments := fs.Bool("comments", false, "count comments as tokens")
+	asJSON := fs.Bool("json", false, "write JSON Lines instead of TSV")
+	ext := fs.String("ext", ".jl", "extension of the files read from directories")
+	fs.Parse(args)
+
+	if *n < 1 {
+		fmt.Fprintln(os.Stderr, "ngrams: -n must be at least 1")
+		return exitFailure
+	}
+	paths := fs.Args()
+	if len(paths) == 0 {
+		paths = []string{"-"}
+	}
+	paths, err := expandPaths(paths, *ext)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitFailure
+	}
+	nc := newNgramCounter(*n, *lexemes)
+	for _, path := range paths {
+		data, _, err := readSource(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitFailure
+		}
+		lx := tokenizer.NewLexer(string(data))
+		toks, _ := lx.LexAll()
+		if *comments {
+			toks = tokenizer.MergeTrivia(toks, lx.Comments())
+		}
+		nc.add(toks)
+	}
+
+	w := bufio.NewWriter(os.Stdout)
+	enc := json.NewEncoder(w)
+	for _, g := range nc.sorted(*min) {
+		if *asJSON {
+			if err := enc.Encode(g); err != nil {
+				fmt.Fprintf(os.Stderr, "marshal json error: %v\n", err)
+				return exitFailure
+			}
+			continue
+		}
+		w.WriteString(strconv.Itoa(g.Count))
+		for i, tt := range g.Types {
+			w.WriteString("\t" + tt)
+			if g.Lexemes != nil {
+				w.WriteString("=" + strconv.Quote(g.Lexemes[i]))
+			}
+		}
+		w.WriteString("\n")
+	}
+	if err := w.Flush(); err != nil {
+		fmt.Fprintf(os.Stderr, "write error: %v\n", err)
+		return exitFailure
+	}
+	return 0
+}
@@ -0,0 +1,17 @@
+package main
+
+import (
+	"os"
+	"strings"
+)
+
+// listFlag is a flag that may be repeated, such as -I; each use appends
+// its value.
+type listFlag []string
+
+func (f *listFlag) String() string { return strings.Join(*f, string(os.PathListSeparator)) }
+
+func (f *listFlag) Set(v string) error {
+	*f = append(*f, v)
+	return nil
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/pprof"
+	"runtime/trace"
+)
+
+// startProfiles starts the CPU profile and execution trace of
+// --cpuprofile and --trace, writing to the named files, and returns a
+// function that stops them and writes the heap profile of --memprofile.
+// An empty name skips that profile.
+func startProfiles(cpuPath, memPath, tracePath string) (stop func() error, err error) {
+	var cpuFile, traceFile *os.File
+	cleanup := func() {
+		if cpuFile != nil {
+			pprof.StopCPUProfile()
+			cpuFile.Close()
+		}
+		if traceFile != nil {
+			trace.Stop()
+			traceFile.Close()
+		}
+	}
+	if cpuPath != "" {
+		if cpuFile, err = os.Create(cpuPath); err != nil {
+			return nil, fmt.Errorf("cpu profile: %v", err)
+		}
+		if err := pprof.StartCPUProfile(cpuFile); err != nil {
+			cpuFile.Close()
+			return nil, fmt.Errorf("cpu profile: %v", err)
+		}
+	}
+	if tracePath != "" {
+		f, err := os.Create(tracePath)
+		if err == nil {
+			if err = trace.Start(f); err != nil {
+				f.Close()
+			}
+		}
+		if err != nil {
+			cleanup()
+			return nil, fmt.Errorf("trace: %v", err)
+		}
+		traceFile = f
+	}
+	if memPath != "" {
+		// fail now rather than after a long run
+		f, err := os.Create(memPath)
+		if err != nil {
+			cleanup()
+			return nil, fmt.Errorf("memory profile: %v", err)
+		}
+		f.Close()
+	}
+
+	return func() error {
+		var errs []error
+		if cpuFile != nil {
+			pprof.StopCPUProfile()
+			errs = append(errs, cpuFile.Close())
+		}
+		if traceFile != nil {
+			trace.Stop()
+			errs = append(errs, traceFile.Close())
+		}
+		if memPath != "" {
+			errs = append(errs, writeMemProfile(memPath))
+		}
+		for _, err := range errs {
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}, nil
+}
+
+// writeMemProfile writes the allocations made since the program started, as
+// `go test -memprofile` does, after a collection so the in-use figures are
+// up to date.
+func writeMemProfile(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("memory profile: %v", err)
+	}
+	runtime.GC()
+	if err := pprof.Lookup("allocs").WriteTo(f, 0); err != nil {
+		f.Close()
+		return fmt.Errorf("memory profile: %v", err)
+	}
+	return f.Close()
+}
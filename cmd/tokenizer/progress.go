@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"tokenizer"
+)
+
+// progressBar draws --progress on stderr: on a terminal, a bar redrawn in
+// place while an input is lexed and cleared when it is done; otherwise a
+// line every few seconds, so a log shows a long job is still going. Inputs
+// lexed quickly show nothing.
+type progressBar struct {
+	tty   bool
+	name  string
+	start time.Time
+	last  time.Time // of the last draw of this input; zero if none
+}
+
+func newProgressBar() *progressBar {
+	fi, err := os.Stderr.Stat()
+	return &progressBar{tty: err == nil && fi.Mode()&os.ModeCharDevice != 0}
+}
+
+// begin starts the bar for the input name.
+func (b *progressBar) begin(name string) {
+	b.name, b.start, b.last = name, time.Now(), time.Time{}
+}
+
+// update is the progress hook of the lexer.
+func (b *progressBar) update(p tokenizer.Progress) {
+	every := 2 * time.Second
+	if b.tty {
+		every = 100 * time.Millisecond
+	}
+	now := time.Now()
+	done := p.Bytes >= p.Total
+	switch {
+	case done && b.last.IsZero():
+		return
+	case done && b.tty:
+		fmt.Fprint(os.Stderr, "\r\x1b[K")
+		return
+	case !done && now.Sub(b.start) < every, !done && now.Sub(b.last) < every:
+		return
+	}
+	b.last = now
+	pct := 100
+	if p.Total > 0 {
+		pct = int(int64(p.Bytes) * 100 / int64(p.Total))
+	}
+	status := fmt.Sprintf("%3d%% %s of %s, %d tokens", pct, formatSize(p.Bytes), formatSize(p.Total), p.Tokens)
+	if !b.tty {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", b.name, status)
+		return
+	}
+	const width = 30
+	filled := pct * width / 100
+	fmt.Fprintf(os.Stderr, "\r\x1b[K%s [%s%s] %s", b.name, strings.Repeat("#", filled), strings.Repeat(" ", width-filled), status)
+}
+
+// formatSize prints a byte count in B, KB, MB or GB.
+func formatSize(n int) string {
+	const unit = 1000
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	f, suffix := float64(n)/unit, "KB"
+	for _, s := range []string{"MB", "GB"} {
+		if f < unit {
+			break
+		}
+		f, suffix = f/unit, s
+	}
+	return fmt.Sprintf("%.1f %s", f, suffix)
+}
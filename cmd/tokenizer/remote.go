@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Limits on sources fetched from a URL.
+const (
+	maxRemoteSource = 8 << 20
+	remoteTimeout   = 30 * time.Second
+)
+
+var remoteClient = &http.Client{Timeout: remoteTimeout}
+
+// isRemote reports whether path names an http or https URL.
+func isRemote(path string) bool {
+	return strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://")
+}
+
+// fetchSource downloads url, failing on non-200 responses and on bodies
+// larger than maxRemoteSource.
+func fetchSource(url string) ([]byte, error) {
+	resp, err := remoteClient.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("fetch error: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch error: %s: %s", url, resp.Status)
+	}
+	if resp.ContentLength > maxRemoteSource {
+		return nil, fmt.Errorf("fetch error: %s: %d bytes exceeds the %d byte limit", url, resp.ContentLength, maxRemoteSource)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxRemoteSource+1))
+	if err != nil {
+		return nil, fmt.Errorf("fetch error: %s: %w", url, err)
+	}
+	if len(data) > maxRemoteSource {
+		return nil, fmt.Errorf("fetch error: %s: body exceeds the %d byte limit", url, maxRemoteSource)
+	}
+	return data, nil
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"tokenizer"
+)
+
+// needsMore reports whether the input looks like the start of a multi-line block:
+// an open bracket, string interpolation, block comment or raw string.
+func needsMore(toks []tokenizer.Token, diags []tokenizer.Diagnostic) bool {
+	for _, d := range diags {
+		switch d.Message {
+		case "unterminated block comment", "unterminated raw string", "unterminated string interpolation":
+			return true
+		}
+	}
+	depth := 0
+	for _, t := range toks {
+		switch t.Type {
+		case tokenizer.LPAREN, tokenizer.LBRACE, tokenizer.LBRACK:
+			depth++
+		case tokenizer.RPAREN, tokenizer.RBRACE, tokenizer.RBRACK:
+			depth--
+		}
+	}
+	return depth > 0
+}
+
+// runREPL reads source from r one line at a time and prints its tokens and
+// errors to w. A line that opens a bracket, block comment or raw string
+// starts a block which is read until a blank line. Line numbers keep
+// counting across inputs.
+func runREPL(r io.Reader, w io.Writer) {
+	in := bufio.NewScanner(r)
+	line := 1
+	var buf strings.Builder
+	prompt := func() {
+		if buf.Len() == 0 {
+			fmt.Fprint(w, "jl> ")
+		} else {
+			fmt.Fprint(w, "..> ")
+		}
+	}
+	eval := func() {
+		src := buf.String()
+		buf.Reset()
+		lx := tokenizer.NewLexer(src)
+		lx.SetLine(line)
+		toks, errs := lx.LexAll()
+		if len(toks) > 0 || len(errs) > 0 {
+			writeTable(w, toks, errs)
+		}
+		line += tokenizer.CountLineBreaks(src)
+	}
+
+	prompt()
+	for in.Scan() {
+		text := in.Text()
+		if buf.Len() > 0 && strings.TrimSpace(text) == "" {
+			eval()
+			prompt()
+			continue
+		}
+		buf.WriteString(text)
+		buf.WriteByte('\n')
+		lx := tokenizer.NewLexer(buf.String())
+		toks, _ := lx.LexAll()
+		if !needsMore(toks, lx.Diagnostics()) {
+			eval()
+		}
+		prompt()
+	}
+	if buf.Len() > 0 {
+		eval()
+	}
+	fmt.Fprintln(w)
+}
@@ -0,0 +1,22 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"tokenizer"
+)
+
+// loadSemanticLegend reads a SemanticLegend from a JSON file.
+func loadSemanticLegend(path string) (tokenizer.SemanticLegend, error) {
+	var lg tokenizer.SemanticLegend
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return lg, fmt.Errorf("read legend error: %w", err)
+	}
+	if err := json.Unmarshal(data, &lg); err != nil {
+		return lg, fmt.Errorf("parse legend error: %w", err)
+	}
+	return lg, nil
+}
@@ -0,0 +1,17 @@
+package main
+
+import (
+	"flag"
+	"os"
+
+	"tokenizer"
+)
+
+// runSpec implements `tokenizer spec`, which prints the token spec for
+// documentation and external tools.
+func runSpec(args []string) int {
+	fs := flag.NewFlagSet("spec", flag.ExitOnError)
+	fs.Parse(args)
+	os.Stdout.Write(tokenizer.TokenSpec())
+	return 0
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"tokenizer"
+)
+
+// Stats summarizes the token stream of one or more files.
+type Stats struct {
+	Files          int            `json:"files"`
+	Lines          int            `json:"lines"`
+	Tokens         int            `json:"tokens"`
+	Errors         int            `json:"errors"`
+	ByType         map[string]int `json:"byType"`
+	Literals       map[string]int `json:"literals"`
+	Comments       int            `json:"comments"`
+	CommentLines   int            `json:"commentLines"`
+	CommentDensity float64        `json:"commentDensity"` // comment lines / non-blank lines
+	TopIdents      []IdentCount   `json:"topIdents"`
+
+	idents    map[string]int
+	codeLines int
+}
+
+type IdentCount struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+func newStats() *Stats {
+	return &Stats{ByType: map[string]int{}, Literals: map[string]int{}, idents: map[string]int{}}
+}
+
+// add accumulates one lexed file.
+func (s *Stats) add(src string, toks, comments []tokenizer.Token, errs []string) {
+	s.Files++
+	s.Lines += len(splitLines(src))
+	s.Tokens += len(toks)
+	s.Errors += len(errs)
+	for _, t := range toks {
+		s.ByType[string(t.Type)]++
+		switch t.Type {
+		case tokenizer.IDENT:
+			s.idents[t.Lexeme]++
+		case tokenizer.INT_LIT, tokenizer.FLOAT_LIT, tokenizer.STRING_LIT, tokenizer.CHAR_LIT:
+			s.Literals[string(t.Type)]++
+		}
+	}
+	s.Comments += len(comments)
+	commentLines := map[int]bool{}
+	for _, c := range comments {
+		for l := c.Line; l <= tokenizer.EndLine(c); l++ {
+			commentLines[l] = true
+		}
+	}
+	s.CommentLines += len(commentLines)
+	for _, line := range splitLines(src) {
+		if strings.TrimSpace(line) != "" {
+			s.codeLines++
+		}
+	}
+}
+
+// finish computes the derived fields, keeping the topN most frequent
+// identifiers.
+func (s *Stats) finish(topN int) {
+	if s.codeLines > 0 {
+		s.CommentDensity = float64(s.CommentLines) / float64(s.codeLines)
+	}
+	s.TopIdents = []IdentCount{}
+	for name, n := range s.idents {
+		s.TopIdents = append(s.TopIdents, IdentCount{name, n})
+	}
+	sort.Slice(s.TopIdents, func(i, j int) bool {
+		a, b := s.TopIdents[i], s.TopIdents[j]
+		return a.Count > b.Count || a.Count == b.Count && a.Name < b.Name
+	})
+	if len(s.TopIdents) > topN {
+		s.TopIdents = s.TopIdents[:topN]
+	}
+}
+
+// sortedCounts returns the keys of m ordered by descending count.
+func sortedCounts(m map[string]int) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return m[keys[i]] > m[keys[j]] || m[keys[i]] == m[keys[j]] && keys[i] < keys[j]
+	})
+	return keys
+}
+
+func (s *Stats) writeText(w io.Writer) {
+	fmt.Fprintf(w, "files:           %d\n", s.Files)
+	fmt.Fprintf(w, "lines:           %d\n", s.Lines)
+	fmt.Fprintf(w, "tokens:          %d\n", s.Tokens)
+	fmt.Fprintf(w, "errors:          %d\n", s.Errors)
+	fmt.Fprintf(w, "comments:        %d (%d lines, density %.1f%%)\n", s.Comments, s.CommentLines, s.CommentDensity*100)
+	fmt.Fprintln(w, "\ntokens by type:")
+	for _, k := range sortedCounts(s.ByType) {
+		fmt.Fprintf(w, "  %-14s %d\n", k, s.ByType[k])
+	}
+	fmt.Fprintln(w, "\nliterals:")
+	for _, k := range sortedCounts(s.Literals) {
+		fmt.Fprintf(w, "  %-14s %d\n", k, s.Literals[k])
+	}
+	fmt.Fprintln(w, "\ntop identifiers:")
+	for _, ic := range s.TopIdents {
+		fmt.Fprintf(w, "  %-14s %d\n", ic.Name, ic.Count)
+	}
+}
+
+// runStats implements `tokenizer stats [-json] [-top N] [file ...]`.
+func runStats(args []string) int {
+	fs := flag.NewFlagSet("stats", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "print the report as JSON")
+	topN := fs.Int("top", 10, "number of most frequent identifiers to list")
+	fs.Parse(args)
+
+	paths := fs.Args()
+	if len(paths) == 0 {
+		paths = []string{"-"}
+	}
+	st := newStats()
+	for _, path := range paths {
+		data, _, err := readSource(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitFailure
+		}
+		lx := tokenizer.NewLexer(string(data))
+		toks, errs := lx.LexAll()
+		st.add(string(data), toks, lx.Comments(), errs)
+	}
+	st.finish(*topN)
+
+	if *asJSON {
+		b, err := json.MarshalIndent(st, "", "  ")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "marshal json error: %v\n", err)
+			return exitFailure
+		}
+		os.Stdout.Write(append(b, '\n'))
+		return 0
+	}
+	st.writeText(os.Stdout)
+	return 0
+}
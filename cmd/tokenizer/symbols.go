@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"tokenizer"
+)
+
+func writeSymbols(w io.Writer, syms []tokenizer.Symbol, decls bool) {
+	for _, s := range syms {
+		fmt.Fprintf(w, "%-20s %5d  %s:%d:%d", s.Name, s.Count, s.File, s.First.Line, s.First.Col)
+		if decls && s.Decl != "" {
+			fmt.Fprintf(w, "  %s", s.Decl)
+		}
+		fmt.Fprintln(w)
+	}
+}
+
+// runSymbols implements `tokenizer symbols [-decls] [-sort name|count] [-json] [path ...]`.
+func runSymbols(args []string) int {
+	fs := flag.NewFlagSet("symbols", flag.ExitOnError)
+	decls := fs.Bool("decls", false, "mark identifiers following def, type, var or cons as declarations")
+	onlyDecls := fs.Bool("only-decls", false, "list only probable declarations (implies -decls)")
+	sortBy := fs.String("sort", "name", "order of the report: name or count")
+	asJSON := fs.Bool("json", false, "print the report as JSON")
+	ext := fs.String("ext", ".jl", "extension of the files read from directories")
+	fs.Parse(args)
+
+	if *sortBy != "name" && *sortBy != "count" {
+		fmt.Fprintf(os.Stderr, "symbols: unknown sort order %q (want name or count)\n", *sortBy)
+		return exitFailure
+	}
+	paths := fs.Args()
+	if len(paths) == 0 {
+		paths = []string{"-"}
+	}
+	paths, err := expandPaths(paths, *ext)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitFailure
+	}
+	st := tokenizer.NewSymbolTable()
+	for _, path := range paths {
+		data, name, err := readSource(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitFailure
+		}
+		toks, _ := tokenizer.NewLexer(string(data)).LexAll()
+		st.Add(name, toks)
+	}
+	syms := st.Symbols(*sortBy == "count")
+	if *onlyDecls {
+		*decls = true
+		syms = filterSymbols(syms, func(s tokenizer.Symbol) bool { return s.Decl != "" })
+	}
+	if !*decls {
+		for i := range syms {
+			syms[i].Decl = ""
+		}
+	}
+
+	if *asJSON {
+		b, err := json.MarshalIndent(syms, "", "  ")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "marshal json error: %v\n", err)
+			return exitFailure
+		}
+		os.Stdout.Write(append(b, '\n'))
+		return 0
+	}
+	writeSymbols(os.Stdout, syms, *decls)
+	return 0
+}
+
+func filterSymbols(syms []tokenizer.Symbol, keep func(tokenizer.Symbol) bool) []tokenizer.Symbol {
+	out := syms[:0]
+	for _, s := range syms {
+		if keep(s) {
+			out = append(out, s)
+		}
+	}
+	return out
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+
+	"tokenizer"
+)
+
+// maxTableLexeme is the widest lexeme (in runes, after escaping) shown in table output.
+const maxTableLexeme = 40
+
+// escapeLexeme makes a lexeme safe to print on a terminal: control characters
+// are written as Go-style escapes and long lexemes are truncated.
+func escapeLexeme(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case r == '\r':
+			b.WriteString(`\r`)
+		case r < 0x100 && (unicode.IsControl(r) || !unicode.IsPrint(r) && r != ' '):
+			fmt.Fprintf(&b, `\x%02x`, r)
+		case !unicode.IsPrint(r) && r != ' ':
+			fmt.Fprintf(&b, `\u%04x`, r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	rs := []rune(b.String())
+	if len(rs) > maxTableLexeme {
+		return string(rs[:maxTableLexeme-3]) + "..."
+	}
+	return string(rs)
+}
+
+// writeTable prints tokens as a fixed-width LINE COL TYPE LEXEME table,
+// followed by any errors.
+func writeTable(w io.Writer, toks []tokenizer.Token, errs []string) {
+	fmt.Fprintf(w, "%-6s %-5s %-14s %s\n", "LINE", "COL", "TYPE", "LEXEME")
+	for _, t := range toks {
+		fmt.Fprintf(w, "%-6d %-5d %-14s %s\n", t.Line, t.Column, t.Type, escapeLexeme(t.Lexeme))
+	}
+	if len(errs) > 0 {
+		fmt.Fprintln(w)
+		for _, e := range errs {
+			fmt.Fprintln(w, e)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"fmt"
+	"io"
+)
+
+// editOp is one step of an edit script: '=' keeps a[I] (== b[J]), '-'
+// deletes a[I] and '+' inserts b[J].
+type editOp struct {
+	Kind byte
+	I, J int
+}
+
+// editScript computes a shortest edit script turning a sequence of length n
+// into one of length m with Myers' O((n+m)·d) algorithm; eq reports whether
+// a[i] equals b[j]. A common prefix and suffix are matched up front, which
+// keeps the search small for the usual case of a few local edits.
+func editScript(n, m int, eq func(i, j int) bool) []editOp {
+	pre := 0
+	for pre < n && pre < m && eq(pre, pre) {
+		pre++
+	}
+	suf := 0
+	for suf < n-pre && suf < m-pre && eq(n-1-suf, m-1-suf) {
+		suf++
+	}
+	ops := make([]editOp, 0, n+m)
+	for i := 0; i < pre; i++ {
+		ops = append(ops, editOp{'=', i, i})
+	}
+	ops = append(ops, myers(pre, n-suf, pre, m-suf, eq)...)
+	for i := 0; i < suf; i++ {
+		ops = append(ops, editOp{'=', n - suf + i, m - suf + i})
+	}
+	return ops
+}
+
+// myers diffs a[a0:a1] against b[b0:b1].
+func myers(a0, a1, b0, b1 int, eq func(i, j int) bool) []editOp {
+	n, m := a1-a0, b1-b0
+	max := n + m
+	off := max + 1
+	v := make([]int, 2*max+3)
+	var trace [][]int
+search:
+	for d := 0; d <= max; d++ {
+		trace = append(trace, append([]int(nil), v...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || k != d && v[off+k-1] < v[off+k+1] {
+				x = v[off+k+1]
+			} else {
+				x = v[off+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && eq(a0+x, b0+y) {
+				x++
+				y++
+			}
+			v[off+k] = x
+			if x >= n && y >= m {
+				break search
+			}
+		}
+	}
+
+	var rev []editOp
+	x, y := n, m
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d]
+		k := x - y
+		var prevK int
+		if k == -d || k != d && v[off+k-1] < v[off+k+1] {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v[off+prevK]
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			x--
+			y--
+			rev = append(rev, editOp{'=', a0 + x, b0 + y})
+		}
+		if d > 0 {
+			if x == prevX {
+				rev = append(rev, editOp{'+', a0 + x, b0 + prevY})
+			} else {
+				rev = append(rev, editOp{'-', a0 + prevX, b0 + y})
+			}
+			x, y = prevX, prevY
+		}
+	}
+	ops := make([]editOp, len(rev))
+	for i, op := range rev {
+		ops[len(rev)-1-i] = op
+	}
+	return ops
+}
+
+// writeUnifiedDiff prints a unified diff of two line slices with three lines
+// of context. It writes nothing when the slices are equal.
+func writeUnifiedDiff(w io.Writer, nameA, nameB string, a, b []string) {
+	ops := editScript(len(a), len(b), func(i, j int) bool { return a[i] == b[j] })
+	const context = 3
+	header := false
+	for k := 0; k < len(ops); {
+		if ops[k].Kind == '=' {
+			k++
+			continue
+		}
+		// grow the hunk until changes are separated by more than 2*context
+		start := k - context
+		if start < 0 {
+			start = 0
+		}
+		end := k
+		for end < len(ops) {
+			if ops[end].Kind != '=' {
+				end++
+				continue
+			}
+			run := end
+			for run < len(ops) && ops[run].Kind == '=' {
+				run++
+			}
+			if run == len(ops) || run-end > 2*context {
+				end += context
+				if end > len(ops) {
+					end = len(ops)
+				}
+				break
+			}
+			end = run
+		}
+		if !header {
+			fmt.Fprintf(w, "--- %s\n+++ %s\n", nameA, nameB)
+			header = true
+		}
+		var lenA, lenB int
+		for _, op := range ops[start:end] {
+			if op.Kind != '+' {
+				lenA++
+			}
+			if op.Kind != '-' {
+				lenB++
+			}
+		}
+		fmt.Fprintf(w, "@@ -%d,%d +%d,%d @@\n", ops[start].I+1, lenA, ops[start].J+1, lenB)
+		for _, op := range ops[start:end] {
+			switch op.Kind {
+			case '=':
+				fmt.Fprintf(w, " %s\n", a[op.I])
+			case '-':
+				fmt.Fprintf(w, "-%s\n", a[op.I])
+			case '+':
+				fmt.Fprintf(w, "+%s\n", b[op.J])
+			}
+		}
+		k = end
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"runtime"
+	"time"
+)
+
+// Timing is what --timings measured of lexing one input, or, as the total,
+// all of them.
+type Timing struct {
+	File         string        `json:"file,omitempty"`
+	Bytes        int           `json:"bytes"`
+	Tokens       int           `json:"tokens"`
+	Elapsed      time.Duration `json:"-"`
+	Seconds      float64       `json:"seconds"`
+	TokensPerSec float64       `json:"tokensPerSecond"`
+	MBPerSec     float64       `json:"mbPerSecond"`
+	Allocs       uint64        `json:"allocs"`     // heap objects allocated
+	AllocBytes   uint64        `json:"allocBytes"` // bytes of them
+}
+
+// lexTimer measures one lexing: the wall time, and with mem the heap
+// allocations, which cost a stop of the world to read.
+type lexTimer struct {
+	start time.Time
+	mem   bool
+	stats runtime.MemStats
+}
+
+func startTimer(mem bool) *lexTimer {
+	t := &lexTimer{mem: mem}
+	if mem {
+		runtime.ReadMemStats(&t.stats)
+	}
+	t.start = time.Now()
+	return t
+}
+
+// stop returns the Timing of file, of bytes lexed into tokens, since the
+// timer started.
+func (t *lexTimer) stop(file string, bytes, tokens int) Timing {
+	tm := Timing{File: file, Bytes: bytes, Tokens: tokens, Elapsed: time.Since(t.start)}
+	if t.mem {
+		var after runtime.MemStats
+		runtime.ReadMemStats(&after)
+		tm.Allocs, tm.AllocBytes = after.Mallocs-t.stats.Mallocs, after.TotalAlloc-t.stats.TotalAlloc
+	}
+	tm.rates()
+	return tm
+}
+
+// rates fills in the fields computed from Elapsed.
+func (tm *Timing) rates() {
+	tm.Seconds = tm.Elapsed.Seconds()
+	if tm.Seconds > 0 {
+		tm.TokensPerSec = float64(tm.Tokens) / tm.Seconds
+		tm.MBPerSec = float64(tm.Bytes) / 1e6 / tm.Seconds
+	}
+}
+
+// timingReport collects the timings of --timings.
+type timingReport struct {
+	Files  []Timing `json:"files"`
+	Total  Timing   `json:"total"`
+	asJSON bool     // --format json was given
+}
+
+func (r *timingReport) add(tm Timing) {
+	r.Files = append(r.Files, tm)
+	r.Total.Bytes += tm.Bytes
+	r.Total.Tokens += tm.Tokens
+	r.Total.Elapsed += tm.Elapsed
+	r.Total.Allocs += tm.Allocs
+	r.Total.AllocBytes += tm.AllocBytes
+	r.Total.rates()
+}
+
+// write prints the report as a table, or as one JSON object.
+func (r *timingReport) write(w io.Writer) error {
+	if r.asJSON {
+		if r.Files == nil {
+			r.Files = []Timing{}
+		}
+		data, err := json.Marshal(r)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "%s\n", data)
+		return err
+	}
+	width := len("total")
+	for _, tm := range r.Files {
+		width = max(width, len(tm.File))
+	}
+	row := func(name string, tm Timing) {
+		fmt.Fprintf(w, "%-*s  %10s  %9d  %10s  %12.0f  %8.1f  %9d  %10s\n", width, name, formatSize(tm.Bytes), tm.Tokens,
+			tm.Elapsed.Round(time.Microsecond), tm.TokensPerSec, tm.MBPerSec, tm.Allocs, formatSize(int(tm.AllocBytes)))
+	}
+	fmt.Fprintf(w, "%-*s  %10s  %9s  %10s  %12s  %8s  %9s  %10s\n", width, "FILE", "SIZE", "TOKENS", "TIME", "TOKENS/S", "MB/S", "ALLOCS", "ALLOCATED")
+	for _, tm := range r.Files {
+		row(tm.File, tm)
+	}
+	if len(r.Files) > 1 {
+		row("total", r.Total)
+	}
+	return nil
+}
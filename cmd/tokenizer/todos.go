@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+
+	"tokenizer"
+)
+
+// Todo is one TODO-style marker found in a comment.
+type Todo struct {
+	File    string `json:"file"`
+	Line    int    `json:"line"`
+	Col     int    `json:"col"`
+	Marker  string `json:"marker"`           // TODO, FIXME, HACK, ...
+	Author  string `json:"author,omitempty"` // from TODO(author)
+	Text    string `json:"text"`             // the rest of the comment line
+	Context string `json:"context"`          // the source line, trimmed
+}
+
+func (td Todo) String() string {
+	marker := td.Marker
+	if td.Author != "" {
+		marker += "(" + td.Author + ")"
+	}
+	return fmt.Sprintf("%s:%d:%d: %s: %s", td.File, td.Line, td.Col, marker, td.Text)
+}
+
+// todoPattern matches markers written as whole words, optionally tagged
+// with an author and followed by a colon: `TODO(ann): text`.
+func todoPattern(markers []string) *regexp.Regexp {
+	quoted := make([]string, len(markers))
+	for i, m := range markers {
+		quoted[i] = regexp.QuoteMeta(m)
+	}
+	return regexp.MustCompile(`\b(` + strings.Join(quoted, "|") + `)\b(?:\(([^)\n]*)\))?:?[ \t]*([^\n]*)`)
+}
+
+// findTodos returns the markers re finds in the comments of src, line and
+// block comments alike, in source order.
+func findTodos(name, src string, comments []tokenizer.Token, re *regexp.Regexp) []Todo {
+	var todos []Todo
+	for _, c := range comments {
+		if c.Type != tokenizer.COMMENT && c.Type != tokenizer.DOC_COMMENT {
+			continue
+		}
+		for _, m := range re.FindAllStringSubmatchIndex(c.Lexeme, -1) {
+			off := c.Offset + m[0]
+			lineStart, lineEnd := tokenizer.LineStartOf(src, off), tokenizer.LineEndOf(src, off)
+			text := strings.TrimSpace(c.Lexeme[m[6]:m[7]])
+			if c.Lexeme[1] == '*' && m[7] == len(c.Lexeme) {
+				text = strings.TrimSpace(strings.TrimSuffix(text, "*/"))
+			}
+			td := Todo{
+				File:    name,
+				Line:    c.Line + tokenizer.CountLineBreaks(c.Lexeme[:m[0]]),
+				Col:     tokenizer.ColumnsOf(src, lineStart, off).Runes,
+				Marker:  c.Lexeme[m[2]:m[3]],
+				Text:    text,
+				Context: strings.TrimSpace(src[lineStart:lineEnd]),
+			}
+			if m[4] >= 0 {
+				td.Author = strings.TrimSpace(c.Lexeme[m[4]:m[5]])
+			}
+			todos = append(todos, td)
+		}
+	}
+	return todos
+}
+
+func writeTodos(w io.Writer, todos []Todo) {
+	for _, td := range todos {
+		fmt.Fprintln(w, td)
+	}
+}
+
+// runTodos implements `tokenizer todos [-json] [-markers list] [path ...]`.
+func runTodos(args []string) int {
+	fs := flag.NewFlagSet("todos", flag.ExitOnError)
+	markers := fs.String("markers", "TODO,FIXME,HACK", "comma-separated markers to report")
+	asJSON := fs.Bool("json", false, "print the report as JSON")
+	ext := fs.String("ext", ".jl", "extension of the files read from directories")
+	fs.Parse(args)
+
+	list := splitList(*markers)
+	if len(list) == 0 {
+		fmt.Fprintln(os.Stderr, "todos: no markers given")
+		return exitFailure
+	}
+	re := todoPattern(list)
+	paths := fs.Args()
+	if len(paths) == 0 {
+		paths = []string{"-"}
+	}
+	paths, err := expandPaths(paths, *ext)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitFailure
+	}
+	todos := []Todo{}
+	for _, path := range paths {
+		data, name, err := readSource(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitFailure
+		}
+		lx := tokenizer.NewLexer(string(data))
+		lx.LexAll()
+		todos = append(todos, findTodos(name, string(data), lx.Comments(), re)...)
+	}
+
+	if *asJSON {
+		b, err := json.MarshalIndent(todos, "", "  ")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "marshal json error: %v\n", err)
+			return exitFailure
+		}
+		os.Stdout.Write(append(b, '\n'))
+		return 0
+	}
+	writeTodos(os.Stdout, todos)
+	return 0
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"tokenizer"
+)
+
+// TokenChange is one entry of a token-level diff. Kind is "inserted",
+// "deleted" or "changed"; Old is set unless inserted, New unless deleted.
+type TokenChange struct {
+	Kind string           `json:"kind"`
+	Old  *tokenizer.Token `json:"old,omitempty"`
+	New  *tokenizer.Token `json:"new,omitempty"`
+}
+
+// DiffTokens compares two token streams by type and lexeme. Runs where n
+// tokens are deleted and n inserted at the same place are reported as n
+// changes.
+func DiffTokens(a, b []tokenizer.Token) []TokenChange {
+	ops := editScript(len(a), len(b), func(i, j int) bool {
+		return a[i].Type == b[j].Type && a[i].Lexeme == b[j].Lexeme
+	})
+	var out []TokenChange
+	for k := 0; k < len(ops); {
+		if ops[k].Kind == '=' {
+			k++
+			continue
+		}
+		var dels, ins []editOp
+		for ; k < len(ops) && ops[k].Kind != '='; k++ {
+			if ops[k].Kind == '-' {
+				dels = append(dels, ops[k])
+			} else {
+				ins = append(ins, ops[k])
+			}
+		}
+		i := 0
+		for ; i < len(dels) && i < len(ins); i++ {
+			out = append(out, TokenChange{Kind: "changed", Old: &a[dels[i].I], New: &b[ins[i].J]})
+		}
+		for _, op := range dels[i:] {
+			out = append(out, TokenChange{Kind: "deleted", Old: &a[op.I]})
+		}
+		for _, op := range ins[i:] {
+			out = append(out, TokenChange{Kind: "inserted", New: &b[op.J]})
+		}
+	}
+	return out
+}
+
+func describeToken(t *tokenizer.Token) string {
+	return fmt.Sprintf("%d:%d %s %q", t.Line, t.Column, t.Type, t.Lexeme)
+}
+
+func writeTokenDiff(w io.Writer, nameA, nameB string, changes []TokenChange) {
+	for _, c := range changes {
+		switch c.Kind {
+		case "changed":
+			fmt.Fprintf(w, "~ %s:%s -> %s:%s\n", nameA, describeToken(c.Old), nameB, describeToken(c.New))
+		case "deleted":
+			fmt.Fprintf(w, "- %s:%s\n", nameA, describeToken(c.Old))
+		case "inserted":
+			fmt.Fprintf(w, "+ %s:%s\n", nameB, describeToken(c.New))
+		}
+	}
+}
+
+// runDiff implements `tokenizer diff [-json] a.jl b.jl`. Like diff(1) it
+// exits 1 when the files differ.
+func runDiff(args []string) int {
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "print the changes as JSON")
+	fs.Parse(args)
+	if fs.NArg() != 2 {
+		fmt.Fprintln(os.Stderr, "usage: tokenizer diff [-json] a.jl b.jl")
+		return exitFailure
+	}
+
+	var streams [2][]tokenizer.Token
+	for i, path := range fs.Args() {
+		data, name, err := readSource(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitFailure
+		}
+		toks, errs := tokenizer.NewLexer(string(data)).LexAll()
+		for _, e := range errs {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", name, e)
+		}
+		streams[i] = toks
+	}
+	changes := DiffTokens(streams[0], streams[1])
+
+	if *asJSON {
+		if changes == nil {
+			changes = []TokenChange{}
+		}
+		b, err := json.MarshalIndent(changes, "", "  ")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "marshal json error: %v\n", err)
+			return exitFailure
+		}
+		os.Stdout.Write(append(b, '\n'))
+	} else {
+		writeTokenDiff(os.Stdout, fs.Arg(0), fs.Arg(1), changes)
+	}
+	if len(changes) > 0 {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"tokenizer"
+)
+
+// Modes of --fix-unicode-punct.
+const (
+	fixPunctWarn  = "warn"  // lex the ASCII equivalents and warn about each
+	fixPunctWrite = "write" // also rewrite the file with them
+)
+
+// fixPunct applies --fix-unicode-punct to the source of srcPath: it warns
+// about each replacement or, in write mode, rewrites the file and reports
+// them. It returns the source to tokenize.
+func fixPunct(src, srcPath string, o *cliOptions) (string, error) {
+	fixed, fixes := tokenizer.FixUnicodePunct(src, o.lexOptions()...)
+	for _, f := range fixes {
+		if o.fixPunct == fixPunctWrite {
+			fmt.Fprintf(os.Stderr, "%s:%d:%d: replaced %q with %q\n", srcPath, f.Line, f.Col, f.From, rune(f.To))
+		} else {
+			fmt.Fprintf(os.Stderr, "%s:%d:%d: warning: treating %q as %q\n", srcPath, f.Line, f.Col, f.From, rune(f.To))
+		}
+	}
+	if o.fixPunct != fixPunctWrite || len(fixes) == 0 {
+		return fixed, nil
+	}
+	info, err := os.Stat(srcPath)
+	if err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(srcPath, []byte(fixed), info.Mode().Perm()); err != nil {
+		return "", err
+	}
+	if !o.quiet {
+		fmt.Fprintf(os.Stderr, "wrote %s\n", srcPath)
+	}
+	return fixed, nil
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"tokenizer"
+)
+
+// runUnlex implements `tokenizer unlex [-o file] tokens.json`: it turns the
+// JSON output of `tokenizer --trivia` back into the source.
+func runUnlex(args []string) int {
+	fs := flag.NewFlagSet("unlex", flag.ExitOnError)
+	outPath := fs.String("o", "", "write the source to this file instead of stdout")
+	fs.Parse(args)
+	if fs.NArg() > 1 {
+		fmt.Fprintln(os.Stderr, "usage: tokenizer unlex [-o file] [tokens.json]")
+		return exitFailure
+	}
+
+	data, name, err := readSource(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitFailure
+	}
+	var doc tokenizer.TokenDocument
+	if err := json.Unmarshal(data, &doc); err != nil {
+		fmt.Fprintf(os.Stderr, "unlex: %s: %v\n", name, err)
+		return exitFailure
+	}
+	src, err := tokenizer.Unlex(doc.Tokens)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "unlex: %s: %v\n", name, err)
+		return exitFailure
+	}
+	if *outPath == "" {
+		os.Stdout.WriteString(src)
+		return 0
+	}
+	if err := os.WriteFile(*outPath, []byte(src), 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "write file error: %v\n", err)
+		return exitFailure
+	}
+	return 0
+}
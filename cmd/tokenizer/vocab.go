@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"tokenizer"
+)
+
+// runTrainVocab implements `tokenizer train-vocab [-size N] [-kind k] [-o
+// file] path ...`: it trains a vocabulary over a corpus and writes it as JSON.
+func runTrainVocab(args []string) int {
+	fs := flag.NewFlagSet("train-vocab", flag.ExitOnError)
+	size := fs.Int("size", 8000, "number of ids in the vocabulary")
+	kind := fs.String("kind", tokenizer.VocabLexical, "vocabulary kind: lexical (frequent lexemes) or bpe (byte-level subwords)")
+	outPath := fs.String("o", "vocab.json", "write the vocabulary to this file (- for stdout)")
+	hfPath := fs.String("hf", "", "also write the vocabulary as a HuggingFace tokenizer.json to this file")
+	ext := fs.String("ext", ".jl", "extension of the files read from directories")
+	fs.Parse(args)
+
+	vt, err := tokenizer.NewVocabTrainer(*kind)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitFailure
+	}
+	paths := fs.Args()
+	if len(paths) == 0 {
+		paths = []string{"-"}
+	}
+	paths, err = expandPaths(paths, *ext)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitFailure
+	}
+	for _, path := range paths {
+		data, _, err := readSource(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitFailure
+		}
+		vt.Add(string(data))
+	}
+	v, err := vt.Train(*size)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitFailure
+	}
+
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "marshal json error: %v\n", err)
+		return exitFailure
+	}
+	b = append(b, '\n')
+	if *hfPath != "" {
+		hf, err := v.HuggingFace()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "marshal json error: %v\n", err)
+			return exitFailure
+		}
+		if err := os.WriteFile(*hfPath, append(hf, '\n'), 0644); err != nil {
+			fmt.Fprintf(os.Stderr, "write file error: %v\n", err)
+			return exitFailure
+		}
+	}
+	if *outPath == "-" {
+		os.Stdout.Write(b)
+		return 0
+	}
+	if err := os.WriteFile(*outPath, b, 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "write file error: %v\n", err)
+		return exitFailure
+	}
+	fmt.Fprintf(os.Stderr, "wrote %s: %d ids\n", *outPath, v.Size)
+	return 0
+}
@@ -0,0 +1,27 @@
+//go:build js && wasm
+
+package main
+
+import (
+	"context"
+	"syscall/js"
+)
+
+// platformMain installs the browser API and keeps the program alive so the
+// page can call it: globalThis.tokenize(source) returns {tokens, errors},
+// and tokenize(source, {parse: true}) also includes the AST. Nothing here
+// touches the file system.
+func platformMain() bool {
+	js.Global().Set("tokenize", js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		if len(args) == 0 || args[0].Type() != js.TypeString {
+			return js.Global().Get("Error").New("tokenize: expected a source string")
+		}
+		parse := len(args) > 1 && args[1].Type() == js.TypeObject && args[1].Get("parse").Truthy()
+		doc, err := tokenizeDocument(context.Background(), args[0].String(), parse)
+		if err != nil {
+			return js.Global().Get("Error").New("tokenize: " + err.Error())
+		}
+		return js.Global().Get("JSON").Call("parse", string(doc))
+	}))
+	select {}
+}
@@ -0,0 +1,7 @@
+//go:build !(js && wasm)
+
+package main
+
+// platformMain runs instead of the command-line tool on platforms that
+// need a different entry point; it reports whether it did.
+func platformMain() bool { return false }
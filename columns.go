@@ -0,0 +1,172 @@
+package tokenizer
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// ColumnUnit selects what Token.Column and Diagnostic.Col count from the
+// start of the line: bytes, runes (the default) or UTF-16 code units.
+type ColumnUnit string
+
+const (
+	ColumnBytes ColumnUnit = "bytes"
+	ColumnRunes ColumnUnit = "runes"
+	ColumnUTF16 ColumnUnit = "utf16"
+)
+
+// ParseColumnUnit checks the name of a column unit.
+func ParseColumnUnit(s string) (ColumnUnit, error) {
+	switch u := ColumnUnit(s); u {
+	case ColumnBytes, ColumnRunes, ColumnUTF16:
+		return u, nil
+	}
+	return "", fmt.Errorf("unknown column unit %q (want bytes, runes or utf16)", s)
+}
+
+// Columns is a token's 1-based column in each of the three units, as
+// included in verbose position mode.
+type Columns struct {
+	Bytes int `json:"bytes"`
+	Runes int `json:"runes"`
+	UTF16 int `json:"utf16"`
+}
+
+// ColumnsOf returns the columns of byte offset off, which lies on the line
+// starting at byte offset lineStart.
+func ColumnsOf(src string, lineStart, off int) Columns {
+	text := src[lineStart:off]
+	return Columns{
+		Bytes: len(text) + 1,
+		Runes: utf8.RuneCountInString(text) + 1,
+		UTF16: utf16Len(text) + 1,
+	}
+}
+
+// advanceColumns returns the columns after text, which starts at c. With a
+// tabWidth above 0, a tab moves every unit to the next tab stop.
+func advanceColumns(c Columns, text string, tabWidth int) Columns {
+	if tabWidth <= 0 {
+		d := ColumnsOf(text, 0, len(text))
+		return Columns{c.Bytes + d.Bytes - 1, c.Runes + d.Runes - 1, c.UTF16 + d.UTF16 - 1}
+	}
+	stop := func(col int) int { return col + tabWidth - (col-1)%tabWidth }
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		i += size
+		if r == '\t' {
+			c.Bytes, c.Runes, c.UTF16 = stop(c.Bytes), stop(c.Runes), stop(c.UTF16)
+			continue
+		}
+		c.Bytes += size
+		c.Runes++
+		c.UTF16 += utf16Len(string(r))
+	}
+	return c
+}
+
+func (c Columns) in(u ColumnUnit) int {
+	switch u {
+	case ColumnBytes:
+		return c.Bytes
+	case ColumnUTF16:
+		return c.UTF16
+	}
+	return c.Runes
+}
+
+// SetColumnUnit makes LexAll report columns in u instead of runes.
+func (lx *Lexer) SetColumnUnit(u ColumnUnit) {
+	lx.columnUnit = u
+}
+
+// SetVerbosePositions makes LexAll attach the column in every unit to each
+// token and comment.
+func (lx *Lexer) SetVerbosePositions(on bool) {
+	lx.verbosePos = on
+}
+
+// recolumn rewrites the rune columns computed while scanning into the
+// configured unit, and fills in Cols in verbose position mode.
+func (lx *Lexer) recolumn() {
+	if (lx.columnUnit == "" || lx.columnUnit == ColumnRunes) && !lx.verbosePos && lx.tabWidth <= 0 {
+		return
+	}
+	fix := func(toks []Token) {
+		cc := columnCursor{lx: lx, off: -1}
+		for i := range toks {
+			t := &toks[i]
+			cols := cc.at(t.Offset)
+			t.Column = cols.in(lx.columnUnit)
+			if lx.verbosePos {
+				t.Cols = &cols
+			}
+		}
+	}
+	fix(lx.tokens)
+	fix(lx.comments)
+	cc := columnCursor{lx: lx, off: -1}
+	for i := range lx.diags {
+		d := &lx.diags[i]
+		off := d.Offset
+		if off > len(lx.src) {
+			off = len(lx.src)
+		}
+		d.Col = cc.at(off).in(lx.columnUnit)
+		lx.errors[i] = d.String()
+	}
+}
+
+// columnCursor finds the columns of byte offsets on their logical lines,
+// where line continuations are left out. It carries the columns forward from
+// the offset asked for before, so offsets in source order cost one pass over
+// the source, and starts over at a line break or an earlier offset.
+type columnCursor struct {
+	lx   *Lexer
+	off  int // -1 before the first offset
+	cols Columns
+}
+
+func (cc *columnCursor) at(off int) Columns {
+	lx := cc.lx
+	from := cc.off
+	switch {
+	case from < 0 || off < from:
+		from, cc.cols = lx.lastLineBreak(0, off)+1, Columns{1, 1, 1}
+	default:
+		if nl := lx.lastLineBreak(from, off); nl >= 0 {
+			from, cc.cols = nl+1, Columns{1, 1, 1}
+		}
+	}
+	// the text from..off without the continuations in it
+	for j := sort.Search(len(lx.joins), func(j int) bool { return lx.joins[j][1] > from }); j < len(lx.joins) && lx.joins[j][0] < off; j++ {
+		if from < lx.joins[j][0] {
+			cc.cols = advanceColumns(cc.cols, lx.src[from:lx.joins[j][0]], lx.tabWidth)
+		}
+		from = lx.joins[j][1]
+	}
+	if from < off {
+		cc.cols = advanceColumns(cc.cols, lx.src[from:off], lx.tabWidth)
+	}
+	cc.off = off
+	return cc.cols
+}
+
+// lastLineBreak returns the offset of the last line break in src[from:to]
+// that is not part of a line continuation, or -1.
+func (lx *Lexer) lastLineBreak(from, to int) int {
+	for {
+		nl := strings.LastIndexAny(lx.src[from:to], "\r\n")
+		if nl < 0 {
+			return -1
+		}
+		nl += from
+		j := sort.Search(len(lx.joins), func(j int) bool { return lx.joins[j][1] > nl })
+		if j == len(lx.joins) || lx.joins[j][0] > nl {
+			return nl
+		}
+		to = max(from, lx.joins[j][0])
+	}
+}
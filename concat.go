@@ -0,0 +1,47 @@
+package tokenizer
+
+// Source is one named input of LexSources.
+type Source struct {
+	Name string
+	Text string
+}
+
+// LexSources lexes several sources into one token stream for whole-program
+// analysis. The tokens of each source are delimited by a FILE_BEGIN token,
+// whose lexeme is the source's name, and a FILE_END token at its end. Every
+// token carries the index of its source as File; offsets, lines and columns
+// stay relative to that source. Each error is prefixed with its source's
+// name. Every source is lexed with opts.
+func LexSources(srcs []Source, opts ...Option) ([]Token, []string) {
+	toks, errs, _ := LexSourcesFunc(srcs, func(src Source) *Lexer { return NewLexer(src.Text, opts...) }, nil)
+	return toks, errs
+}
+
+// LexSourcesFunc is LexSources with the lexer of each source made by
+// newLexer and run by lexAll, which may wrap its LexAll, say to time it; a
+// nil lexAll just calls LexAll. It also returns the lexer of each source,
+// for its comments and diagnostics.
+func LexSourcesFunc(srcs []Source, newLexer func(src Source) *Lexer, lexAll func(lx *Lexer) ([]Token, []string)) ([]Token, []string, []*Lexer) {
+	if lexAll == nil {
+		lexAll = (*Lexer).LexAll
+	}
+	var (
+		all    []Token
+		errs   []string
+		lexers = make([]*Lexer, len(srcs))
+	)
+	for i, src := range srcs {
+		lx := newLexer(src)
+		lx.SetFile(i)
+		toks, lexErrs := lexAll(lx)
+		lexers[i] = lx
+		all = append(all, Token{Type: FILE_BEGIN, Lexeme: src.Name, Line: 1, Column: 1, File: i})
+		all = append(all, toks...)
+		c := ColumnsOf(src.Text, LineStartOf(src.Text, len(src.Text)), len(src.Text)).in(lx.columnUnit)
+		all = append(all, Token{Type: FILE_END, Line: lx.line, Column: c, Offset: len(src.Text), End: len(src.Text), File: i})
+		for _, e := range lexErrs {
+			errs = append(errs, src.Name+": "+e)
+		}
+	}
+	return all, errs, lexers
+}
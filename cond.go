@@ -0,0 +1,271 @@
+package tokenizer
+
+import (
+	"fmt"
+	"strings"
+)
+
+// cond is an open #if: one frame of the conditional stack of a file.
+type cond struct {
+	at     Token // the #if
+	active bool  // the current branch is kept
+	taken  bool  // some branch has been kept
+	inElse bool  // #else was seen
+}
+
+// skippedSpan is a stretch of a file that an #if left out.
+type skippedSpan struct {
+	file, from, to int
+}
+
+// activeConds reports whether every open #if keeps its current branch.
+func activeConds(conds []cond) bool {
+	return len(conds) == 0 || conds[len(conds)-1].active
+}
+
+// conditional handles t if it is an #if, #ifdef, #ifndef, #elif, #else or
+// #endif directive, updating conds, and reports whether it was one.
+func (pp *Preprocessor) conditional(t Token, conds *[]cond) bool {
+	c := *conds
+	outer := len(c) < 2 || c[len(c)-2].active // the enclosing branch is kept
+	switch *t.Value {
+	case "if", "ifdef", "ifndef":
+		on := activeConds(c)
+		if on {
+			on = pp.condition(t)
+		}
+		*conds = append(c, cond{at: t, active: on, taken: on})
+	case "elif":
+		if len(c) == 0 {
+			pp.errorAt(t, ErrCondition, "#elif without #if")
+			return true
+		}
+		if c[len(c)-1].inElse {
+			pp.errorAt(t, ErrCondition, "#elif after #else")
+			return true
+		}
+		top := &c[len(c)-1]
+		top.active = false
+		if outer && !top.taken {
+			top.active = pp.condition(t)
+			top.taken = top.active
+		}
+	case "else":
+		if len(c) == 0 || c[len(c)-1].inElse {
+			pp.errorAt(t, ErrCondition, "#else without #if")
+			return true
+		}
+		top := &c[len(c)-1]
+		top.active, top.taken, top.inElse = outer && !top.taken, true, true
+	case "endif":
+		if len(c) == 0 {
+			pp.errorAt(t, ErrCondition, "#endif without #if")
+			return true
+		}
+		*conds = c[:len(c)-1]
+	default:
+		return false
+	}
+	return true
+}
+
+// condition evaluates the condition of the #if-like directive t.
+func (pp *Preprocessor) condition(t Token) bool {
+	toks := pp.directiveTokens(t)
+	if *t.Value != "if" && *t.Value != "elif" {
+		if len(toks) != 1 || toks[0].Type != IDENT {
+			pp.errorAt(t, ErrCondition, fmt.Sprintf("#%s expects a macro name", *t.Value))
+			return false
+		}
+		return (pp.macros[toks[0].Lexeme] != nil) == (*t.Value == "ifdef")
+	}
+
+	// defined is resolved before macros are expanded, as in C
+	var pre []Token
+	for i := 0; i < len(toks); i++ {
+		if toks[i].Type != IDENT || toks[i].Lexeme != "defined" {
+			pre = append(pre, toks[i])
+			continue
+		}
+		name, n := definedOperand(toks[i+1:])
+		if n == 0 {
+			pp.errorAt(toks[i], ErrCondition, "defined expects a macro name")
+			return false
+		}
+		v := int64(0)
+		if pp.macros[name] != nil {
+			v = 1
+		}
+		pre = append(pre, Token{Type: INT_LIT, Lexeme: "defined", IntVal: &v, Line: toks[i].Line, Column: toks[i].Column, Offset: toks[i].Offset, End: toks[i].End, File: toks[i].File})
+		i += n
+	}
+	ev := &condEval{toks: pp.substitute(pre, nil)}
+	if len(ev.toks) == 0 {
+		pp.errorAt(t, ErrCondition, fmt.Sprintf("#%s expects a condition", *t.Value))
+		return false
+	}
+	v := ev.or()
+	if ev.err == "" && ev.pos < len(ev.toks) {
+		ev.fail(fmt.Sprintf("unexpected %q", ev.toks[ev.pos].Lexeme))
+	}
+	if ev.err != "" {
+		pp.errorAt(ev.at(t), ErrCondition, "invalid #"+*t.Value+" condition: "+ev.err)
+		return false
+	}
+	return v != 0
+}
+
+// definedOperand returns the name of `NAME` or `(NAME)` at the start of
+// toks and how many tokens it takes, or 0 if there is none.
+func definedOperand(toks []Token) (string, int) {
+	switch {
+	case len(toks) >= 1 && toks[0].Type == IDENT:
+		return toks[0].Lexeme, 1
+	case len(toks) >= 3 && toks[0].Type == LPAREN && toks[1].Type == IDENT && toks[2].Type == RPAREN:
+		return toks[1].Lexeme, 3
+	}
+	return "", 0
+}
+
+// condEval evaluates an #if condition: integers, names (0 unless a macro
+// made them a number), !, unary -, comparisons, && and || with C
+// precedence, and parentheses.
+type condEval struct {
+	toks []Token
+	pos  int
+	err  string
+	bad  int // index of the token err is about
+}
+
+// at returns the token err is about, or t when there is none.
+func (e *condEval) at(t Token) Token {
+	if e.bad < len(e.toks) {
+		return e.toks[e.bad]
+	}
+	return t
+}
+
+func (e *condEval) fail(msg string) {
+	if e.err == "" {
+		e.err, e.bad = msg, e.pos
+	}
+}
+
+func (e *condEval) accept(tt TokenType) bool {
+	if e.pos < len(e.toks) && e.toks[e.pos].Type == tt {
+		e.pos++
+		return true
+	}
+	return false
+}
+
+func (e *condEval) or() int64 {
+	v := e.and()
+	for e.accept(OROR) {
+		r := e.and()
+		v = b2i(v != 0 || r != 0)
+	}
+	return v
+}
+
+func (e *condEval) and() int64 {
+	v := e.cmp()
+	for e.accept(ANDAND) {
+		r := e.cmp()
+		v = b2i(v != 0 && r != 0)
+	}
+	return v
+}
+
+func (e *condEval) cmp() int64 {
+	v := e.unary()
+	for e.pos < len(e.toks) {
+		var f func(a, b int64) bool
+		switch e.toks[e.pos].Type {
+		case EQ:
+			f = func(a, b int64) bool { return a == b }
+		case NE:
+			f = func(a, b int64) bool { return a != b }
+		case LT:
+			f = func(a, b int64) bool { return a < b }
+		case LE:
+			f = func(a, b int64) bool { return a <= b }
+		case GT:
+			f = func(a, b int64) bool { return a > b }
+		case GE:
+			f = func(a, b int64) bool { return a >= b }
+		default:
+			return v
+		}
+		e.pos++
+		v = b2i(f(v, e.unary()))
+	}
+	return v
+}
+
+func (e *condEval) unary() int64 {
+	switch {
+	case e.accept(BANG):
+		return b2i(e.unary() == 0)
+	case e.accept(MINUS):
+		return -e.unary()
+	case e.accept(LPAREN):
+		v := e.or()
+		if !e.accept(RPAREN) {
+			e.fail("missing ')'")
+		}
+		return v
+	}
+	if e.pos == len(e.toks) {
+		e.fail("unexpected end of condition")
+		return 0
+	}
+	t := e.toks[e.pos]
+	e.pos++
+	switch {
+	case t.Type == INT_LIT && t.IntVal != nil:
+		return *t.IntVal
+	case t.Type == IDENT:
+		return 0 // not a macro
+	case strings.HasPrefix(string(t.Type), "KW_"):
+		return 0 // keywords are names too
+	}
+	e.pos--
+	e.fail(fmt.Sprintf("unexpected %q", t.Lexeme))
+	return 0
+}
+
+func b2i(b bool) int64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// skip records that toks from offset from to offset to of file were left
+// out.
+func (pp *Preprocessor) skip(file, from, to int) {
+	if to > from {
+		pp.skipped = append(pp.skipped, skippedSpan{file, from, to})
+	}
+}
+
+// Skipped reports whether d is a lexical error in a branch an #if left
+// out, which is not reported unless SetCheckInactive(true) was called.
+func (pp *Preprocessor) Skipped(d Diagnostic) bool {
+	if pp.checkInactive || d.Phase != "lexical" {
+		return false
+	}
+	for _, s := range pp.skipped {
+		if s.file == d.File && d.Offset >= s.from && d.Offset < s.to {
+			return true
+		}
+	}
+	return false
+}
+
+// SetCheckInactive makes the preprocessor keep lexical errors found in
+// branches an #if leaves out; by default they are dropped.
+func (pp *Preprocessor) SetCheckInactive(check bool) {
+	pp.checkInactive = check
+}
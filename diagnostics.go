@@ -0,0 +1,78 @@
+package tokenizer
+
+// Error codes identify a kind of error independently of its message, so
+// they stay stable when wording changes and can be passed to --suppress.
+const (
+	ErrInvalidCharacter    = "E0001" // character that starts no token
+	ErrInvalidUTF8         = "E0002"
+	ErrUnterminatedComment = "E0003"
+	ErrInvalidNumber       = "E0004" // malformed hex, binary, octal or float literal
+	ErrIntOverflow         = "E0005"
+	ErrUnterminatedString  = "E0006" // string, raw string, text block or interpolation
+	ErrInvalidEscape       = "E0007"
+	ErrInvalidCharLit      = "E0008"
+	ErrBadDirective        = "E0009"
+	ErrBadAnnotation       = "E0010"
+	ErrBidiControl         = "E0011" // Unicode bidirectional control, anywhere in the source
+	ErrLimit               = "E0012" // input, token or token count above a limit of Limits
+	ErrSyntax              = "E0100" // any parser error
+	ErrInclude             = "E0200" // #include not found, unreadable or cyclic
+	ErrMacro               = "E0201" // malformed #define or macro call, too deep an expansion
+	ErrCondition           = "E0202" // bad #if condition, unbalanced #if/#else/#endif
+	ErrComplexity          = "E0300" // function above the complexity limit
+	ErrBracket             = "E0400" // unbalanced or badly nested bracket
+)
+
+// ErrorFilter applies --max-errors and --suppress as errors are reported,
+// for the lexer, the preprocessor and the parser alike. The zero value
+// admits every error.
+type ErrorFilter struct {
+	Max      int // 0 means no limit
+	silenced map[string]bool
+	kept     int
+	omitted  int // errors dropped because Max was reached
+}
+
+// Suppress makes f drop errors with the given codes.
+func (f *ErrorFilter) Suppress(codes ...string) {
+	if f.silenced == nil {
+		f.silenced = map[string]bool{}
+	}
+	for _, c := range codes {
+		f.silenced[c] = true
+	}
+}
+
+// Admit reports whether an error with the given code should be recorded.
+func (f *ErrorFilter) Admit(code string) bool {
+	if f.silenced[code] {
+		return false
+	}
+	if f.Max > 0 && f.kept >= f.Max {
+		f.omitted++
+		return false
+	}
+	f.kept++
+	return true
+}
+
+// Omitted returns how many errors were dropped because Max was reached.
+func (f *ErrorFilter) Omitted() int {
+	return f.omitted
+}
+
+// SetMaxErrors makes the lexer keep at most n errors; the rest are only
+// counted, see OmittedErrors. n <= 0 means no limit.
+func (lx *Lexer) SetMaxErrors(n int) {
+	lx.filter.Max = n
+}
+
+// Suppress drops errors with the given codes, e.g. ErrInvalidEscape.
+func (lx *Lexer) Suppress(codes ...string) {
+	lx.filter.Suppress(codes...)
+}
+
+// OmittedErrors returns how many errors were dropped by SetMaxErrors.
+func (lx *Lexer) OmittedErrors() int {
+	return lx.filter.Omitted()
+}
@@ -0,0 +1,75 @@
+package tokenizer
+
+import (
+	"sort"
+	"strings"
+)
+
+// isDocComment reports whether the comment lex is a doc comment: `/// ...`
+// or `/** ... */`, but not a `////` rule or the empty `/**/`.
+func isDocComment(lex string) bool {
+	switch {
+	case strings.HasPrefix(lex, "///"):
+		return !strings.HasPrefix(lex, "////")
+	case strings.HasPrefix(lex, "/**"):
+		return !strings.HasPrefix(lex, "/**/") && !strings.HasPrefix(lex, "/***")
+	}
+	return false
+}
+
+// docText returns the text of a doc comment without its markers: the
+// `///` and one space of a line comment, or the `/**`, `*/` and the leading
+// `*` and space of each line of a block comment.
+func docText(lex string) string {
+	if strings.HasPrefix(lex, "///") {
+		return strings.TrimRight(strings.TrimPrefix(lex[3:], " "), " \t\r")
+	}
+	lines := strings.Split(NormalizeLineBreaks(strings.TrimSuffix(lex[3:], "*/")), "\n")
+	for i, l := range lines {
+		if t := strings.TrimLeft(l, " \t"); strings.HasPrefix(t, "*") {
+			l = t[1:]
+		} else if i == 0 {
+			l = t
+		}
+		lines[i] = strings.TrimRight(strings.TrimPrefix(l, " "), " \t\r")
+	}
+	for len(lines) > 0 && lines[0] == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// attachDocs sets Doc on each def, type, var or cons token that directly
+// follows a run of doc comments: on the next line, or after annotations
+// that do. Consecutive doc comments on adjacent lines form one run.
+func (lx *Lexer) attachDocs() {
+	cs := lx.comments
+	for i := 0; i < len(cs); i++ {
+		if cs[i].Type != DOC_COMMENT {
+			continue
+		}
+		texts := []string{docText(cs[i].Lexeme)}
+		for i+1 < len(cs) && cs[i+1].Type == DOC_COMMENT && cs[i+1].Line == EndLine(cs[i])+1 {
+			i++
+			texts = append(texts, docText(cs[i].Lexeme))
+		}
+		last := cs[i]
+		j := sort.Search(len(lx.tokens), func(j int) bool { return lx.tokens[j].Offset >= last.End })
+		if j == len(lx.tokens) || lx.tokens[j].Line > EndLine(last)+1 {
+			continue
+		}
+		if i+1 < len(cs) && cs[i+1].Offset < lx.tokens[j].Offset {
+			continue // a plain comment comes between
+		}
+		for j < len(lx.tokens) && lx.tokens[j].Type == ANNOTATION {
+			j++
+		}
+		if j < len(lx.tokens) && declKeywords[lx.tokens[j].Type] != "" {
+			doc := strings.Join(texts, "\n")
+			lx.tokens[j].Doc = &doc
+		}
+	}
+}
@@ -17,4 +17,4 @@ var d: f64 = 1e+
 var e: i32 = 123_
 
 // 6) invalid character
-@
+$
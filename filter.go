@@ -0,0 +1,22 @@
+package tokenizer
+
+// Filter returns the tokens for which keep reports true, in their original
+// order. toks is not modified.
+func Filter(toks []Token, keep func(Token) bool) []Token {
+	out := make([]Token, 0, len(toks))
+	for _, t := range toks {
+		if keep(t) {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+// OfType returns a Filter predicate matching tokens of any of the given types.
+func OfType(types ...TokenType) func(Token) bool {
+	set := make(map[TokenType]bool, len(types))
+	for _, tt := range types {
+		set[tt] = true
+	}
+	return func(t Token) bool { return set[t.Type] }
+}
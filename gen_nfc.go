@@ -0,0 +1,162 @@
+//go:build ignore
+
+// gen_nfc generates nfc_tables.go, the Unicode data NFC needs, from two
+// files of the Unicode Character Database (https://www.unicode.org/Public/UCD/latest/ucd/):
+//
+//	go run gen_nfc.go UnicodeData.txt CompositionExclusions.txt
+//
+// Use the Unicode version of the Go release building the tokenizer, so the
+// letters the lexer accepts in names and the tables agree.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"go/format"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+func main() {
+	if len(os.Args) != 3 {
+		log.Fatal("usage: go run gen_nfc.go UnicodeData.txt CompositionExclusions.txt")
+	}
+	class := map[rune]uint8{}
+	decomp := map[rune][]rune{}
+	err := eachLine(os.Args[1], func(fields []string) error {
+		if len(fields) < 6 {
+			return fmt.Errorf("%d fields", len(fields))
+		}
+		r, err := parseRune(fields[0])
+		if err != nil {
+			return err
+		}
+		cc, err := strconv.ParseUint(fields[3], 10, 8)
+		if err != nil {
+			return err
+		}
+		if cc != 0 {
+			class[r] = uint8(cc)
+		}
+		// canonical mappings only: compatibility ones start with a <tag>
+		if d := fields[5]; d != "" && !strings.HasPrefix(d, "<") {
+			for _, f := range strings.Fields(d) {
+				m, err := parseRune(f)
+				if err != nil {
+					return err
+				}
+				decomp[r] = append(decomp[r], m)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	excluded := map[rune]bool{}
+	err = eachLine(os.Args[2], func(fields []string) error {
+		r, err := parseRune(fields[0])
+		excluded[r] = true
+		return err
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// primary composites: pairs whose composition is not excluded, neither
+	// from a singleton nor from a decomposition starting with a non-starter
+	compose := map[[2]rune]rune{}
+	for r, d := range decomp {
+		if len(d) == 2 && !excluded[r] && class[d[0]] == 0 && class[r] == 0 {
+			compose[[2]rune{d[0], d[1]}] = r
+		}
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintln(&b, "// Code generated by gen_nfc.go from UnicodeData.txt and CompositionExclusions.txt; DO NOT EDIT.")
+	fmt.Fprintln(&b)
+	fmt.Fprintln(&b, "package tokenizer")
+	fmt.Fprintln(&b)
+	fmt.Fprintln(&b, "// combiningClass gives the canonical combining class of the runes that")
+	fmt.Fprintln(&b, "// have one other than 0.")
+	fmt.Fprintln(&b, "var combiningClass = map[rune]uint8{")
+	for _, r := range sortedRunes(class) {
+		fmt.Fprintf(&b, "%#04x: %d,\n", r, class[r])
+	}
+	fmt.Fprintln(&b, "}")
+	fmt.Fprintln(&b)
+	fmt.Fprintln(&b, "// canonicalDecomp gives the canonical decomposition of a rune, one level")
+	fmt.Fprintln(&b, "// deep: its parts may decompose further. A singleton has 0 as its second.")
+	fmt.Fprintln(&b, "var canonicalDecomp = map[rune][2]rune{")
+	for _, r := range sortedRunes(decomp) {
+		d := append(decomp[r], 0)
+		fmt.Fprintf(&b, "%#04x: {%#04x, %#04x},\n", r, d[0], d[1])
+	}
+	fmt.Fprintln(&b, "}")
+	fmt.Fprintln(&b)
+	fmt.Fprintln(&b, "// canonicalComp gives the primary composite of a starter and the rune")
+	fmt.Fprintln(&b, "// that follows it. Hangul syllables are left to the algorithm.")
+	fmt.Fprintln(&b, "var canonicalComp = map[[2]rune]rune{")
+	pairs := make([][2]rune, 0, len(compose))
+	for p := range compose {
+		pairs = append(pairs, p)
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		return pairs[i][0] < pairs[j][0] || pairs[i][0] == pairs[j][0] && pairs[i][1] < pairs[j][1]
+	})
+	for _, p := range pairs {
+		fmt.Fprintf(&b, "{%#04x, %#04x}: %#04x,\n", p[0], p[1], compose[p])
+	}
+	fmt.Fprintln(&b, "}")
+
+	src, err := format.Source(b.Bytes())
+	if err != nil {
+		log.Fatalf("format generated code: %v", err)
+	}
+	if err := os.WriteFile("nfc_tables.go", src, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// eachLine calls fn with the ';'-separated fields of each line of a UCD
+// file, leaving out comments and blank lines.
+func eachLine(path string, fn func(fields []string) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	for n := 1; sc.Scan(); n++ {
+		line, _, _ := strings.Cut(sc.Text(), "#")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		fields := strings.Split(line, ";")
+		for i := range fields {
+			fields[i] = strings.TrimSpace(fields[i])
+		}
+		if err := fn(fields); err != nil {
+			return fmt.Errorf("%s:%d: %v", path, n, err)
+		}
+	}
+	return sc.Err()
+}
+
+func parseRune(s string) (rune, error) {
+	n, err := strconv.ParseUint(s, 16, 32)
+	return rune(n), err
+}
+
+func sortedRunes[V any](m map[rune]V) []rune {
+	keys := make([]rune, 0, len(m))
+	for r := range m {
+		keys = append(keys, r)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	return keys
+}
@@ -0,0 +1,146 @@
+//go:build ignore
+
+// gen_tokens generates tokens_gen.go from tokenspec.json: the keyword and
+// type name tables, and the DFA that recognizes operators. Run it with
+// `go generate`.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/format"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+)
+
+type spec struct {
+	Keywords  map[string]string `json:"keywords"`
+	TypeNames []string          `json:"typeNames"`
+	Operators map[string]string `json:"operators"`
+}
+
+// state is a node of the operator trie; every trie is already a DFA.
+type state struct {
+	next   map[byte]int
+	accept string
+}
+
+func main() {
+	data, err := os.ReadFile("tokenspec.json")
+	if err != nil {
+		log.Fatal(err)
+	}
+	var sp spec
+	if err := json.Unmarshal(data, &sp); err != nil {
+		log.Fatalf("tokenspec.json: %v", err)
+	}
+
+	// build the trie breadth-first so state numbers grow with depth
+	ops := make([]string, 0, len(sp.Operators))
+	for op := range sp.Operators {
+		if op == "" {
+			log.Fatal("tokenspec.json: empty operator")
+		}
+		for i := 0; i < len(op); i++ {
+			if op[i] >= 0x80 {
+				log.Fatalf("tokenspec.json: operator %q is not ASCII", op)
+			}
+		}
+		ops = append(ops, op)
+	}
+	sort.Slice(ops, func(i, j int) bool {
+		return len(ops[i]) < len(ops[j]) || len(ops[i]) == len(ops[j]) && ops[i] < ops[j]
+	})
+	states := []*state{{next: map[byte]int{}}}
+	for _, op := range ops {
+		s := 0
+		for i := 0; i < len(op); i++ {
+			n, ok := states[s].next[op[i]]
+			if !ok {
+				n = len(states)
+				states = append(states, &state{next: map[byte]int{}})
+				states[s].next[op[i]] = n
+			}
+			s = n
+		}
+		states[s].accept = sp.Operators[op]
+	}
+	if len(states) > 256 {
+		log.Fatalf("tokenspec.json: %d operator states do not fit in a uint8", len(states))
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintln(&b, "// Code generated by gen_tokens.go from tokenspec.json; DO NOT EDIT.")
+	fmt.Fprintln(&b)
+	fmt.Fprintln(&b, "package tokenizer")
+	fmt.Fprintln(&b)
+	fmt.Fprintln(&b, "// keywords maps each keyword, in lowercase, to its token type.")
+	fmt.Fprintln(&b, "var keywords = map[string]TokenType{")
+	for _, w := range sortedKeys(sp.Keywords) {
+		fmt.Fprintf(&b, "%q: %s,\n", w, sp.Keywords[w])
+	}
+	fmt.Fprintln(&b, "}")
+	fmt.Fprintln(&b)
+	fmt.Fprintln(&b, "// typeNames holds the predeclared type names, lexed as TYPE_NAME.")
+	fmt.Fprintln(&b, "var typeNames = map[string]struct{}{")
+	for _, n := range sp.TypeNames {
+		fmt.Fprintf(&b, "%q: {},\n", n)
+	}
+	fmt.Fprintln(&b, "}")
+	fmt.Fprintln(&b)
+	fmt.Fprintln(&b, "// opStates is the number of states of the operator DFA. State 0 is the")
+	fmt.Fprintln(&b, "// start state, so 0 in opNext means there is no transition.")
+	fmt.Fprintf(&b, "const opStates = %d\n", len(states))
+	fmt.Fprintln(&b)
+	fmt.Fprintln(&b, "// opNext is the transition table of the operator DFA, indexed by state")
+	fmt.Fprintln(&b, "// and ASCII byte.")
+	fmt.Fprintln(&b, "var opNext = [opStates][128]uint8{")
+	for i, s := range states {
+		if len(s.next) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "%d: {", i)
+		keys := make([]int, 0, len(s.next))
+		for c := range s.next {
+			keys = append(keys, int(c))
+		}
+		sort.Ints(keys)
+		for j, c := range keys {
+			if j > 0 {
+				b.WriteString(", ")
+			}
+			fmt.Fprintf(&b, "%s: %d", strconv.QuoteRune(rune(c)), s.next[byte(c)])
+		}
+		fmt.Fprintln(&b, "},")
+	}
+	fmt.Fprintln(&b, "}")
+	fmt.Fprintln(&b)
+	fmt.Fprintln(&b, "// opAccept gives the token type recognized in each accepting state.")
+	fmt.Fprintln(&b, "var opAccept = [opStates]TokenType{")
+	for i, s := range states {
+		if s.accept != "" {
+			fmt.Fprintf(&b, "%d: %s,\n", i, s.accept)
+		}
+	}
+	fmt.Fprintln(&b, "}")
+
+	src, err := format.Source(b.Bytes())
+	if err != nil {
+		log.Fatalf("format generated code: %v", err)
+	}
+	if err := os.WriteFile("tokens_gen.go", src, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
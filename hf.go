@@ -0,0 +1,149 @@
+package tokenizer
+
+import (
+	"encoding/json"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// The JSON of a HuggingFace tokenizers `tokenizer.json` file, as far as a
+// Vocab needs it. Fields the library expects but we leave unset are null.
+type hfTokenizer struct {
+	Version       string          `json:"version"`
+	Truncation    json.RawMessage `json:"truncation"`
+	Padding       json.RawMessage `json:"padding"`
+	AddedTokens   []hfAddedToken  `json:"added_tokens"`
+	Normalizer    json.RawMessage `json:"normalizer"`
+	PreTokenizer  interface{}     `json:"pre_tokenizer"`
+	PostProcessor json.RawMessage `json:"post_processor"`
+	Decoder       interface{}     `json:"decoder"`
+	Model         interface{}     `json:"model"`
+}
+
+type hfAddedToken struct {
+	ID         int    `json:"id"`
+	Content    string `json:"content"`
+	SingleWord bool   `json:"single_word"`
+	Lstrip     bool   `json:"lstrip"`
+	Rstrip     bool   `json:"rstrip"`
+	Normalized bool   `json:"normalized"`
+	Special    bool   `json:"special"`
+}
+
+type hfByteLevel struct {
+	Type           string `json:"type"` // "ByteLevel"
+	AddPrefixSpace bool   `json:"add_prefix_space"`
+	TrimOffsets    bool   `json:"trim_offsets"`
+	UseRegex       bool   `json:"use_regex"`
+}
+
+type hfSplit struct {
+	Type     string            `json:"type"` // "Split"
+	Pattern  map[string]string `json:"pattern"`
+	Behavior string            `json:"behavior"`
+	Invert   bool              `json:"invert"`
+}
+
+type hfBPEModel struct {
+	Type                    string         `json:"type"` // "BPE"
+	Dropout                 *float64       `json:"dropout"`
+	UnkToken                *string        `json:"unk_token"`
+	ContinuingSubwordPrefix *string        `json:"continuing_subword_prefix"`
+	EndOfWordSuffix         *string        `json:"end_of_word_suffix"`
+	FuseUnk                 bool           `json:"fuse_unk"`
+	ByteFallback            bool           `json:"byte_fallback"`
+	Vocab                   map[string]int `json:"vocab"`
+	Merges                  []string       `json:"merges"`
+}
+
+type hfWordLevelModel struct {
+	Type     string         `json:"type"` // "WordLevel"
+	Vocab    map[string]int `json:"vocab"`
+	UnkToken string         `json:"unk_token"`
+}
+
+// HuggingFace returns v as the tokenizer.json of the HuggingFace tokenizers
+// library, so `Tokenizer.from_file` loads it in Python.
+//
+// A BPE vocabulary becomes a byte-level BPE model, the bytes spelled with
+// the characters GPT-2 uses for them. The library applies the merges to the
+// whole input rather than to each lexical piece, so its ids can differ from
+// Encode's where a merge learned inside one token also matches across two;
+// decoding gives back the input either way. A lexical vocabulary becomes a
+// word-level model whose pre-tokenizer splits on an approximation of the
+// lexer: names, numbers, strings, characters and the operators, longest
+// first, with whitespace dropped.
+func (v *Vocab) HuggingFace() ([]byte, error) {
+	tk := hfTokenizer{Version: "1.0", AddedTokens: []hfAddedToken{}}
+	if v.bpe != nil {
+		bytes := byteLevelAlphabet()
+		spell := func(id int) string {
+			var b strings.Builder
+			for _, c := range v.bpe.Piece(id) {
+				b.WriteRune(bytes[c])
+			}
+			return b.String()
+		}
+		model := hfBPEModel{Type: "BPE", Vocab: map[string]int{}, Merges: make([]string, len(v.Merges))}
+		for id := 0; id < v.bpe.Size(); id++ {
+			// two merges can spell the same bytes; the map keeps the first id
+			if _, ok := model.Vocab[spell(id)]; !ok {
+				model.Vocab[spell(id)] = id
+			}
+		}
+		for i, m := range v.Merges {
+			model.Merges[i] = spell(m[0]) + " " + spell(m[1])
+		}
+		tk.PreTokenizer = hfByteLevel{Type: "ByteLevel"}
+		tk.Decoder = hfByteLevel{Type: "ByteLevel"}
+		tk.Model = model
+	} else {
+		model := hfWordLevelModel{Type: "WordLevel", Vocab: make(map[string]int, len(v.Tokens)), UnkToken: UnknownToken}
+		for id, s := range v.Tokens {
+			model.Vocab[s] = id
+		}
+		tk.AddedTokens = append(tk.AddedTokens, hfAddedToken{ID: 0, Content: UnknownToken, Special: true})
+		tk.PreTokenizer = hfSplit{Type: "Split", Pattern: map[string]string{"Regex": lexemePattern()}, Behavior: "Removed", Invert: true}
+		tk.Model = model
+	}
+	return json.MarshalIndent(tk, "", "  ")
+}
+
+// byteLevelAlphabet returns the printable character GPT-2's byte-level BPE
+// spells each byte with: the byte itself when it is a visible Latin-1
+// character, else one from U+0100 on.
+func byteLevelAlphabet() [256]rune {
+	var out [256]rune
+	n := 0
+	for b := 0; b < 256; b++ {
+		if b >= '!' && b <= '~' || b >= 0xA1 && b <= 0xAC || b >= 0xAE && b <= 0xFF {
+			out[b] = rune(b)
+		} else {
+			out[b] = rune(256 + n)
+			n++
+		}
+	}
+	return out
+}
+
+// lexemePattern returns a regular expression matching one lexeme of the
+// default language, approximately: the operators come from the lexer's own
+// table, longest first.
+func lexemePattern() string {
+	var ops []string
+	for op := range operatorTable() {
+		ops = append(ops, op)
+	}
+	sort.Strings(ops)
+	sort.SliceStable(ops, func(i, j int) bool { return len(ops[i]) > len(ops[j]) })
+	for i, op := range ops {
+		ops[i] = regexp.QuoteMeta(op)
+	}
+	return strings.Join(append([]string{
+		`"(?:[^"\\\n]|\\.)*"`,
+		`'(?:[^'\\\n]|\\.)*'`,
+		`[\p{L}_][\p{L}\p{N}_]*`,
+		`\p{N}[\p{L}\p{N}_.]*`,
+	}, append(ops, `\S`)...), "|")
+}
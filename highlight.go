@@ -0,0 +1,35 @@
+package tokenizer
+
+import (
+	"sort"
+	"strings"
+)
+
+// TokenCategory groups token types into the coarse classes used by the
+// highlighters: keyword, type, ident, literal, operator and comment.
+func TokenCategory(tt TokenType) string {
+	switch {
+	case tt == COMMENT, tt == DOC_COMMENT, tt == SHEBANG, tt == PRAGMA:
+		return "comment"
+	case strings.HasPrefix(string(tt), "KW_"), tt == ANNOTATION, tt == DIRECTIVE:
+		return "keyword"
+	case tt == TYPE_NAME:
+		return "type"
+	case tt == IDENT:
+		return "ident"
+	case tt == INT_LIT, tt == FLOAT_LIT, tt == STRING_LIT, tt == CHAR_LIT, tt == STRING_SEGMENT:
+		return "literal"
+	default:
+		return "operator"
+	}
+}
+
+// MergeTrivia returns toks and comments combined into a single slice
+// ordered by source offset.
+func MergeTrivia(toks, comments []Token) []Token {
+	all := make([]Token, 0, len(toks)+len(comments))
+	all = append(all, toks...)
+	all = append(all, comments...)
+	sort.SliceStable(all, func(i, j int) bool { return all[i].Offset < all[j].Offset })
+	return all
+}
@@ -0,0 +1,131 @@
+package tokenizer
+
+import (
+	"fmt"
+	"strings"
+)
+
+// tokenTypeIDs lists every token type; the id of a type is its index. New
+// types are only ever appended, so the ids stay stable.
+var tokenTypeIDs = []TokenType{
+	KW_PKG, KW_IMP, KW_DEF, KW_VAR, KW_CONS, KW_TYPE, KW_STRUCT, KW_INTERFACE,
+	KW_MAPPING, KW_CHANNEL, KW_J, KW_SELECT, KW_LATER, KW_RET, KW_IF, KW_ELSE,
+	KW_SWITCH, KW_CASE, KW_FALL, KW_FR, KW_RANGE, KW_BREAK, KW_CONTINUE,
+	KW_JOTO, KW_DFT, KW_PANIC, KW_RECOVER,
+	IDENT, INT_LIT, FLOAT_LIT, STRING_LIT, CHAR_LIT, TYPE_NAME,
+	STRING_SEGMENT, INTERP_START, INTERP_END,
+	LPAREN, RPAREN, LBRACE, RBRACE, LBRACK, RBRACK, COMMA, SEMI, COLON, DOT,
+	ASSIGN, DECL, PLUS, MINUS, STAR, SLASH, PERCENT, LT, GT, LE, GE, EQ, NE,
+	ANDAND, OROR, BAND, BOR, BXOR, SHL, SHR, ADDEQ, SUBEQ, MULEQ, DIVEQ,
+	MODEQ, ANDEQ, OREQ, XOREQ, SHLEQ, SHREQ,
+	CH_SEND, BANG, INC, DEC, ARROW, ELLIPSIS, RANGE_OP, QUESTION,
+	PIPE_FORWARD, POW, POWEQ,
+	ANNOTATION, DIRECTIVE, PRAGMA,
+	COMMENT, SHEBANG, DOC_COMMENT, WHITESPACE, SKIPPED,
+	FILE_BEGIN, FILE_END,
+}
+
+var typeIDs = func() map[TokenType]int {
+	m := make(map[TokenType]int, len(tokenTypeIDs))
+	for id, tt := range tokenTypeIDs {
+		m[tt] = id
+	}
+	return m
+}()
+
+// operatorTable returns the operators of the default language and their
+// types, read off the operator DFA.
+func operatorTable() map[string]TokenType {
+	ops := map[string]TokenType{}
+	var walk func(state int, prefix string)
+	walk = func(state int, prefix string) {
+		if opAccept[state] != "" {
+			ops[prefix] = opAccept[state]
+		}
+		for c, next := range opNext[state] {
+			if next != 0 {
+				walk(int(next), prefix+string(rune(c)))
+			}
+		}
+	}
+	walk(0, "")
+	return ops
+}
+
+// spellings maps the types that are always spelled the same, the keywords
+// and operators, to that spelling. KW_RECOVER is spelled "recover", the
+// shorter of its two.
+var spellings = func() map[TokenType]string {
+	m := map[TokenType]string{INTERP_START: "${", INTERP_END: "}"}
+	for w, tt := range keywords {
+		if s, ok := m[tt]; !ok || len(w) < len(s) {
+			m[tt] = w
+		}
+	}
+	for op, tt := range operatorTable() {
+		m[tt] = op
+	}
+	return m
+}()
+
+// TokenIDs maps toks to integer ids: the id of its type for a token spelled
+// as its type always is, such as a keyword or an operator, and otherwise,
+// for names and literals, len(tokenTypeIDs) plus the id of its lexeme in v.
+// A lexeme v lacks, or every one when v is nil, falls back to the id of its
+// type. v must be a lexical vocabulary.
+func TokenIDs(toks []Token, v *Vocab) []int {
+	ids := make([]int, len(toks))
+	for i, t := range toks {
+		id, ok := typeIDs[t.Type]
+		if !ok {
+			id = typeIDs[SKIPPED] // a type from a lexer spec
+		}
+		if s, fixed := spellings[t.Type]; v != nil && (!fixed || s != t.Lexeme) {
+			if vid := v.ids[t.Lexeme]; vid > 0 {
+				id = len(tokenTypeIDs) + vid
+			}
+		}
+		ids[i] = id
+	}
+	return ids
+}
+
+// TokensFromIDs is the inverse of TokenIDs with the same v: it returns the
+// tokens ids stand for, without positions. A type id gives the type's
+// spelling, or a placeholder such as <IDENT> for a type without one; a
+// vocabulary id gives its lexeme, typed by lexing it; an id neither knows
+// gives a SKIPPED placeholder such as <?9000>.
+func TokensFromIDs(ids []int, v *Vocab) []Token {
+	toks := make([]Token, len(ids))
+	for i, id := range ids {
+		var t Token
+		switch vid := id - len(tokenTypeIDs); {
+		case id >= 0 && id < len(tokenTypeIDs):
+			t.Type = tokenTypeIDs[id]
+			if s, ok := spellings[t.Type]; ok {
+				t.Lexeme = s
+			} else {
+				t.Lexeme = "<" + string(t.Type) + ">"
+			}
+		case v != nil && vid > 0 && vid < len(v.Tokens):
+			t = Token{Type: lexemeType(v.Tokens[vid]), Lexeme: v.Tokens[vid]}
+		default:
+			t = Token{Type: SKIPPED, Lexeme: fmt.Sprintf("<?%d>", id)}
+		}
+		toks[i] = t
+	}
+	return toks
+}
+
+// lexemeType returns the type of the token s lexes to, or, for a piece that
+// is no token alone, such as the "a${ of an interpolated string, a guess.
+func lexemeType(s string) TokenType {
+	toks, errs := NewLexer(s).LexAll()
+	switch {
+	case len(errs) == 0 && len(toks) == 1 && toks[0].Lexeme == s:
+		return toks[0].Type
+	case strings.HasPrefix(s, `"`) || strings.HasSuffix(s, `"`):
+		return STRING_SEGMENT
+	}
+	return IDENT
+}
@@ -0,0 +1,112 @@
+package tokenizer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+)
+
+// WriteJSON streams the document to w as JSON: what json.MarshalIndent(d,
+// "", "  ") produces, or json.Marshal(d) when compact is set. Only one token
+// is encoded in memory at a time, so memory use does not grow with the
+// token count.
+func (d *TokenDocument) WriteJSON(w io.Writer, compact bool) error {
+	bw := bufio.NewWriter(w)
+	enc := &elemEncoder{w: bw, compact: compact}
+	enc.field("{", "tokens")
+	enc.array(d.Tokens == nil, len(d.Tokens), func(i int) interface{} { return &d.Tokens[i] })
+	if len(d.AST) > 0 {
+		enc.field(",", "ast")
+		enc.value(d.AST, 1)
+	}
+	enc.field(",", "errors")
+	enc.array(d.Errors == nil, len(d.Errors), func(i int) interface{} { return d.Errors[i] })
+	if len(d.Files) > 0 {
+		enc.field(",", "files")
+		enc.array(false, len(d.Files), func(i int) interface{} { return d.Files[i] })
+	}
+	enc.newline(0)
+	bw.WriteString("}")
+	if enc.err != nil {
+		return enc.err
+	}
+	return bw.Flush()
+}
+
+// elemEncoder writes the fields of a top-level JSON object one element at
+// a time, reusing its buffers, and keeps the first error.
+type elemEncoder struct {
+	w        *bufio.Writer
+	compact  bool
+	raw, ind bytes.Buffer
+	err      error
+}
+
+// newline starts a line at the given nesting depth; compact JSON has none.
+func (e *elemEncoder) newline(depth int) {
+	if e.compact {
+		return
+	}
+	e.w.WriteString("\n")
+	for i := 0; i < depth; i++ {
+		e.w.WriteString("  ")
+	}
+}
+
+// field writes sep, which opens the object or separates two fields, and
+// the key of the next field.
+func (e *elemEncoder) field(sep, key string) {
+	e.w.WriteString(sep)
+	e.newline(1)
+	e.w.WriteString(`"` + key + `":`)
+	if !e.compact {
+		e.w.WriteString(" ")
+	}
+}
+
+// array writes n elements as a JSON array; null is what encoding/json
+// writes for a nil slice.
+func (e *elemEncoder) array(null bool, n int, elem func(i int) interface{}) {
+	switch {
+	case null:
+		e.w.WriteString("null")
+		return
+	case n == 0:
+		e.w.WriteString("[]")
+		return
+	}
+	e.w.WriteString("[")
+	enc := json.NewEncoder(&e.raw)
+	for i := 0; i < n && e.err == nil; i++ {
+		if i > 0 {
+			e.w.WriteString(",")
+		}
+		e.newline(2)
+		e.raw.Reset()
+		if e.err = enc.Encode(elem(i)); e.err != nil {
+			return
+		}
+		e.value(bytes.TrimSuffix(e.raw.Bytes(), []byte("\n")), 2)
+	}
+	e.newline(1)
+	e.w.WriteString("]")
+}
+
+// value writes the JSON value b, indented for the given nesting depth.
+func (e *elemEncoder) value(b []byte, depth int) {
+	if e.err != nil {
+		return
+	}
+	e.ind.Reset()
+	if e.compact {
+		e.err = json.Compact(&e.ind, b)
+	} else {
+		e.err = json.Indent(&e.ind, b, indentPrefix[:2*depth], "  ")
+	}
+	if e.err == nil {
+		e.w.Write(e.ind.Bytes())
+	}
+}
+
+const indentPrefix = "    "
@@ -0,0 +1,1463 @@
+// Package tokenizer lexes J Language source into tokens, with the
+// preprocessor, parser and token tools built on the lexer. The tokenizer
+// command in cmd/tokenizer is its command-line front end.
+package tokenizer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+//go:generate go run gen_tokens.go
+
+type TokenType string
+
+const (
+	// keywords (lowercase)
+	KW_PKG       TokenType = "KW_PKG"
+	KW_IMP       TokenType = "KW_IMP"
+	KW_DEF       TokenType = "KW_DEF"
+	KW_VAR       TokenType = "KW_VAR"
+	KW_CONS      TokenType = "KW_CONS"
+	KW_TYPE      TokenType = "KW_TYPE"
+	KW_STRUCT    TokenType = "KW_STRUCT"
+	KW_INTERFACE TokenType = "KW_INTERFACE"
+	KW_MAPPING   TokenType = "KW_MAPPING"
+	KW_CHANNEL   TokenType = "KW_CHANNEL"
+	KW_J         TokenType = "KW_J"
+	KW_SELECT    TokenType = "KW_SELECT"
+	KW_LATER     TokenType = "KW_LATER"
+	KW_RET       TokenType = "KW_RET"
+	KW_IF        TokenType = "KW_IF"
+	KW_ELSE      TokenType = "KW_ELSE"
+	KW_SWITCH    TokenType = "KW_SWITCH"
+	KW_CASE      TokenType = "KW_CASE"
+	KW_FALL      TokenType = "KW_FALL"
+	KW_FR        TokenType = "KW_FR"
+	KW_RANGE     TokenType = "KW_RANGE"
+	KW_BREAK     TokenType = "KW_BREAK"
+	KW_CONTINUE  TokenType = "KW_CONTINUE"
+	KW_JOTO      TokenType = "KW_JOTO"
+	KW_DFT       TokenType = "KW_DFT"
+	KW_PANIC     TokenType = "KW_PANIC"
+	KW_RECOVER   TokenType = "KW_RECOVER" // also accepts "recovery"
+
+	// identifiers & literals & type names
+	IDENT      TokenType = "IDENT"
+	INT_LIT    TokenType = "INT_LIT"
+	FLOAT_LIT  TokenType = "FLOAT_LIT"
+	STRING_LIT TokenType = "STRING_LIT"
+	CHAR_LIT   TokenType = "CHAR_LIT"
+	TYPE_NAME  TokenType = "TYPE_NAME"
+
+	// interpolated strings: "a${x}b" is STRING_SEGMENT INTERP_START IDENT
+	// INTERP_END STRING_SEGMENT
+	STRING_SEGMENT TokenType = "STRING_SEGMENT" // "a${  or  }b"  (lexeme without ${ and })
+	INTERP_START   TokenType = "INTERP_START"   // ${
+	INTERP_END     TokenType = "INTERP_END"     // }
+
+	// punctuation / operators
+	LPAREN TokenType = "LPAREN" // (
+	RPAREN TokenType = "RPAREN" // )
+	LBRACE TokenType = "LBRACE" // {
+	RBRACE TokenType = "RBRACE" // }
+	LBRACK TokenType = "LBRACK" // [
+	RBRACK TokenType = "RBRACK" // ]
+	COMMA  TokenType = "COMMA"  // ,
+	SEMI   TokenType = "SEMI"   // ;
+	COLON  TokenType = "COLON"  // :
+	DOT    TokenType = "DOT"    // .
+
+	ASSIGN  TokenType = "ASSIGN"  // =
+	DECL    TokenType = "DECL"    // :=
+	PLUS    TokenType = "PLUS"    // +
+	MINUS   TokenType = "MINUS"   // -
+	STAR    TokenType = "STAR"    // *
+	SLASH   TokenType = "SLASH"   // /
+	PERCENT TokenType = "PERCENT" // %
+	LT      TokenType = "LT"      // <
+	GT      TokenType = "GT"      // >
+	LE      TokenType = "LE"      // <=
+	GE      TokenType = "GE"      // >=
+	EQ      TokenType = "EQ"      // ==
+	NE      TokenType = "NE"      // !=
+	ANDAND  TokenType = "ANDAND"  // &&
+	OROR    TokenType = "OROR"    // ||
+	BAND    TokenType = "BAND"    // &
+	BOR     TokenType = "BOR"     // |
+	BXOR    TokenType = "BXOR"    // ^
+	SHL     TokenType = "SHL"     // <<
+	SHR     TokenType = "SHR"     // >>
+	ADDEQ   TokenType = "ADDEQ"   // +=
+	SUBEQ   TokenType = "SUBEQ"   // -=
+	MULEQ   TokenType = "MULEQ"   // *=
+	DIVEQ   TokenType = "DIVEQ"   // /=
+	MODEQ   TokenType = "MODEQ"   // %=
+	ANDEQ   TokenType = "ANDEQ"   // &=
+	OREQ    TokenType = "OREQ"    // |=
+	XOREQ   TokenType = "XOREQ"   // ^=
+	SHLEQ   TokenType = "SHLEQ"   // <<=
+	SHREQ   TokenType = "SHREQ"   // >>=
+
+	CH_SEND TokenType = "CH_SEND" // <-
+	BANG    TokenType = "BANG"    // !
+	INC     TokenType = "INC"     // ++
+	DEC     TokenType = "DEC"     // --
+	ARROW   TokenType = "ARROW"   // ->
+
+	ELLIPSIS TokenType = "ELLIPSIS" // ...
+	RANGE_OP TokenType = "RANGE_OP" // ..
+	QUESTION TokenType = "QUESTION" // ?
+
+	PIPE_FORWARD TokenType = "PIPE_FORWARD" // |>
+	POW          TokenType = "POW"          // **
+	POWEQ        TokenType = "POWEQ"        // **=
+
+	ANNOTATION TokenType = "ANNOTATION" // @name; its value is the name
+	DIRECTIVE  TokenType = "DIRECTIVE"  // #name arg... up to the end of the line
+	PRAGMA     TokenType = "PRAGMA"     // //#pragma:name arg...; its value is the name
+
+	// trivia (collected separately, not part of the token stream)
+	COMMENT TokenType = "COMMENT"
+	SHEBANG TokenType = "SHEBANG" // #!... on the first line
+	// DOC_COMMENT is a /// or /** */ comment; its text becomes the Doc of
+	// the declaration keyword right after it
+	DOC_COMMENT TokenType = "DOC_COMMENT"
+	// the text between tokens and comments, added by WithTrivia
+	WHITESPACE TokenType = "WHITESPACE" // blanks and line breaks
+	SKIPPED    TokenType = "SKIPPED"    // input the lexer rejected
+
+	// pseudo-tokens delimiting each source in a LexSources stream
+	FILE_BEGIN TokenType = "FILE_BEGIN" // the lexeme is the source's name
+	FILE_END   TokenType = "FILE_END"
+)
+
+type Token struct {
+	Type     TokenType `json:"type"`
+	Lexeme   string    `json:"lexeme"`
+	Line     int       `json:"line"`
+	Column   int       `json:"col"`
+	Offset   int       `json:"offset"` // byte offset of the first byte
+	End      int       `json:"end"`    // byte offset just past the last byte
+	IntVal   *int64    `json:"intVal,omitempty"`
+	FloatVal *float64  `json:"floatVal,omitempty"`
+	Value    *string   `json:"value,omitempty"`   // decoded contents of string and char literals; on a name not in NFC, its NFC form
+	RuneVal  *int32    `json:"runeVal,omitempty"` // code point (or byte, for \x and octal escapes) of a char literal
+	BigVal   *string   `json:"bigVal,omitempty"`  // decimal value of an integer literal too large for IntVal
+	Arg      *string   `json:"arg,omitempty"`     // argument text of a directive; its value is the name
+	Cols     *Columns  `json:"cols,omitempty"`    // column in every unit, in verbose position mode
+	AsIdent  bool      `json:"asIdent,omitempty"` // a contextual keyword, which may also be used as a name
+	File     int       `json:"file,omitempty"`    // index into the report's file table; 0 is the first file
+	Doc      *string   `json:"doc,omitempty"`     // on def, type, var and cons: the doc comment text before it
+	Match    *int      `json:"match,omitempty"`   // on a bracket: the index of its matching one, see MatchBrackets
+	// Expansion is the macro call this token was expanded from, if any
+	Expansion *MacroSite `json:"expansion,omitempty"`
+}
+
+// MacroSite is where a macro was called: the outermost call when macros
+// expand to other macro calls.
+type MacroSite struct {
+	Macro string `json:"macro"`
+	File  int    `json:"file,omitempty"`
+	Line  int    `json:"line"`
+	Col   int    `json:"col"`
+}
+
+// Pos is a line and column, such as the position of the first token of an
+// AST node.
+type Pos struct {
+	Line int `json:"line"`
+	Col  int `json:"col"`
+}
+
+// Position returns the position itself; embedding Pos makes a struct a
+// parser.Node.
+func (p Pos) Position() Pos { return p }
+
+// Diagnostic is the structured form of an error. Offset and End are byte
+// offsets delimiting the offending text; Phase is "lexical" or "syntax".
+type Diagnostic struct {
+	Phase   string `json:"phase"`
+	Code    string `json:"code"` // stable identifier such as E0004, for --suppress
+	Line    int    `json:"line"`
+	Col     int    `json:"col"`
+	Offset  int    `json:"offset"`
+	End     int    `json:"end"`
+	Message string `json:"message"`
+	File    int    `json:"file,omitempty"` // index into the report's file table, like Token.File
+	Notes   []Note `json:"notes,omitempty"`
+}
+
+// Note adds context to a diagnostic, such as the macro expansion the error
+// happened in.
+type Note struct {
+	File    string `json:"file"`
+	Line    int    `json:"line"`
+	Col     int    `json:"col"`
+	Message string `json:"message"`
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%s error at %d:%d: %s", d.Phase, d.Line, d.Col, d.Message)
+}
+
+type Lexer struct {
+	src      string // scanned as UTF-8 bytes; runes are decoded on demand
+	i        int    // byte offset of the next rune
+	start    int    // byte offset where the current token began
+	line     int
+	col      int // rune column of src[i]
+	length   int
+	tokens   []Token
+	comments []Token
+	errors   []string
+	diags    []Diagnostic
+	filter   ErrorFilter
+
+	interp []interpFrame // open ${ ... } interpolations, innermost last
+
+	spanMode bool // record SpanTokens in spans instead of Tokens
+	spans    []SpanToken
+
+	contextual map[TokenType]bool // keywords tagged AsIdent
+	spec       *compiledSpec      // a language loaded with SetSpec; nil for the built-in one
+	file       int                // stamped on tokens and diagnostics as their File
+
+	// continuation enables `\` line continuations; joins are the `\` and
+	// line break of each one seen, and joinedLines counts those whose
+	// line number the next real line break still has to catch up on
+	continuation bool
+	joins        [][2]int
+	joinedLines  int
+
+	columnUnit ColumnUnit // unit of the columns LexAll reports; runes when empty
+	verbosePos bool       // also attach Cols to every token
+	tabWidth   int        // columns between tab stops; 0 counts a tab as one
+	rawNames   bool       // leave names unnormalized (see SetNameNormalization)
+
+	withComments bool  // LexAll merges the comments into the tokens
+	optErr       error // of the first option that failed
+
+	limits  Limits
+	stopped bool // past limits.MaxTokens: the rest of the input is left out
+
+	// done is the Done channel of LexAllContext's context, looked at by
+	// advance every cancelCheckBytes; cancelled is set when it was closed
+	// and the input was cut at the cursor
+	done      <-chan struct{}
+	checkAt   int
+	cancelled bool
+
+	progress   func(Progress) // see SetProgress
+	progressAt int            // offset of the next progress report
+}
+
+// interpFrame is an open string interpolation: where its ${ started, and
+// how many '{' inside it are still unclosed.
+type interpFrame struct {
+	line, col, offset int
+	depth             int
+}
+
+// NewLexer returns a lexer for input, configured by opts.
+func NewLexer(input string, opts ...Option) *Lexer {
+	lx := &Lexer{
+		src: input, length: len(input),
+		line: 1, col: 1,
+	}
+	for _, opt := range opts {
+		if err := opt(lx); err != nil && lx.optErr == nil {
+			lx.optErr = err
+		}
+	}
+	return lx
+}
+
+// Reset makes lx ready to lex src, keeping its options and the capacity of
+// its token, comment and error slices, so a tool lexing many small snippets
+// does not allocate a lexer for each. The tokens, comments and errors it
+// returned before are overwritten: copy what must outlive the next LexAll.
+func (lx *Lexer) Reset(src string) {
+	lx.src, lx.length = src, len(src)
+	lx.i, lx.start = 0, 0
+	lx.line, lx.col = 1, 1
+	lx.tokens = lx.tokens[:0]
+	lx.comments = lx.comments[:0]
+	lx.errors = lx.errors[:0]
+	lx.diags = lx.diags[:0]
+	lx.filter.kept, lx.filter.omitted = 0, 0
+	lx.interp = lx.interp[:0]
+	lx.spans = lx.spans[:0]
+	lx.joins = lx.joins[:0]
+	lx.joinedLines = 0
+	lx.stopped = false
+	lx.done, lx.checkAt, lx.cancelled = nil, 0, false
+}
+
+// Lex lexes src with a lexer configured by opts and returns its tokens and
+// errors: a shorthand for NewLexer(src, opts...).LexAll(), with structured
+// errors.
+func Lex(src string, opts ...Option) ([]Token, []Diagnostic) {
+	lx := NewLexer(src, opts...)
+	toks, _ := lx.LexAll()
+	return toks, lx.Diagnostics()
+}
+
+// LexFile lexes the file at path like Lex. The error is about reading the
+// file; lexical errors are in the diagnostics.
+func LexFile(path string, opts ...Option) ([]Token, []Diagnostic, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("read file error: %w", err)
+	}
+	toks, diags := Lex(string(data), opts...)
+	return toks, diags, nil
+}
+
+// eof is what peek returns past the end of the input. It is not a valid
+// rune, so a NUL byte in the source is lexed like any other character.
+const eof rune = -1
+
+// isLineEnd reports whether ch ends a line: a line feed, a carriage return
+// (alone, as in classic Mac files, or before a line feed) or the end of the
+// input.
+func isLineEnd(ch rune) bool {
+	return ch == '\n' || ch == '\r' || ch == eof
+}
+
+// LineStartOf returns the byte offset at which the line holding byte offset
+// off begins; \n, \r\n and a lone \r all end lines.
+func LineStartOf(src string, off int) int {
+	return strings.LastIndexAny(src[:off], "\r\n") + 1
+}
+
+// LineEndOf returns the byte offset of the line break ending the line that
+// holds byte offset off, or len(src) on the last line.
+func LineEndOf(src string, off int) int {
+	if i := strings.IndexAny(src[off:], "\r\n"); i >= 0 {
+		return off + i
+	}
+	return len(src)
+}
+
+// CountLineBreaks counts the line breaks in s, a \r\n counting once.
+func CountLineBreaks(s string) int {
+	return strings.Count(s, "\n") + strings.Count(s, "\r") - strings.Count(s, "\r\n")
+}
+
+// EndLine returns the line on which t's lexeme ends.
+func EndLine(t Token) int {
+	return t.Line + CountLineBreaks(t.Lexeme)
+}
+
+// LineBreak reports whether next starts on a later line than t ends, or in
+// another (included) file. The tokens of a macro expansion count as on
+// the line of the macro call.
+func LineBreak(t, next Token) bool {
+	file, end := t.File, EndLine(t)
+	if e := t.Expansion; e != nil {
+		file, end = e.File, e.Line
+	}
+	nextFile, nextLine := next.File, next.Line
+	if e := next.Expansion; e != nil {
+		nextFile, nextLine = e.File, e.Line
+	}
+	return nextFile != file || nextLine > end
+}
+
+// SourceLines splits src at its line breaks, leaving them out; text after
+// the last one, even none, is a line too.
+func SourceLines(src string) []string {
+	var lines []string
+	for off := 0; ; {
+		end := LineEndOf(src, off)
+		lines = append(lines, src[off:end])
+		if end == len(src) {
+			return lines
+		}
+		off = end + 1
+		if src[end] == '\r' && off < len(src) && src[off] == '\n' {
+			off++
+		}
+	}
+}
+
+// peek returns the rune n runes ahead of the cursor, or eof past the end of
+// the input.
+func (lx *Lexer) peek(n int) rune {
+	j := lx.i
+	for {
+		if j >= lx.length {
+			return eof
+		}
+		if b := lx.src[j]; b < utf8.RuneSelf {
+			if n == 0 {
+				return rune(b)
+			}
+			j++
+		} else {
+			r, w := utf8.DecodeRuneInString(lx.src[j:])
+			if n == 0 {
+				return r
+			}
+			j += w
+		}
+		n--
+	}
+}
+func (lx *Lexer) advance() rune {
+	if lx.i >= lx.length {
+		return 0
+	}
+	ch, w := rune(lx.src[lx.i]), 1
+	if ch >= utf8.RuneSelf {
+		ch, w = utf8.DecodeRuneInString(lx.src[lx.i:])
+		if ch == utf8.RuneError && w == 1 {
+			// each bad byte reads as one U+FFFD, wherever it appears
+			from, l, c := lx.i, lx.line, lx.col
+			lx.i++
+			lx.col++
+			lx.errorFrom(l, c, from, ErrInvalidUTF8, fmt.Sprintf("invalid UTF-8 byte 0x%02X at offset %d", lx.src[from], from))
+			return ch
+		}
+		if IsBidiControl(ch) {
+			// reported wherever it is, strings and comments included
+			lx.errorFrom(lx.line, lx.col, lx.i, ErrBidiControl, bidiMessage(ch))
+		}
+	}
+	lx.i += w
+	// the \r of a \r\n is the end of its line; the \n starts the next
+	if ch == '\n' || ch == '\r' && (lx.i == lx.length || lx.src[lx.i] != '\n') {
+		lx.line += 1 + lx.joinedLines
+		lx.joinedLines = 0
+		lx.col = 1
+	} else {
+		lx.col++
+	}
+	if lx.i >= lx.checkAt {
+		lx.checkCancel()
+	}
+	return ch
+}
+
+// checkCancel looks at done. Once it is closed the input is cut at the
+// cursor, like MaxInput cuts it, so the comment or string being scanned,
+// however long, ends there.
+func (lx *Lexer) checkCancel() {
+	if lx.done == nil {
+		lx.checkAt = int(^uint(0) >> 1)
+		return
+	}
+	lx.checkAt = lx.i + cancelCheckBytes
+	select {
+	case <-lx.done:
+		lx.cancelled = true
+		lx.src, lx.length = lx.src[:lx.i], lx.i
+	default:
+	}
+}
+func (lx *Lexer) add(tt TokenType, lex string, l, c int, iv *int64, fv *float64) {
+	if lx.spanMode {
+		lx.spans = append(lx.spans, SpanToken{Type: tt, Offset: lx.start, Len: lx.i - lx.start, Line: l, Column: c})
+		return
+	}
+	lex = lx.limitLexeme(lex, l, c, lx.start)
+	lx.tokens = append(lx.tokens, Token{Type: tt, Lexeme: lex, Line: l, Column: c, Offset: lx.start, End: lx.i, IntVal: iv, FloatVal: fv, File: lx.file})
+	lx.countToken(len(lx.tokens), l, c)
+}
+
+// addValue adds a literal token together with its decoded value.
+func (lx *Lexer) addValue(tt TokenType, lex string, l, c int, val string) {
+	lx.add(tt, lex, l, c, nil, nil)
+	if !lx.spanMode {
+		v := cutLexeme(val, lx.maxLexeme()) // a copy, so span mode does not move val to the heap
+		lx.tokens[len(lx.tokens)-1].Value = &v
+	}
+}
+
+// addInt adds an INT_LIT with its value. Literals that do not fit in an
+// int64 are reported and keep their exact value, in decimal, in BigVal.
+func (lx *Lexer) addInt(lex string, l, c int) {
+	lx.add(INT_LIT, lex, l, c, nil, nil)
+	if lx.spanMode {
+		return
+	}
+	digits := strings.ReplaceAll(lex, "_", "")
+	base := 10
+	if len(digits) > 1 && digits[0] == '0' && strings.IndexByte("xXbBoO", digits[1]) >= 0 {
+		base = 0 // strconv reads the prefix
+	}
+	tok := &lx.tokens[len(lx.tokens)-1]
+	if v, err := strconv.ParseInt(digits, base, 64); err == nil {
+		tok.IntVal = &v
+		return
+	}
+	if n, ok := new(big.Int).SetString(digits, base); ok {
+		s := n.String()
+		tok.BigVal = &s
+	}
+	lx.errorAt(l, c, ErrIntOverflow, "integer literal overflows int64")
+}
+
+// addComment records a comment that started at byte offset start.
+func (lx *Lexer) addComment(lex string, l, c, start int) {
+	if lx.spanMode {
+		return
+	}
+	tt := COMMENT
+	if lx.spec == nil && isDocComment(lex) {
+		tt = DOC_COMMENT
+	}
+	lex = lx.limitLexeme(lex, l, c, start)
+	lx.comments = append(lx.comments, Token{Type: tt, Lexeme: lex, Line: l, Column: c, Offset: start, End: lx.i, File: lx.file})
+}
+
+// errorAt reports an error for the text consumed since lx.start. If nothing
+// has been consumed yet the span covers the current character.
+func (lx *Lexer) errorAt(l, c int, code, msg string) {
+	lx.errorFrom(l, c, lx.start, code, msg)
+}
+
+// errorFrom is errorAt for a span starting at byte offset from, used for
+// errors inside a token such as a bad escape in a string.
+func (lx *Lexer) errorFrom(l, c, from int, code, msg string) {
+	end := lx.i
+	if end <= from {
+		end = from
+		if ch := lx.peek(0); ch != eof {
+			end += utf8.RuneLen(ch)
+		}
+	}
+	if !lx.filter.Admit(code) {
+		return
+	}
+	d := Diagnostic{Phase: "lexical", Code: code, Line: l, Col: c, Offset: from, End: end, Message: msg, File: lx.file}
+	lx.diags = append(lx.diags, d)
+	lx.errors = append(lx.errors, d.String())
+}
+
+func (lx *Lexer) isIdentStart(r rune) bool {
+	return r == '_' || unicode.IsLetter(r)
+}
+
+// isIdentPart accepts combining marks after the first rune, so a letter
+// may be written as a base letter and an accent (see NFC).
+func (lx *Lexer) isIdentPart(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.In(r, unicode.Mn, unicode.Mc)
+}
+
+func (lx *Lexer) skipWSAndComments() {
+	if lx.i == 0 && strings.HasPrefix(lx.src, "#!") {
+		// #!/usr/bin/env jl makes a script executable; keep it as trivia
+		for !isLineEnd(lx.peek(0)) {
+			lx.advance()
+		}
+		lx.addComment(lx.src[:lx.i], 1, 1, 0)
+		if !lx.spanMode {
+			lx.comments[len(lx.comments)-1].Type = SHEBANG
+		}
+	}
+	for {
+		ch := lx.peek(0)
+		// whitespace
+		if ch == ' ' || ch == '\t' || ch == '\r' || ch == '\n' {
+			lx.advance()
+			continue
+		}
+		if ch == '\\' && lx.continuation && lx.skipContinuation() {
+			continue
+		}
+		// comments
+		if ch == '/' {
+			n := lx.peek(1)
+			startLine, startCol, startOff := lx.line, lx.col, lx.i
+			// line comment
+			if n == '/' {
+				for !isLineEnd(lx.peek(0)) {
+					lx.advance()
+				}
+				if lx.spec == nil && strings.HasPrefix(lx.src[startOff:lx.i], pragmaPrefix) {
+					lx.addPragma(startLine, startCol, startOff)
+					continue
+				}
+				lx.addComment(lx.src[startOff:lx.i], startLine, startCol, startOff)
+				continue
+			}
+			// nested block comment
+			if n == '*' {
+				lx.advance()
+				lx.advance()
+				depth := 1
+				for depth > 0 {
+					c := lx.peek(0)
+					if c == eof {
+						lx.start = startOff
+						lx.errorAt(startLine, startCol, ErrUnterminatedComment, "unterminated block comment")
+						lx.addComment(lx.src[startOff:lx.i], startLine, startCol, startOff)
+						return
+					}
+					if c == '/' && lx.peek(1) == '*' {
+						lx.advance()
+						lx.advance()
+						depth++
+						continue
+					}
+					if c == '*' && lx.peek(1) == '/' {
+						lx.advance()
+						lx.advance()
+						depth--
+						continue
+					}
+					lx.advance()
+				}
+				lx.addComment(lx.src[startOff:lx.i], startLine, startCol, startOff)
+				continue
+			}
+		}
+		break
+	}
+}
+
+// keywordsByLen holds the keywords grouped by length, so lookupKeyword only
+// compares lex against the handful of keywords as long as it is.
+var keywordsByLen = func() (byLen [maxKeywordLen + 1][]keywordEntry) {
+	for word, t := range keywords {
+		byLen[len(word)] = append(byLen[len(word)], keywordEntry{word, t})
+	}
+	return byLen
+}()
+
+const maxKeywordLen = 9 // "interface"
+
+type keywordEntry struct {
+	word string
+	tt   TokenType
+}
+
+// lookupKeyword matches lex against keywords case-insensitively. It never
+// allocates or hashes: candidates are picked by length and compared byte by
+// byte, and since every keyword is lowercase ASCII letters, b|0x20 folds the
+// case of a byte exactly where it can matter.
+func lookupKeyword(lex string) (TokenType, bool) {
+	if len(lex) > maxKeywordLen {
+		return "", false
+	}
+next:
+	for _, kw := range keywordsByLen[len(lex)] {
+		for i := 0; i < len(lex); i++ {
+			if lex[i]|0x20 != kw.word[i] {
+				continue next
+			}
+		}
+		return kw.tt, true
+	}
+	return "", false
+}
+
+// SetFile sets the index, into the file table of a report covering several
+// files, that LexAll stamps on every token and diagnostic as File.
+func (lx *Lexer) SetFile(index int) {
+	lx.file = index
+}
+
+// SetLine numbers the lines of the source from n rather than 1, for a
+// source that continues an earlier one, as each input of the REPL does.
+// Reset numbers them from 1 again.
+func (lx *Lexer) SetLine(n int) {
+	lx.line = n
+}
+
+// SetContextualKeywords makes the named keywords contextual: they are still
+// lexed as keywords but tagged AsIdent, so the parser may take them as names
+// where a keyword would make no sense, as in `type := 1`.
+func (lx *Lexer) SetContextualKeywords(words ...string) error {
+	lx.contextual = map[TokenType]bool{}
+	for _, w := range words {
+		t, ok := lookupKeyword(w)
+		if !ok {
+			return fmt.Errorf("%q is not a keyword", w)
+		}
+		lx.contextual[t] = true
+	}
+	return nil
+}
+
+// SetLineContinuation enables the dialect in which a `\` right before a
+// line break continues the line: both are skipped as trivia, and the tokens
+// after them keep the line and count the columns of the joined logical
+// line. Lines after it are numbered as in the file again.
+func (lx *Lexer) SetLineContinuation(on bool) {
+	lx.continuation = on
+}
+
+// Continuations returns the byte offsets [from, to) of each `\` and the
+// line break after it that a lexer with SetLineContinuation skipped, in
+// order, for VerifyRoundTrip.
+func (lx *Lexer) Continuations() [][2]int {
+	return lx.joins
+}
+
+// skipContinuation skips a `\` at the cursor followed by a line break, if
+// there is one, without moving to a new line.
+func (lx *Lexer) skipContinuation() bool {
+	n := 0
+	switch {
+	case lx.peek(1) == '\r' && lx.peek(2) == '\n':
+		n = 3
+	case lx.peek(1) == '\n' || lx.peek(1) == '\r':
+		n = 2
+	default:
+		return false
+	}
+	from, l, c := lx.i, lx.line, lx.col
+	lx.i += n
+	lx.line, lx.col = l, c
+	lx.joinedLines++
+	lx.joins = append(lx.joins, [2]int{from, lx.i})
+	return true
+}
+
+// ---------- scans ----------
+func (lx *Lexer) scanIdentOrKeyword() {
+	l, c := lx.line, lx.col
+	start := lx.i
+	for lx.isIdentPart(lx.peek(0)) {
+		lx.advance()
+	}
+	lex := lx.src[start:lx.i]
+	if t, ok := lookupKeyword(lex); ok {
+		lx.add(t, lex, l, c, nil, nil)
+		if lx.contextual[t] && !lx.spanMode {
+			lx.tokens[len(lx.tokens)-1].AsIdent = true
+		}
+		return
+	}
+	if _, ok := typeNames[lex]; ok {
+		lx.add(TYPE_NAME, lex, l, c, nil, nil)
+		return
+	}
+	lx.add(IDENT, lex, l, c, nil, nil)
+	lx.normalizeName()
+}
+
+func validUnderscores(s string) bool {
+	if s == "" {
+		return false
+	}
+	if s[0] == '_' || s[len(s)-1] == '_' {
+		return false
+	}
+	if strings.Contains(s, "__") {
+		return false
+	}
+	bad := []string{"_.", "._", "e_", "_e", "E_", "_E", "x_", "_x", "X_", "_X", "b_", "_b", "B_", "_B", "o_", "_o", "O_", "_O"}
+	for _, p := range bad {
+		if strings.Contains(s, p) {
+			return false
+		}
+	}
+	return true
+}
+
+func (lx *Lexer) scanNumber() {
+	l, c := lx.line, lx.col
+	start := lx.i
+
+	// base-prefixed
+	if lx.peek(0) == '0' && (lx.peek(1) == 'x' || lx.peek(1) == 'X' || lx.peek(1) == 'b' || lx.peek(1) == 'B' || lx.peek(1) == 'o' || lx.peek(1) == 'O') {
+		base := lx.peek(1)
+		lx.advance()
+		lx.advance()
+		var count int
+		for {
+			ch := lx.peek(0)
+			if ch == '_' || unicode.IsDigit(ch) || (base == 'x' || base == 'X') && ((ch >= 'a' && ch <= 'f') || (ch >= 'A' && ch <= 'F')) || (base == 'b' || base == 'B') && (ch == '0' || ch == '1') || (base == 'o' || base == 'O') && (ch >= '0' && ch <= '7') {
+				lx.advance()
+				count++
+			} else {
+				break
+			}
+		}
+		body := lx.src[start+2 : lx.i]
+		if count == 0 || !validUnderscores(body) {
+			msg := "invalid numeric literal"
+			switch base {
+			case 'x', 'X':
+				msg = "invalid hex literal"
+			case 'b', 'B':
+				msg = "invalid binary literal"
+			case 'o', 'O':
+				msg = "invalid octal literal"
+			}
+			lx.errorAt(l, c, ErrInvalidNumber, msg)
+			return
+		}
+		lx.addInt(lx.src[start:lx.i], l, c)
+		return
+	}
+
+	// decimal / float
+	for unicode.IsDigit(lx.peek(0)) || lx.peek(0) == '_' {
+		lx.advance()
+	}
+	isFloat := false
+	if lx.peek(0) == '.' && unicode.IsDigit(lx.peek(1)) {
+		isFloat = true
+		lx.advance()
+		for unicode.IsDigit(lx.peek(0)) || lx.peek(0) == '_' {
+			lx.advance()
+		}
+	}
+	if lx.peek(0) == 'e' || lx.peek(0) == 'E' {
+		isFloat = true
+		lx.advance()
+		if lx.peek(0) == '+' || lx.peek(0) == '-' {
+			lx.advance()
+		}
+		if !unicode.IsDigit(lx.peek(0)) {
+			lx.errorAt(l, c, ErrInvalidNumber, "invalid float exponent")
+			return
+		}
+		for unicode.IsDigit(lx.peek(0)) || lx.peek(0) == '_' {
+			lx.advance()
+		}
+	}
+	lex := lx.src[start:lx.i]
+	if !validUnderscores(lex) {
+		lx.errorAt(l, c, ErrInvalidNumber, "illegal underscore placement in number")
+		return
+	}
+	if isFloat || strings.ContainsAny(lex, ".eE") {
+		lx.add(FLOAT_LIT, lex, l, c, nil, nil)
+	} else {
+		lx.addInt(lex, l, c)
+	}
+}
+
+func (lx *Lexer) scanString() {
+	l, c := lx.line, lx.col
+	lx.advance() // "
+	lx.scanStringBody(l, c, STRING_LIT)
+}
+
+// scanStringBody scans string contents up to and including the closing
+// quote. The token began at lx.start (line l, column c) with either the
+// opening quote or, when resuming after an interpolation, nothing at all.
+// At "${" it emits the text so far as a STRING_SEGMENT followed by
+// INTERP_START and returns; nextToken resumes the string after the
+// matching '}'. tt is the type to use if the string ends without reaching
+// another "${".
+func (lx *Lexer) scanStringBody(l, c int, tt TokenType) {
+	start, body := lx.start, lx.i
+	// val is only built once an escape shows up; until then the value is
+	// a slice of the source.
+	var val strings.Builder
+	escaped := false
+	value := func(end int) string {
+		if escaped {
+			return val.String()
+		}
+		return lx.src[body:end]
+	}
+	for {
+		ch := lx.peek(0)
+		if isLineEnd(ch) {
+			lx.errorAt(l, c, ErrUnterminatedString, "unterminated string literal")
+			return
+		}
+		if ch == '\\' {
+			if isLineEnd(lx.peek(1)) {
+				lx.advance()
+				lx.errorAt(l, c, ErrUnterminatedString, "unterminated string escape")
+				return
+			}
+			if !escaped && !lx.spanMode {
+				escaped = true
+				val.WriteString(lx.src[body:lx.i])
+			}
+			if escaped {
+				lx.scanEscape(&val)
+			} else {
+				lx.scanEscape(nil)
+			}
+			continue
+		}
+		if ch == '$' && lx.peek(1) == '{' {
+			lx.addValue(STRING_SEGMENT, lx.src[start:lx.i], l, c, value(lx.i))
+			lx.start = lx.i
+			lx.interp = append(lx.interp, interpFrame{line: lx.line, col: lx.col, offset: lx.i})
+			l, c = lx.line, lx.col
+			lx.advance()
+			lx.advance()
+			lx.add(INTERP_START, "${", l, c, nil, nil)
+			return
+		}
+		from := lx.i
+		lx.advance()
+		if ch == '"' {
+			break
+		}
+		if escaped {
+			val.WriteString(lx.src[from:lx.i])
+		}
+	}
+	lx.addValue(tt, lx.src[start:lx.i], l, c, value(lx.i-1))
+}
+
+// scanEscape consumes one escape sequence starting at the backslash under
+// the cursor, appends its decoded form to val (if not nil) and returns it.
+// Malformed and unknown escapes are reported at the escape itself and
+// decode to U+FFFD.
+//
+//	\n \t \r \\ \" \'    the usual characters
+//	\$                 a dollar sign, so "\${" is not an interpolation
+//	\0                 NUL
+//	\ooo \xHH          one byte, in octal (at most \377) or hex
+//	\uXXXX \UXXXXXXXX  one Unicode code point
+func (lx *Lexer) scanEscape(val *strings.Builder) rune {
+	l, c, from := lx.line, lx.col, lx.i
+	lx.advance() // backslash
+	ch := lx.advance()
+	r := utf8.RuneError
+	switch ch {
+	case 'n':
+		r = '\n'
+	case 't':
+		r = '\t'
+	case 'r':
+		r = '\r'
+	case '\\', '"', '\'', '$':
+		r = ch
+	case '0', '1', '2', '3', '4', '5', '6', '7':
+		v, n := uint32(ch-'0'), 1
+		for ; n < 3 && '0' <= lx.peek(0) && lx.peek(0) <= '7'; n++ {
+			v = v<<3 | uint32(lx.advance()-'0')
+		}
+		switch {
+		case n == 1 && ch == '0':
+			r = 0
+		case n < 3:
+			lx.errorFrom(l, c, from, ErrInvalidEscape, "invalid octal escape: needs 3 octal digits")
+		case v > 0xFF:
+			lx.errorFrom(l, c, from, ErrInvalidEscape, fmt.Sprintf("invalid octal escape: \\%o is above \\377", v))
+		default:
+			if val != nil {
+				val.WriteByte(byte(v))
+			}
+			return rune(v)
+		}
+	case 'x':
+		v, n := byte(0), 0
+		for ; n < 2 && isHexDigit(lx.peek(0)); n++ {
+			v = v<<4 | byte(hexValue(lx.advance()))
+		}
+		if n < 2 {
+			lx.errorFrom(l, c, from, ErrInvalidEscape, "invalid hex escape: \\x needs 2 hex digits")
+			break
+		}
+		if val != nil {
+			val.WriteByte(v)
+		}
+		return rune(v)
+	case 'u', 'U':
+		digits := 4
+		if ch == 'U' {
+			digits = 8
+		}
+		var v uint32 // \UFFFFFFFF does not fit in a rune
+		n := 0
+		for ; n < digits && isHexDigit(lx.peek(0)); n++ {
+			v = v<<4 | uint32(hexValue(lx.advance()))
+		}
+		switch {
+		case n < digits:
+			lx.errorFrom(l, c, from, ErrInvalidEscape, fmt.Sprintf("invalid Unicode escape: \\%c needs %d hex digits", ch, digits))
+		case 0xD800 <= v && v <= 0xDFFF:
+			lx.errorFrom(l, c, from, ErrInvalidEscape, fmt.Sprintf("invalid Unicode escape: U+%04X is a surrogate half", v))
+		case v > utf8.MaxRune:
+			lx.errorFrom(l, c, from, ErrInvalidEscape, fmt.Sprintf("invalid Unicode escape: U+%X is beyond U+10FFFF", v))
+		default:
+			r = rune(v)
+		}
+	default:
+		lx.errorFrom(l, c, from, ErrInvalidEscape, fmt.Sprintf("unknown escape sequence \\%c", ch))
+	}
+	if val != nil {
+		val.WriteRune(r)
+	}
+	return r
+}
+
+func isHexDigit(r rune) bool {
+	return '0' <= r && r <= '9' || 'a' <= r && r <= 'f' || 'A' <= r && r <= 'F'
+}
+
+func hexValue(r rune) rune {
+	switch {
+	case r >= 'a':
+		return r - 'a' + 10
+	case r >= 'A':
+		return r - 'A' + 10
+	}
+	return r - '0'
+}
+
+// scanTextBlock scans a """...""" string, which may span lines. Escapes
+// work as in "..." strings (no interpolation). Its value drops the line
+// break right after the opening quotes, the last line if it holds only
+// the indentation of the closing quotes, and the indentation common to all
+// non-blank lines, so a block can be indented along with the code.
+func (lx *Lexer) scanTextBlock() {
+	l, c := lx.line, lx.col
+	start := lx.i
+	lx.advance()
+	lx.advance()
+	lx.advance()
+	body := lx.i
+	escaped := false
+	for {
+		ch := lx.peek(0)
+		if ch == eof {
+			lx.errorAt(l, c, ErrUnterminatedString, "unterminated text block")
+			return
+		}
+		if ch == '\\' && lx.peek(1) != eof {
+			lx.scanEscape(nil) // report bad escapes where they are
+			escaped = true
+			continue
+		}
+		if ch == '"' && lx.peek(1) == '"' && lx.peek(2) == '"' {
+			break
+		}
+		lx.advance()
+	}
+	raw := lx.src[body:lx.i]
+	lx.advance()
+	lx.advance()
+	lx.advance()
+	if lx.spanMode {
+		lx.add(STRING_LIT, lx.src[start:lx.i], l, c, nil, nil)
+		return
+	}
+	value := stripTextBlockIndent(raw)
+	if escaped {
+		value = decodeEscapes(value)
+	}
+	lx.addValue(STRING_LIT, lx.src[start:lx.i], l, c, value)
+}
+
+// NormalizeLineBreaks turns the \r\n and lone \r line breaks of s into \n,
+// as the value of a string spanning lines has them.
+func NormalizeLineBreaks(s string) string {
+	if strings.IndexByte(s, '\r') < 0 {
+		return s
+	}
+	return strings.ReplaceAll(strings.ReplaceAll(s, "\r\n", "\n"), "\r", "\n")
+}
+
+// stripTextBlockIndent computes the value of a text block from the raw
+// text between its quotes; see scanTextBlock.
+func stripTextBlockIndent(raw string) string {
+	raw = NormalizeLineBreaks(raw)
+	if strings.HasPrefix(raw, "\n") {
+		raw = raw[1:]
+	}
+	lines := strings.Split(raw, "\n")
+	last := lines[len(lines)-1]
+	closingLine := len(lines) > 1 && strings.TrimLeft(last, " \t") == ""
+	indent := -1
+	for i, line := range lines {
+		trimmed := strings.TrimLeft(line, " \t")
+		if trimmed == "" && !(closingLine && i == len(lines)-1) {
+			continue
+		}
+		if n := len(line) - len(trimmed); indent < 0 || n < indent {
+			indent = n
+		}
+	}
+	if closingLine {
+		lines = lines[:len(lines)-1]
+		lines = append(lines, "")
+	}
+	for i, line := range lines {
+		if len(line) >= indent && indent > 0 {
+			lines[i] = line[indent:]
+		} else if strings.TrimLeft(line, " \t") == "" {
+			lines[i] = ""
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// decodeEscapes replaces the escape sequences in s by their values. The
+// escapes have already been validated where they appeared in the source.
+func decodeEscapes(s string) string {
+	lx := NewLexer(s)
+	var val strings.Builder
+	for lx.peek(0) != eof {
+		if lx.peek(0) == '\\' && lx.peek(1) != eof {
+			lx.scanEscape(&val)
+			continue
+		}
+		from := lx.i
+		lx.advance()
+		val.WriteString(lx.src[from:lx.i])
+	}
+	return val.String()
+}
+
+// atLineStart reports whether only spaces and tabs precede lx.start on its
+// line.
+func (lx *Lexer) atLineStart() bool {
+	lineStart := LineStartOf(lx.src, lx.start)
+	return strings.Trim(lx.src[lineStart:lx.start], " \t") == ""
+}
+
+// scanDirective scans a preprocessor line such as `#include "x.jl"` or
+// `#define DEBUG 1`: the name after '#' becomes the token's value and the
+// rest of the line, trimmed, its Arg.
+func (lx *Lexer) scanDirective() {
+	l, c := lx.line, lx.col
+	lx.advance() // #
+	for lx.peek(0) == ' ' || lx.peek(0) == '\t' {
+		lx.advance() // indented as in `#  if`
+	}
+	nameStart := lx.i
+	for lx.isIdentPart(lx.peek(0)) {
+		lx.advance()
+	}
+	if lx.i == nameStart {
+		lx.errorAt(l, c, ErrBadDirective, "expected directive name after '#'")
+		return
+	}
+	name := lx.src[nameStart:lx.i]
+	for !isLineEnd(lx.peek(0)) {
+		lx.advance()
+	}
+	lex := lx.src[lx.start:lx.i]
+	arg := strings.TrimSpace(lx.src[nameStart+len(name) : lx.i])
+	lx.addValue(DIRECTIVE, lex, l, c, name)
+	if !lx.spanMode {
+		lx.tokens[len(lx.tokens)-1].Arg = &arg
+	}
+}
+
+// pragmaPrefix starts a pragma comment such as `//#pragma:nolint`.
+const pragmaPrefix = "//#pragma:"
+
+// addPragma adds the pragma comment that started at byte offset start as a
+// PRAGMA token: the name after the prefix becomes its value and the rest
+// of the line, trimmed, its Arg. Without a name it stays a comment and is
+// reported.
+func (lx *Lexer) addPragma(l, c, start int) {
+	lex := lx.src[start:lx.i]
+	rest := lex[len(pragmaPrefix):]
+	n := 0
+	for n < len(rest) {
+		r, size := utf8.DecodeRuneInString(rest[n:])
+		if !lx.isIdentPart(r) && r != '-' {
+			break
+		}
+		n += size
+	}
+	if n == 0 {
+		lx.addComment(lex, l, c, start)
+		lx.errorFrom(l, c, start, ErrBadDirective, "expected pragma name after '"+pragmaPrefix+"'")
+		return
+	}
+	lx.start = start
+	lx.addValue(PRAGMA, lex, l, c, rest[:n])
+	if !lx.spanMode {
+		arg := strings.TrimSpace(rest[n:])
+		lx.tokens[len(lx.tokens)-1].Arg = &arg
+	}
+}
+
+// scanRawString scans a `...` string. Nothing is escaped inside it except
+// the backtick itself: two backticks in a row stand for one.
+func (lx *Lexer) scanRawString() {
+	l, c := lx.line, lx.col
+	start := lx.i
+	lx.advance() // `
+	doubled := false
+	for {
+		ch := lx.peek(0)
+		if ch == eof {
+			lx.errorAt(l, c, ErrUnterminatedString, "unterminated raw string")
+			return
+		}
+		lx.advance()
+		if ch == '`' {
+			if lx.peek(0) != '`' {
+				break
+			}
+			lx.advance()
+			doubled = true
+		}
+	}
+	value := lx.src[start+1 : lx.i-1]
+	if doubled && !lx.spanMode {
+		value = strings.ReplaceAll(value, "``", "`")
+	}
+	if !lx.spanMode {
+		value = NormalizeLineBreaks(value)
+	}
+	lx.addValue(STRING_LIT, lx.src[start:lx.i], l, c, value)
+}
+
+func (lx *Lexer) scanChar() {
+	l, c := lx.line, lx.col
+	start := lx.i
+	lx.advance() // '
+	ch := lx.peek(0)
+	var val strings.Builder
+	var r rune
+	if ch == '\\' {
+		if isLineEnd(lx.peek(1)) {
+			lx.advance()
+			lx.errorAt(l, c, ErrInvalidCharLit, "unterminated char escape")
+			return
+		}
+		if lx.spanMode {
+			r = lx.scanEscape(nil)
+		} else {
+			r = lx.scanEscape(&val)
+		}
+	} else {
+		if isLineEnd(ch) || ch == '\'' {
+			lx.errorAt(l, c, ErrInvalidCharLit, "empty or invalid char literal")
+			return
+		}
+		r = lx.advance()
+	}
+	value := lx.src[start+1 : lx.i]
+	if ch == '\\' {
+		value = val.String()
+	}
+	if lx.peek(0) != '\'' {
+		// 'ab': take the whole literal if it closes on this line, so the
+		// rest is not lexed as an identifier and a new char literal
+		if end := strings.IndexAny(lx.src[lx.i:], "'\r\n"); end >= 0 && lx.src[lx.i+end] == '\'' {
+			for lx.i < lx.length && lx.src[lx.i] != '\'' {
+				lx.advance()
+			}
+			lx.advance()
+			lx.errorAt(l, c, ErrInvalidCharLit, "char literal has more than one character")
+			return
+		}
+		lx.errorAt(l, c, ErrInvalidCharLit, "unterminated char literal")
+		return
+	}
+	lx.advance()
+	lx.addValue(CHAR_LIT, lx.src[start:lx.i], l, c, value)
+	if !lx.spanMode {
+		rv := r
+		lx.tokens[len(lx.tokens)-1].RuneVal = &rv
+	}
+}
+
+// ---------- main tokenization step ----------
+func (lx *Lexer) nextToken() bool {
+	if lx.spec != nil {
+		return lx.nextSpecToken()
+	}
+	lx.skipWSAndComments()
+	ch := lx.peek(0)
+	if ch == eof {
+		for i := len(lx.interp) - 1; i >= 0; i-- {
+			f := lx.interp[i]
+			lx.errorFrom(f.line, f.col, f.offset, ErrUnterminatedString, "unterminated string interpolation")
+		}
+		lx.interp = nil
+		return false
+	}
+	l, c := lx.line, lx.col
+	lx.start = lx.i
+
+	if lx.isIdentStart(ch) {
+		lx.scanIdentOrKeyword()
+		return true
+	}
+	// numbers
+	if unicode.IsDigit(ch) {
+		lx.scanNumber()
+		return true
+	}
+	// strings
+	if ch == '"' {
+		if lx.peek(1) == '"' && lx.peek(2) == '"' {
+			lx.scanTextBlock()
+		} else {
+			lx.scanString()
+		}
+		return true
+	}
+	if ch == '`' {
+		lx.scanRawString()
+		return true
+	}
+	// char
+	if ch == '\'' {
+		lx.scanChar()
+		return true
+	}
+
+	switch ch {
+	case '#':
+		if !lx.atLineStart() {
+			lx.errorAt(l, c, ErrBadDirective, "'#' directive must start a line")
+			lx.advance()
+			break
+		}
+		lx.scanDirective()
+	case '@':
+		lx.advance()
+		if !lx.isIdentStart(lx.peek(0)) {
+			lx.errorAt(l, c, ErrBadAnnotation, "expected annotation name after '@'")
+			break
+		}
+		for lx.isIdentPart(lx.peek(0)) {
+			lx.advance()
+		}
+		lx.addValue(ANNOTATION, lx.src[lx.start:lx.i], l, c, lx.src[lx.start+1:lx.i])
+	default:
+		if lx.scanOperator(l, c) {
+			break
+		}
+		if _, w := utf8.DecodeRuneInString(lx.src[lx.i:]); ch == utf8.RuneError && w == 1 || IsBidiControl(ch) {
+			lx.advance() // reports the encoding error or the control itself
+			break
+		}
+		lx.errorAt(l, c, ErrInvalidCharacter, fmt.Sprintf("invalid character %q", ch))
+		lx.advance()
+	}
+	return true
+}
+
+// scanOperator adds the longest operator at the cursor, recognized by the
+// DFA generated from tokenspec.json; a sequence missing from the spec, like
+// "??", lexes as several shorter operators. '{' and '}' also keep track of
+// string interpolation. It reports false if no operator starts here.
+func (lx *Lexer) scanOperator(l, c int) bool {
+	state, tt, end := 0, TokenType(""), 0
+	for i := lx.i; i < lx.length && lx.src[i] < utf8.RuneSelf; i++ {
+		if state = int(opNext[state][lx.src[i]]); state == 0 {
+			break
+		}
+		if opAccept[state] != "" {
+			tt, end = opAccept[state], i+1
+		}
+	}
+	if tt == "" {
+		return false
+	}
+	for lx.i < end && lx.i < lx.length {
+		lx.advance()
+	}
+	n := len(lx.interp)
+	switch {
+	case tt == LBRACE && n > 0:
+		lx.interp[n-1].depth++
+	case tt == RBRACE && n > 0 && lx.interp[n-1].depth == 0:
+		lx.interp = lx.interp[:n-1]
+		lx.add(INTERP_END, "}", l, c, nil, nil)
+		lx.start = lx.i
+		lx.scanStringBody(lx.line, lx.col, STRING_SEGMENT)
+		return true
+	case tt == RBRACE && n > 0:
+		lx.interp[n-1].depth--
+	}
+	lx.add(tt, lx.src[lx.start:lx.i], l, c, nil, nil)
+	return true
+}
+
+func (lx *Lexer) LexAll() ([]Token, []string) {
+	toks, errs, _ := lx.LexAllContext(context.Background())
+	return toks, errs
+}
+
+// cancelCheckBytes is how many bytes the lexer scans between looks at the
+// context of LexAllContext, inside a token as well as between them.
+const cancelCheckBytes = 4096
+
+// maxPresizedTokens caps the tokens LexAll makes room for up front.
+const maxPresizedTokens = 4096
+
+// LexAllContext is LexAll stopping early when ctx is cancelled or its
+// deadline passes, even inside a long token. It then returns the tokens and
+// errors of the tokens lexed so far, without the one cut short, and an error
+// wrapping ctx.Err() that says where that token began.
+func (lx *Lexer) LexAllContext(ctx context.Context) ([]Token, []string, error) {
+	total := lx.limitInput()
+	if lx.tokens == nil {
+		// sources rarely average fewer than 4 bytes per token; a Token is
+		// many times that, so larger inputs grow the slice as they need
+		lx.tokens = make([]Token, 0, min(lx.length/4+1, maxPresizedTokens))
+	}
+	lx.progressAt = progressEvery
+	lx.done = ctx.Done() // nil for a context that is never cancelled
+	lx.checkCancel()
+	var cancelled error
+	for !lx.stopped && cancelled == nil {
+		if lx.done == nil {
+			if !lx.nextToken() {
+				break
+			}
+			lx.reportProgress(false)
+			continue
+		}
+		// what the token cut short by a cancellation added is taken back
+		l, c := lx.line, lx.col
+		toks, comments, diags, filter := len(lx.tokens), len(lx.comments), len(lx.diags), lx.filter
+		more := lx.nextToken()
+		if lx.cancelled {
+			lx.tokens, lx.comments = lx.tokens[:toks], lx.comments[:comments]
+			lx.diags, lx.errors, lx.filter = lx.diags[:diags], lx.errors[:diags], filter
+			lx.interp = nil
+			cancelled = fmt.Errorf("lexing stopped at %d:%d: %w", l, c, ctx.Err())
+			break
+		}
+		if !more {
+			break
+		}
+		lx.reportProgress(false)
+	}
+	lx.reportProgress(true)
+	if total > 0 && !lx.stopped && cancelled == nil {
+		lx.errorFrom(lx.line, lx.col, lx.i, ErrLimit, fmt.Sprintf("input of %d bytes is longer than the limit of %d; the rest is not lexed", total, lx.limits.MaxInput))
+	}
+	if lx.stopped {
+		lx.tokens = lx.tokens[:min(len(lx.tokens), lx.limits.MaxTokens)]
+	}
+	lx.attachDocs()
+	lx.recolumn()
+	if lx.withComments {
+		return MergeTrivia(lx.tokens, lx.comments), lx.errors, cancelled
+	}
+	return lx.tokens, lx.errors, cancelled
+}
+
+// Comments returns the comments skipped during lexing, in source order.
+func (lx *Lexer) Comments() []Token {
+	return lx.comments
+}
+
+// Diagnostics returns the structured form of the errors reported by LexAll.
+func (lx *Lexer) Diagnostics() []Diagnostic {
+	return lx.diags
+}
+
+// TokenDocument is the JSON document the tokenizer produces for one source.
+type TokenDocument struct {
+	Tokens []Token         `json:"tokens"`
+	AST    json.RawMessage `json:"ast,omitempty"`
+	Errors []string        `json:"errors"`
+	Files  []string        `json:"files,omitempty"` // the file table Token.File indexes
+}
@@ -0,0 +1,568 @@
+package tokenizer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+// benchProgram is a chunk of typical source, repeated to build the inputs of
+// the benchmarks.
+const benchProgram = `pkg main
+imp "std/io"
+
+// Point is a position on the grid.
+type Point struct { x i32; y i32 }
+
+def distance(a Point, b Point): i32 {
+    var dx: i32 = a.x - b.x
+    var dy: i32 = a.y - b.y
+    if dx < 0 { dx = -dx }
+    if dy < 0 { dy = -dy }
+    ret dx + dy
+}
+
+def main(): i32 {
+    later io.Println("done")
+    cons limit = 0x1_000
+    fr i := 0; i < limit; i++ {
+        io.Println("step", i, 3.25e-2, 'é', "naïve \"quoted\" text")
+    }
+    ret 0
+}
+`
+
+// benchSource returns benchProgram repeated to at least size bytes.
+func benchSource(size int) string {
+	return strings.Repeat(benchProgram, size/len(benchProgram)+1)
+}
+
+func TestRawStrings(t *testing.T) {
+	tests := []struct {
+		src   string
+		value string
+		end   int // of the literal
+		// position of the identifier after it
+		line, col int
+	}{
+		{"`abc` x", "abc", 5, 1, 7},
+		{"`` x", "", 2, 1, 4},
+		{"`a``b` x", "a`b", 6, 1, 8},
+		{"```` x", "`", 4, 1, 6},
+		{"`a\nb` x", "a\nb", 5, 2, 4},
+		{"`\n\n` x", "\n\n", 4, 3, 3},
+		{"`a\r\nb\rc`\r\nx", "a\nb\nc", 8, 4, 1},
+		{"`é\n𝒳\t` x", "é\n𝒳\t", 10, 2, 5},
+		{"`one\n  two``three\n` x", "one\n  two`three\n", 19, 3, 3},
+	}
+	for _, tt := range tests {
+		toks, errs := NewLexer(tt.src).LexAll()
+		if len(errs) > 0 || len(toks) != 2 {
+			t.Errorf("%q: got %d tokens and errors %q, want 2 tokens", tt.src, len(toks), errs)
+			continue
+		}
+		s, x := toks[0], toks[1]
+		if s.Type != STRING_LIT || s.Value == nil || *s.Value != tt.value {
+			t.Errorf("%q: got %s with value %v, want STRING_LIT %q", tt.src, s.Type, s.Value, tt.value)
+		}
+		if s.Line != 1 || s.Column != 1 || s.Offset != 0 || s.End != tt.end || s.Lexeme != tt.src[:tt.end] {
+			t.Errorf("%q: literal at %d:%d [%d,%d), want 1:1 [0,%d)", tt.src, s.Line, s.Column, s.Offset, s.End, tt.end)
+		}
+		if x.Lexeme != "x" || x.Line != tt.line || x.Column != tt.col {
+			t.Errorf("%q: %q after it at %d:%d, want x at %d:%d", tt.src, x.Lexeme, x.Line, x.Column, tt.line, tt.col)
+		}
+	}
+
+	for _, src := range []string{"`abc", "`a``", "`a\n"} {
+		lx := NewLexer(src)
+		toks, _ := lx.LexAll()
+		if d := lx.Diagnostics(); len(toks) != 0 || len(d) != 1 || d[0].Code != ErrUnterminatedString {
+			t.Errorf("%q: got %d tokens and %v, want one unterminated string error", src, len(toks), d)
+		}
+	}
+}
+
+func TestQuestion(t *testing.T) {
+	tests := []struct {
+		src   string
+		types []TokenType
+	}{
+		{"?", []TokenType{QUESTION}},
+		{"c ? a : b", []TokenType{IDENT, QUESTION, IDENT, COLON, IDENT}},
+		{"c?1:2", []TokenType{IDENT, QUESTION, INT_LIT, COLON, INT_LIT}},
+		{"x := c ? 1 : 2", []TokenType{IDENT, DECL, IDENT, QUESTION, INT_LIT, COLON, INT_LIT}},
+		// no ?. ?? or ?: operators yet: each is ? and what follows it
+		{"a?.b", []TokenType{IDENT, QUESTION, DOT, IDENT}},
+		{"a ?? b", []TokenType{IDENT, QUESTION, QUESTION, IDENT}},
+		{"a ?: b", []TokenType{IDENT, QUESTION, COLON, IDENT}},
+		{"??=", []TokenType{QUESTION, QUESTION, ASSIGN}},
+		{`"?" '?' // ?`, []TokenType{STRING_LIT, CHAR_LIT}},
+	}
+	for _, tt := range tests {
+		toks, errs := NewLexer(tt.src).LexAll()
+		var got []TokenType
+		for _, tok := range toks {
+			got = append(got, tok.Type)
+		}
+		if len(errs) > 0 || fmt.Sprint(got) != fmt.Sprint(tt.types) {
+			t.Errorf("%q: got %v %q, want %v", tt.src, got, errs, tt.types)
+		}
+	}
+}
+
+func TestMacroExpansionLimit(t *testing.T) {
+	var b strings.Builder
+	b.WriteString("#define A0 x x\n")
+	for i := 1; i <= 40; i++ {
+		fmt.Fprintf(&b, "#define A%d A%d A%d\n", i, i-1, i-1)
+	}
+	b.WriteString("y := A40\n")
+	src := b.String()
+
+	toks, _ := NewLexer(src).LexAll()
+	pp := NewPreprocessor(nil, nil)
+	out := pp.Expand("bomb.jl", src, toks)
+	if len(out) != 3 || out[2].Lexeme != "A40" {
+		t.Errorf("got %d tokens, want y := A40 left unexpanded", len(out))
+	}
+	errs := pp.Errors()
+	if len(errs) != 1 || !strings.Contains(errs[0], "more than 1048576 tokens") {
+		t.Errorf("errors %q", errs)
+	}
+}
+
+// preprocess expands src as the file name and returns the lexemes of the
+// result and the preprocessor's errors.
+func preprocess(t *testing.T, name, src string, includePath ...string) ([]string, []string) {
+	t.Helper()
+	lx := NewLexer(src)
+	toks, _ := lx.LexAll()
+	pp := NewPreprocessor(includePath, nil)
+	var lexemes []string
+	for _, tok := range pp.Expand(name, src, toks) {
+		lexemes = append(lexemes, tok.Lexeme)
+	}
+	var errs []string
+	for _, d := range lx.Diagnostics() {
+		if !pp.Skipped(d) {
+			errs = append(errs, d.String())
+		}
+	}
+	return lexemes, append(errs, pp.Errors()...)
+}
+
+func TestPreprocessInclude(t *testing.T) {
+	dir := t.TempDir()
+	src, inc := filepath.Join(dir, "src"), filepath.Join(dir, "inc")
+	files := map[string]string{
+		filepath.Join(src, "a.jl"):  "local_a\n",
+		filepath.Join(inc, "a.jl"):  "path_a\n",
+		filepath.Join(inc, "b.jl"):  "path_b\n",
+		filepath.Join(src, "c1.jl"): "#include \"c2.jl\"\nc1\n",
+		filepath.Join(src, "c2.jl"): "#include \"c1.jl\"\nc2\n",
+	}
+	for path, text := range files {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(text), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	main := filepath.Join(src, "main.jl")
+	tests := []struct {
+		name, src string
+		want      []string
+		errs      []string
+	}{
+		// the including file's directory comes before the include path
+		{"lookup order", "#include \"a.jl\"\n#include \"b.jl\"\nx\n", []string{"local_a", "path_b", "x"}, nil},
+		{"not found", "#include \"nope.jl\"\nx\n", []string{"x"},
+			[]string{`preprocessor error at 1:1: included file "nope.jl" not found`}},
+		{"cycle", "#include \"c1.jl\"\nx\n", []string{"c2", "c1", "x"},
+			[]string{filepath.Join(src, "c2.jl") + ": preprocessor error at 1:1: include cycle: " +
+				filepath.Join(src, "c1.jl") + " -> " + filepath.Join(src, "c2.jl") + " -> " + filepath.Join(src, "c1.jl")}},
+	}
+	for _, tt := range tests {
+		got, errs := preprocess(t, main, tt.src, inc)
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+		if !slices.Equal(errs, tt.errs) {
+			t.Errorf("%s: errors %q, want %q", tt.name, errs, tt.errs)
+		}
+	}
+}
+
+func TestPreprocessConditionals(t *testing.T) {
+	tests := []struct {
+		src  string
+		want []string
+		errs []string
+	}{
+		{"#define A 1\n#if A\na\n#elif 1\nb\n#else\nc\n#endif\n", []string{"a"}, nil},
+		{"#if 0\na\n#elif 1\nb\n#elif 1\nc\n#else\nd\n#endif\n", []string{"b"}, nil},
+		{"#if 0\na\n#elif defined(B)\nb\n#else\nc\n#endif\n", []string{"c"}, nil},
+		// an inner branch is never kept inside an outer one left out
+		{"#if 0\n#if 1\na\n#else\nb\n#endif\n#else\n#ifdef A\nc\n#elif 1\nd\n#endif\n#endif\n", []string{"d"}, nil},
+		{"#if 1\n#ifndef A\na\n#if 0\nb\n#endif\n#endif\n#endif\nc\n", []string{"a", "c"}, nil},
+		// lexical errors in a branch left out are not reported
+		{"#if 0\nx := @\n#else\ny\n#endif\n", []string{"y"}, nil},
+		{"#if 1\nx := @\n#endif\n", []string{"x", ":="},
+			[]string{"lexical error at 2:6: expected annotation name after '@'"}},
+		{"#if 1\na\n#else\nb\n#elif 1\nc\n#endif\n", []string{"a"},
+			[]string{"preprocessor error at 5:1: #elif after #else"}},
+		{"#elif 1\na\n", []string{"a"}, []string{"preprocessor error at 1:1: #elif without #if"}},
+		{"#if 1\na\n#else\nb\n#else\nc\n#endif\n", []string{"a"},
+			[]string{"preprocessor error at 5:1: #else without #if"}},
+		{"a\n#endif\n", []string{"a"}, []string{"preprocessor error at 2:1: #endif without #if"}},
+		{"#if 1 +\na\n#endif\n", nil, []string{`preprocessor error at 1:7: invalid #if condition: unexpected "+"`}},
+	}
+	for _, tt := range tests {
+		got, errs := preprocess(t, "main.jl", tt.src)
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%q: got %q, want %q", tt.src, got, tt.want)
+		}
+		if !slices.Equal(errs, tt.errs) {
+			t.Errorf("%q: errors %q, want %q", tt.src, errs, tt.errs)
+		}
+	}
+}
+
+func TestLoadLexSpec(t *testing.T) {
+	const luaYAML = `# Lua, roughly
+name: lua
+keywords:
+  local: KW_LOCAL
+  end: KW_END
+operators: {"=": ASSIGN, "..": CONCAT, '#': LEN}
+lineComments: ["--"]
+blockComments:
+  - ["--[[", "]]"]
+strings:
+  - {open: "\"", close: "\"", escapes: true}
+  - open: "[["
+    close: ]]
+    multiline: true
+numbers:
+  hex: true
+  float: true
+`
+	want := &LexSpec{
+		Name:          "lua",
+		Keywords:      map[string]string{"local": "KW_LOCAL", "end": "KW_END"},
+		Operators:     map[string]string{"=": "ASSIGN", "..": "CONCAT", "#": "LEN"},
+		LineComments:  []string{"--"},
+		BlockComments: [][2]string{{"--[[", "]]"}},
+		Strings: []StringSpec{
+			{Open: `"`, Close: `"`, Escapes: true},
+			{Open: "[[", Close: "]]", Multiline: true},
+		},
+		Numbers: NumberSpec{Hex: true, Float: true},
+	}
+	dir := t.TempDir()
+	load := func(name, src string) (*LexSpec, error) {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return LoadLexSpec(path)
+	}
+	sp, err := load("lua.yaml", luaYAML)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprintf("%+v", sp) != fmt.Sprintf("%+v", want) {
+		t.Errorf("got %+v\nwant %+v", sp, want)
+	}
+
+	for _, tt := range []struct{ name, src, err string }{
+		{"typo.json", `{"name": "x", "lineComment": ["--"]}`, `unknown field "lineComment"`},
+		{"typo.yml", "name: x\nlineComment: [\"--\"]\n", `unknown field "lineComment"`},
+		{"trailing.json", `{"name": "x"} {}`, "unexpected data after the spec"},
+		{"dup.yaml", "name: x\nname: y\n", `line 2: duplicate key "name"`},
+		{"indent.yaml", "name: x\n  numbers: {}\n", "line 2: unexpected indentation"},
+		{"anchor.yaml", "name: &n x\n", "line 1: unsupported YAML syntax"},
+		{"type.yaml", "typeNames: [i8, 16]\n", "cannot unmarshal number"},
+	} {
+		if _, err := load(tt.name, tt.src); err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: got error %v, want %q", tt.name, err, tt.err)
+		}
+	}
+}
+
+func TestColumnUnits(t *testing.T) {
+	const src = "é x\n\"😀\" y\n\"é😀\" z\n\"é\" @\n"
+	// the columns of the tokens, then of the error, in bytes, runes and
+	// UTF-16 code units
+	want := []Columns{
+		{1, 1, 1}, {4, 3, 3}, // é x
+		{1, 1, 1}, {8, 5, 6}, // "😀" y
+		{1, 1, 1}, {10, 6, 7}, // "é😀" z
+		{1, 1, 1}, // "é"
+	}
+	wantErr := Columns{6, 5, 5} // @
+	for _, unit := range []ColumnUnit{ColumnBytes, ColumnRunes, ColumnUTF16} {
+		lx := NewLexer(src, WithColumnUnit(unit), WithVerbosePositions())
+		toks, _ := lx.LexAll()
+		if len(toks) != len(want) {
+			t.Fatalf("%s: got %d tokens, want %d", unit, len(toks), len(want))
+		}
+		for i, tok := range toks {
+			if tok.Column != want[i].in(unit) || tok.Cols == nil || *tok.Cols != want[i] {
+				t.Errorf("%s: %q at %d:%d, cols %v, want column %d, cols %v", unit, tok.Lexeme, tok.Line, tok.Column, tok.Cols, want[i].in(unit), want[i])
+			}
+		}
+		diags := lx.Diagnostics()
+		if len(diags) != 1 || diags[0].Line != 4 || diags[0].Col != wantErr.in(unit) {
+			t.Errorf("%s: errors %v, want one at 4:%d", unit, diags, wantErr.in(unit))
+		}
+	}
+}
+
+func TestLineBreakPositions(t *testing.T) {
+	type pos struct {
+		lexeme    string
+		line, col int
+	}
+	tests := []struct {
+		src  string
+		want []pos
+		errs []string
+	}{
+		{"a\rb\r\nc\n\rd", []pos{{"a", 1, 1}, {"b", 2, 1}, {"c", 3, 1}, {"d", 5, 1}}, nil},
+		{"x := 1\r\n  y\r\n", []pos{{"x", 1, 1}, {":=", 1, 3}, {"1", 1, 6}, {"y", 2, 3}}, nil},
+		// an unterminated char literal ends at a lone \r like at \n
+		{"x := 'ab\ry := 'c'", []pos{{"x", 1, 1}, {":=", 1, 3}, {"b", 1, 8}, {"y", 2, 1}, {":=", 2, 3}, {"'c'", 2, 6}},
+			[]string{"lexical error at 1:6: unterminated char literal"}},
+		{"x := 'ab\r\ny := 'c'\r\n", []pos{{"x", 1, 1}, {":=", 1, 3}, {"b", 1, 8}, {"y", 2, 1}, {":=", 2, 3}, {"'c'", 2, 6}},
+			[]string{"lexical error at 1:6: unterminated char literal"}},
+	}
+	for _, tt := range tests {
+		toks, errs := NewLexer(tt.src).LexAll()
+		var got []pos
+		for _, tok := range toks {
+			got = append(got, pos{tok.Lexeme, tok.Line, tok.Column})
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%q: got %v, want %v", tt.src, got, tt.want)
+		}
+		if !slices.Equal(errs, tt.errs) {
+			t.Errorf("%q: errors %q, want %q", tt.src, errs, tt.errs)
+		}
+	}
+}
+
+func TestEscapes(t *testing.T) {
+	tests := []struct {
+		src, value string
+		err        string
+	}{
+		{`"a\n\t\r\\\"\'\$b"`, "a\n\t\r\\\"'$b", ""},
+		{`"\${x}"`, "${x}", ""},
+		{`"\0"`, "\x00", ""},
+		{`"\101\x42\x4a"`, "ABJ", ""},
+		{`"\377\xff"`, "\xff\xff", ""},
+		{`"é\U0001F600"`, "é😀", ""},
+		{`'\x41'`, "A", ""},
+		{`'é'`, "é", ""},
+		{`"\x4"`, "\uFFFD", "1:2: invalid hex escape: \\x needs 2 hex digits"},
+		{`"a\x"`, "a\uFFFD", "1:3: invalid hex escape: \\x needs 2 hex digits"},
+		{`"\12"`, "\uFFFD", "1:2: invalid octal escape: needs 3 octal digits"},
+		{`"\400"`, "\uFFFD", "1:2: invalid octal escape: \\400 is above \\377"},
+		{`"\u{41}"`, "\uFFFD{41}", "1:2: invalid Unicode escape: \\u needs 4 hex digits"},
+		{`"\u12"`, "\uFFFD", "1:2: invalid Unicode escape: \\u needs 4 hex digits"},
+		{`"\uD800"`, "\uFFFD", "1:2: invalid Unicode escape: U+D800 is a surrogate half"},
+		{`"\U00110000"`, "\uFFFD", "1:2: invalid Unicode escape: U+110000 is beyond U+10FFFF"},
+		{`"\UFFFFFFFF"`, "\uFFFD", "1:2: invalid Unicode escape: U+FFFFFFFF is beyond U+10FFFF"},
+		{`"\q"`, "\uFFFD", "1:2: unknown escape sequence \\q"},
+	}
+	for _, tt := range tests {
+		toks, errs := NewLexer(tt.src).LexAll()
+		if len(toks) != 1 || toks[0].Value == nil {
+			t.Errorf("%s: got %v, want one literal with a value", tt.src, toks)
+			continue
+		}
+		if *toks[0].Value != tt.value {
+			t.Errorf("%s: value %q, want %q", tt.src, *toks[0].Value, tt.value)
+		}
+		var want []string
+		if tt.err != "" {
+			want = []string{"lexical error at " + tt.err}
+		}
+		if !slices.Equal(errs, want) {
+			t.Errorf("%s: errors %q, want %q", tt.src, errs, want)
+		}
+	}
+}
+
+func TestLexAllContextInsideToken(t *testing.T) {
+	// long enough that lexing the comment outlasts the cancel
+	src := "x := 1\n/*" + strings.Repeat("a", 32<<20)
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+	lx := NewLexer(src)
+	toks, errs, err := lx.LexAllContext(ctx)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("got error %v, want it cancelled", err)
+	}
+	if !strings.Contains(err.Error(), "stopped at 1:7") {
+		t.Errorf("error %q does not say lexing stopped before the comment", err)
+	}
+	// the comment cut short is dropped, and not reported unterminated
+	if len(toks) != 3 || len(errs) != 0 || len(lx.Comments()) != 0 {
+		t.Errorf("got %d tokens, %d comments and errors %q", len(toks), len(lx.Comments()), errs)
+	}
+}
+
+func TestVerifyLineContinuation(t *testing.T) {
+	for _, src := range []string{
+		"x := 1 + \\\n  2\n",
+		"x := 1 + \\\r\n  2\r\n",
+		"f(a, \\\n\tb, \\\r  c)",
+	} {
+		lx := NewLexer(src, WithLineContinuation())
+		toks, errs := lx.LexAll()
+		if len(errs) > 0 {
+			t.Fatalf("%q: %q", src, errs)
+		}
+		if err := VerifyRoundTrip(src, toks, lx.Comments(), lx.Diagnostics(), lx.Continuations()); err != nil {
+			t.Errorf("%q: %v", src, err)
+		}
+		// without the joins the `\` is text no token covers
+		if err := VerifyRoundTrip(src, toks, lx.Comments(), lx.Diagnostics(), nil); err == nil || !strings.Contains(err.Error(), "is not part of any token") {
+			t.Errorf("%q: got %v without the continuations", src, err)
+		}
+	}
+}
+
+// benchWords is the keyword lookup workload: keywords, aliases, mixed case
+// and the kind of identifiers that share their lengths.
+var benchWords = strings.Fields(`
+	pkg main imp def handler var x cons MaxSize type Point struct interface
+	mapping channel j select later ret if else switch case fall fr range
+	break continue joto dft panic recover recovery RET Def userId count
+	value i err ok buf result index length Println io fmt data offset
+`)
+
+// lookupKeywordMap is the previous map-based lookupKeyword, the baseline of
+// BenchmarkLookupKeyword.
+func lookupKeywordMap(lex string) (TokenType, bool) {
+	var buf [16]byte
+	if len(lex) > len(buf) {
+		return "", false
+	}
+	for i := 0; i < len(lex); i++ {
+		b := lex[i]
+		if b >= utf8.RuneSelf {
+			return "", false
+		}
+		if 'A' <= b && b <= 'Z' {
+			b += 'a' - 'A'
+		}
+		buf[i] = b
+	}
+	t, ok := keywords[string(buf[:len(lex)])]
+	return t, ok
+}
+
+func TestLookupKeyword(t *testing.T) {
+	words := append([]string{"", "PKG", "Recovery", "définir", "averyverylongidentifier"}, benchWords...)
+	for w := range keywords {
+		words = append(words, w, strings.ToUpper(w))
+	}
+	for _, w := range words {
+		got, gotOK := lookupKeyword(w)
+		want, wantOK := lookupKeywordMap(w)
+		if got != want || gotOK != wantOK {
+			t.Errorf("lookupKeyword(%q) = %q, %v; the map gives %q, %v", w, got, gotOK, want, wantOK)
+		}
+	}
+}
+
+func BenchmarkLookupKeyword(b *testing.B) {
+	for _, c := range []struct {
+		name   string
+		lookup func(string) (TokenType, bool)
+	}{
+		{"map", lookupKeywordMap},
+		{"length", lookupKeyword},
+	} {
+		b.Run(c.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				c.lookup(benchWords[i%len(benchWords)])
+			}
+		})
+	}
+}
+
+// BenchmarkLexAll lexes multi-megabyte inputs. Compare its B/op with
+// BenchmarkRuneCopy's, the copy of the input a []rune scanner makes before
+// lexing anything.
+func BenchmarkLexAll(b *testing.B) {
+	for _, size := range []int{1 << 20, 4 << 20} {
+		src := benchSource(size)
+		b.Run(fmt.Sprintf("%dMB", size>>20), func(b *testing.B) {
+			b.SetBytes(int64(len(src)))
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				NewLexer(src).LexAll()
+			}
+		})
+	}
+}
+
+func BenchmarkRuneCopy(b *testing.B) {
+	src := benchSource(4 << 20)
+	b.SetBytes(int64(len(src)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_ = []rune(src)
+	}
+}
+
+// BenchmarkLexSpans is LexAll's input lexed into pooled span tokens, which
+// should make no allocation per token: 0 allocs/op once the pool is warm.
+func BenchmarkLexSpans(b *testing.B) {
+	src := benchSource(4 << 20)
+	b.SetBytes(int64(len(src)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		LexSpans(src).Release()
+	}
+}
+
+// FuzzLexAll checks the lexer's guarantee for arbitrary bytes: it never
+// panics or hangs, and its tokens, comments and diagnostics account for the
+// whole input. The span lexer runs on the same input too. The seed corpus
+// is in testdata/fuzz/FuzzLexAll; FuzzParseFile in parser fuzzes the parser.
+func FuzzLexAll(f *testing.F) {
+	f.Fuzz(func(t *testing.T, src string) {
+		done := make(chan error, 1)
+		go func() {
+			lx := NewLexer(src)
+			toks, _ := lx.LexAll()
+			err := VerifyRoundTrip(src, toks, lx.Comments(), lx.Diagnostics(), lx.Continuations())
+			LexSpans(src).Release()
+			done <- err
+		}()
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Fatalf("%q: %v", src, err)
+			}
+		case <-time.After(10 * time.Second):
+			t.Fatalf("%q: lexing did not finish", src)
+		}
+	})
+}
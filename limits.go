@@ -0,0 +1,86 @@
+package tokenizer
+
+import (
+	"fmt"
+	"unicode/utf8"
+)
+
+// Limits bound what a Lexer spends on a pathological input, such as a
+// file of hundreds of megabytes or a single enormous identifier. A zero
+// field means no limit. Going over one is reported as an ErrLimit
+// diagnostic and lexing carries on within it.
+type Limits struct {
+	MaxInput  int `json:"maxInput"`  // bytes of input lexed; the rest is left out
+	MaxLexeme int `json:"maxLexeme"` // bytes kept of a token's lexeme and decoded value
+	MaxTokens int `json:"maxTokens"` // tokens lexed before stopping
+}
+
+// SetLimits sets the limits of lx; see Limits.
+func (lx *Lexer) SetLimits(l Limits) {
+	lx.limits = l
+}
+
+// WithLimits sets the limits of the lexer, as SetLimits does.
+func WithLimits(l Limits) Option {
+	return func(lx *Lexer) error {
+		if l.MaxInput < 0 || l.MaxLexeme < 0 || l.MaxTokens < 0 {
+			return fmt.Errorf("negative limit in %+v", l)
+		}
+		lx.SetLimits(l)
+		return nil
+	}
+}
+
+// limitInput cuts the input to MaxInput bytes, at a rune boundary, and
+// returns its length before, or 0 when it is within the limit.
+func (lx *Lexer) limitInput() (total int) {
+	if max := lx.limits.MaxInput; max > 0 && lx.length > max {
+		total = lx.length
+		lx.src = cutLexeme(lx.src, max)
+		lx.length = len(lx.src)
+	}
+	return total
+}
+
+// limitLexeme cuts lex to MaxLexeme bytes, at a rune boundary, reporting
+// the token at l:c that was longer.
+func (lx *Lexer) limitLexeme(lex string, l, c, from int) string {
+	max := lx.limits.MaxLexeme
+	if max <= 0 || len(lex) <= max {
+		return lex
+	}
+	lx.errorFrom(l, c, from, ErrLimit, fmt.Sprintf("token of %d bytes is longer than the limit of %d; only its first %d are kept", len(lex), max, max))
+	return cutLexeme(lex, max)
+}
+
+// maxLexeme returns MaxLexeme, or a length no string reaches when there is
+// no limit.
+func (lx *Lexer) maxLexeme() int {
+	if lx.limits.MaxLexeme <= 0 {
+		return int(^uint(0) >> 1)
+	}
+	return lx.limits.MaxLexeme
+}
+
+// cutLexeme returns the longest prefix of s of at most max bytes that ends
+// at a rune boundary.
+func cutLexeme(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	for max > 0 && !utf8.RuneStart(s[max]) {
+		max--
+	}
+	return s[:max]
+}
+
+// countToken stops the lexer, with a diagnostic at the token at l:c, when
+// it is the first past MaxTokens; n counts the tokens added so far. LexAll
+// then drops the tokens past the limit.
+func (lx *Lexer) countToken(n, l, c int) {
+	if max := lx.limits.MaxTokens; max > 0 && n > max && !lx.stopped {
+		lx.errorFrom(l, c, lx.start, ErrLimit, fmt.Sprintf("more than %d tokens; the rest of the input is not lexed", max))
+		lx.stopped = true
+		lx.interp = nil
+	}
+}
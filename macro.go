@@ -0,0 +1,256 @@
+package tokenizer
+
+import (
+	"fmt"
+	"strings"
+)
+
+// maxMacroDepth bounds how deeply macro expansions may nest.
+const maxMacroDepth = 64
+
+// maxMacroTokens bounds the tokens the macro expansions of a preprocessor
+// run may produce, counting every level of a nested expansion: without it a
+// few macros that each use the previous one twice expand to billions of
+// tokens well inside the depth limit.
+const maxMacroTokens = 1 << 20
+
+// macro is a #define: object-like (`#define N 10`) or function-like
+// (`#define SQ(x) ((x) * (x))`).
+type macro struct {
+	name     string
+	function bool
+	params   []string
+	body     []Token // positioned in the #define line
+}
+
+// expansion is one active macro expansion: the macro and the token that
+// invoked it.
+type expansion struct {
+	macro *macro
+	at    Token
+}
+
+// directiveTokens lexes the text of the directive t after its name, such as
+// `SQ(x) ((x) * (x))` in `#define SQ(x) ((x) * (x))`. The tokens are
+// positioned in t's file and line; lexical errors are reported.
+func (pp *Preprocessor) directiveTokens(t Token) []Token {
+	skip := strings.Index(t.Lexeme, *t.Value) + len(*t.Value) // '#', blanks and the name are ASCII
+	lx := pp.newLexer(t.Lexeme[skip:])
+	lx.SetFile(t.File)
+	toks, _ := lx.LexAll()
+	for i := range toks {
+		shift(&toks[i].Line, &toks[i].Column, &toks[i].Offset, &toks[i].End, t, skip)
+		if c := toks[i].Cols; c != nil {
+			c.Bytes, c.Runes, c.UTF16 = c.Bytes+skip, c.Runes+skip, c.UTF16+skip
+		}
+	}
+	for _, d := range lx.Diagnostics() {
+		shift(&d.Line, &d.Col, &d.Offset, &d.End, t, skip)
+		pp.diags = append(pp.diags, d)
+	}
+	return toks
+}
+
+// shift moves a position lexed from the text skip bytes into the directive
+// t to where that text is in t's file.
+func shift(line, col, off, end *int, t Token, skip int) {
+	*line += t.Line - 1
+	*col += t.Column - 1 + skip
+	*off += t.Offset + skip
+	*end += t.Offset + skip
+}
+
+// define records the macro of the #define directive t.
+func (pp *Preprocessor) define(t Token) {
+	toks := pp.directiveTokens(t)
+	if len(toks) == 0 || toks[0].Type != IDENT {
+		pp.errorAt(t, ErrMacro, "#define expects a macro name")
+		return
+	}
+	m := &macro{name: toks[0].Lexeme, body: toks[1:]}
+	// a '(' right after the name makes a function-like macro; after a
+	// space it starts the body
+	if len(toks) > 1 && toks[1].Type == LPAREN && toks[1].Offset == toks[0].End {
+		m.function = true
+		i := 2
+		for ; i < len(toks) && toks[i].Type != RPAREN; i++ {
+			want := IDENT // at even positions after the '('
+			if i%2 == 1 {
+				want = COMMA
+			}
+			if toks[i].Type != want {
+				pp.errorAt(toks[i], ErrMacro, fmt.Sprintf("malformed parameter list of macro %s", m.name))
+				return
+			}
+			if want == IDENT {
+				for _, p := range m.params {
+					if p == toks[i].Lexeme {
+						pp.errorAt(toks[i], ErrMacro, fmt.Sprintf("duplicate parameter %s of macro %s", p, m.name))
+						return
+					}
+				}
+				m.params = append(m.params, toks[i].Lexeme)
+			}
+		}
+		if i == len(toks) || toks[i-1].Type == COMMA {
+			pp.errorAt(t, ErrMacro, fmt.Sprintf("malformed parameter list of macro %s", m.name))
+			return
+		}
+		m.body = toks[i+1:]
+	}
+	pp.macros[m.name] = m
+}
+
+// undef removes the macro named by the #undef directive t.
+func (pp *Preprocessor) undef(t Token) {
+	name := strings.TrimSpace(*t.Arg)
+	if name == "" {
+		pp.errorAt(t, ErrMacro, "#undef expects a macro name")
+		return
+	}
+	delete(pp.macros, name)
+}
+
+// macroCall expands the macro named by toks[i], if it is one. It returns
+// the expansion and how many tokens of toks the call took: 0 when toks[i]
+// is not expanded. A macro is not expanded again inside its own
+// expansion, and a function-like macro only when an argument list follows.
+func (pp *Preprocessor) macroCall(toks []Token, i int, stack []expansion) ([]Token, int) {
+	t := toks[i]
+	m := pp.macros[t.Lexeme]
+	if t.Type != IDENT || m == nil {
+		return nil, 0
+	}
+	for _, e := range stack {
+		if e.macro == m {
+			return nil, 0
+		}
+	}
+	if m.function && (i+1 == len(toks) || toks[i+1].Type != LPAREN) {
+		return nil, 0
+	}
+	if pp.expanded > maxMacroTokens {
+		return nil, 0
+	}
+	if len(stack) == maxMacroDepth {
+		pp.macroError(t, stack, fmt.Sprintf("macro expansion nested more than %d levels deep", maxMacroDepth))
+		return nil, 1
+	}
+	inner := append(append([]expansion(nil), stack...), expansion{m, t})
+	if !m.function {
+		return pp.counted(t, stack, pp.substitute(m.body, inner), 1)
+	}
+
+	args, n := macroArgs(toks[i+1:])
+	if n < 0 {
+		pp.macroError(t, stack, fmt.Sprintf("unterminated argument list of macro %s", m.name))
+		return nil, 1
+	}
+	if len(m.params) == 0 && len(args) == 1 && len(args[0]) == 0 {
+		args = nil // F() calls a macro without parameters
+	}
+	if len(args) != len(m.params) {
+		pp.macroError(t, stack, fmt.Sprintf("macro %s takes %d arguments, got %d", m.name, len(m.params), len(args)))
+		return nil, 1 + n
+	}
+	for j := range args {
+		args[j] = pp.substitute(args[j], stack)
+	}
+	var body []Token
+	for _, bt := range m.body {
+		if p := indexOf(m.params, bt.Lexeme); bt.Type == IDENT && p >= 0 {
+			body = append(body, args[p]...)
+		} else {
+			body = append(body, bt)
+		}
+	}
+	return pp.counted(t, stack, pp.substitute(body, inner), 1+n)
+}
+
+// counted adds exp, the expansion of the call at t that took n tokens, to
+// the tokens expanded so far. Past maxMacroTokens it reports t, once, and
+// leaves the call unexpanded, as it does every later call.
+func (pp *Preprocessor) counted(t Token, stack []expansion, exp []Token, n int) ([]Token, int) {
+	if pp.expanded > maxMacroTokens {
+		return nil, 0
+	}
+	pp.expanded += len(exp)
+	if pp.expanded > maxMacroTokens {
+		pp.macroError(t, stack, fmt.Sprintf("macro expansion produces more than %d tokens; the rest is not expanded", maxMacroTokens))
+		return nil, 0
+	}
+	return exp, n
+}
+
+// substitute macro-expands every macro call in toks.
+func (pp *Preprocessor) substitute(toks []Token, stack []expansion) []Token {
+	var out []Token
+	for i := 0; i < len(toks); {
+		if exp, n := pp.macroCall(toks, i, stack); n > 0 {
+			out = append(out, exp...)
+			i += n
+			continue
+		}
+		out = append(out, toks[i])
+		i++
+	}
+	return out
+}
+
+// macroArgs splits the argument list that toks starts with, `(a, (b, c))`,
+// at its top-level commas. It returns the arguments and the number of
+// tokens up to and including the closing ')', or -1 if it is missing or
+// mismatched.
+func macroArgs(toks []Token) ([][]Token, int) {
+	args := [][]Token{nil}
+	depth := 0
+	for i, t := range toks {
+		switch {
+		case t.Type == LPAREN || t.Type == LBRACK || t.Type == LBRACE:
+			depth++
+			if depth == 1 {
+				continue
+			}
+		case t.Type == RPAREN || t.Type == RBRACK || t.Type == RBRACE:
+			depth--
+			if depth == 0 && t.Type == RPAREN {
+				return args, i + 1
+			}
+			if depth == 0 {
+				return nil, -1 // ( closed by ] or }
+			}
+		case t.Type == COMMA && depth == 1:
+			args = append(args, nil)
+			continue
+		}
+		args[len(args)-1] = append(args[len(args)-1], t)
+	}
+	return nil, -1
+}
+
+// macroError reports an error at t with the expansion backtrace in stack
+// as notes, innermost first.
+func (pp *Preprocessor) macroError(t Token, stack []expansion, msg string) {
+	n := len(pp.diags)
+	pp.errorAt(t, ErrMacro, msg)
+	if len(pp.diags) == n {
+		return
+	}
+	d := &pp.diags[n]
+	for i := len(stack) - 1; i >= 0; i-- {
+		at := stack[i].at
+		d.Notes = append(d.Notes, Note{
+			File: pp.files[at.File].Name, Line: at.Line, Col: at.Column,
+			Message: fmt.Sprintf("in expansion of macro %s", stack[i].macro.name),
+		})
+	}
+}
+
+func indexOf(list []string, s string) int {
+	for i, v := range list {
+		if v == s {
+			return i
+		}
+	}
+	return -1
+}
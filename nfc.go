@@ -0,0 +1,138 @@
+package tokenizer
+
+import (
+	"sort"
+)
+
+// Hangul syllables decompose and compose algorithmically.
+const (
+	hangulBase  = 0xAC00
+	hangulL     = 0x1100
+	hangulV     = 0x1161
+	hangulT     = 0x11A7
+	hangulLs    = 19
+	hangulVs    = 21
+	hangulTs    = 28
+	hangulCount = hangulLs * hangulVs * hangulTs
+)
+
+// NFC returns s in Unicode Normalization Form C, so that a name typed with
+// a precomposed letter, as é, and with a letter and a combining mark, as
+// e followed by U+0301, read the same.
+func NFC(s string) string {
+	if !needsNFC(s) {
+		return s
+	}
+	var d []rune
+	for _, r := range s {
+		d = decompose(d, r)
+	}
+	// canonical order: each run of non-starters sorted by combining class
+	for i := 0; i < len(d); {
+		j := i
+		for j < len(d) && combiningClass[d[j]] != 0 {
+			j++
+		}
+		if j-i > 1 {
+			run := d[i:j]
+			sort.SliceStable(run, func(a, b int) bool { return combiningClass[run[a]] < combiningClass[run[b]] })
+		}
+		i = j + 1
+	}
+	return string(compose(d))
+}
+
+// needsNFC reports whether s may change under NFC. Runes below U+0300, where
+// the combining marks start, never do.
+func needsNFC(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0xCC { // the first byte of U+0300 and above
+			return true
+		}
+	}
+	return false
+}
+
+// decompose appends the full canonical decomposition of r to d.
+func decompose(d []rune, r rune) []rune {
+	if s := r - hangulBase; s >= 0 && s < hangulCount {
+		d = append(d, hangulL+s/(hangulVs*hangulTs), hangulV+s%(hangulVs*hangulTs)/hangulTs)
+		if t := s % hangulTs; t != 0 {
+			d = append(d, hangulT+t)
+		}
+		return d
+	}
+	m, ok := canonicalDecomp[r]
+	if !ok {
+		return append(d, r)
+	}
+	d = decompose(d, m[0])
+	if m[1] != 0 {
+		d = decompose(d, m[1])
+	}
+	return d
+}
+
+// compose recombines canonically ordered d into primary composites: each
+// rune with the last starter before it, unless a rune in between has a
+// combining class of 0 or at least its own.
+func compose(d []rune) []rune {
+	out := d[:0]
+	starter := -1
+	var last uint8 // class of the last rune kept after the starter
+	for _, r := range d {
+		class := combiningClass[r]
+		if starter >= 0 && (len(out)-1 == starter || last != 0 && last < class) {
+			if c, ok := composePair(out[starter], r); ok {
+				out[starter] = c
+				continue
+			}
+		}
+		if class == 0 {
+			starter = len(out)
+		}
+		out = append(out, r)
+		last = class
+	}
+	return out
+}
+
+func composePair(a, b rune) (rune, bool) {
+	if l, v := a-hangulL, b-hangulV; l >= 0 && l < hangulLs && v >= 0 && v < hangulVs {
+		return hangulBase + (l*hangulVs+v)*hangulTs, true
+	}
+	if s, t := a-hangulBase, b-hangulT; s >= 0 && s < hangulCount && s%hangulTs == 0 && t > 0 && t < hangulTs {
+		return a + t, true
+	}
+	c, ok := canonicalComp[[2]rune{a, b}]
+	return c, ok
+}
+
+// SetNameNormalization turns off, or back on, giving an IDENT whose lexeme
+// is not in NFC its NFC form as Value. It is on by default; the lexeme is
+// always the source text.
+func (lx *Lexer) SetNameNormalization(on bool) {
+	lx.rawNames = !on
+}
+
+// normalizeName sets the Value of the IDENT just added to the NFC form of
+// its lexeme, if that differs.
+func (lx *Lexer) normalizeName() {
+	if lx.rawNames || lx.spanMode {
+		return
+	}
+	t := &lx.tokens[len(lx.tokens)-1]
+	if n := NFC(t.Lexeme); n != t.Lexeme {
+		t.Value = &n
+	}
+}
+
+// NameOf returns the name a token spells: for an IDENT written in another
+// normalization form, the NFC form the lexer put in its Value, and otherwise
+// its lexeme. Symbol tables key on it so both spellings are one name.
+func NameOf(t Token) string {
+	if t.Type == IDENT && t.Value != nil {
+		return *t.Value
+	}
+	return t.Lexeme
+}
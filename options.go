@@ -0,0 +1,106 @@
+package tokenizer
+
+// An Option configures a Lexer made by NewLexer. Options are applied in
+// order; the first one that fails is reported by Err.
+type Option func(lx *Lexer) error
+
+// Err returns the error of the first option passed to NewLexer that could
+// not be applied, such as an unknown contextual keyword; the lexer then
+// runs without it.
+func (lx *Lexer) Err() error {
+	return lx.optErr
+}
+
+// WithComments makes LexAll return comments, doc comments and the shebang in
+// the token stream, in source order, instead of only through Comments.
+func WithComments() Option {
+	return func(lx *Lexer) error {
+		lx.withComments = true
+		return nil
+	}
+}
+
+// WithTabWidth makes columns count a tab as reaching the next tab stop, every
+// n columns, as an editor shows it. n <= 0 counts a tab as one character,
+// the default.
+func WithTabWidth(n int) Option {
+	return func(lx *Lexer) error {
+		lx.tabWidth = n
+		return nil
+	}
+}
+
+// WithNameNormalization turns the NFC form of names on or off, as
+// SetNameNormalization does.
+func WithNameNormalization(on bool) Option {
+	return func(lx *Lexer) error {
+		lx.SetNameNormalization(on)
+		return nil
+	}
+}
+
+// WithDialect makes the lexer tokenize the language described by sp instead
+// of the built-in one, as SetSpec does.
+func WithDialect(sp *LexSpec) Option {
+	return func(lx *Lexer) error {
+		return lx.SetSpec(sp)
+	}
+}
+
+// WithLineContinuation enables `\` line continuations, as
+// SetLineContinuation does.
+func WithLineContinuation() Option {
+	return func(lx *Lexer) error {
+		lx.SetLineContinuation(true)
+		return nil
+	}
+}
+
+// WithContextualKeywords makes the named keywords contextual, as
+// SetContextualKeywords does.
+func WithContextualKeywords(words ...string) Option {
+	return func(lx *Lexer) error {
+		return lx.SetContextualKeywords(words...)
+	}
+}
+
+// WithColumnUnit sets the unit of columns, as SetColumnUnit does.
+func WithColumnUnit(u ColumnUnit) Option {
+	return func(lx *Lexer) error {
+		lx.SetColumnUnit(u)
+		return nil
+	}
+}
+
+// WithVerbosePositions attaches the column in every unit to each token, as
+// SetVerbosePositions does.
+func WithVerbosePositions() Option {
+	return func(lx *Lexer) error {
+		lx.SetVerbosePositions(true)
+		return nil
+	}
+}
+
+// WithMaxErrors keeps at most n errors, as SetMaxErrors does.
+func WithMaxErrors(n int) Option {
+	return func(lx *Lexer) error {
+		lx.SetMaxErrors(n)
+		return nil
+	}
+}
+
+// WithSuppressed drops errors with the given codes, as Suppress does.
+func WithSuppressed(codes ...string) Option {
+	return func(lx *Lexer) error {
+		lx.Suppress(codes...)
+		return nil
+	}
+}
+
+// WithFile stamps index on every token and diagnostic, as SetFile does.
+func WithFile(index int) Option {
+	return func(lx *Lexer) error {
+		lx.SetFile(index)
+		return nil
+	}
+}
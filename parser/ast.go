@@ -0,0 +1,295 @@
+package parser
+
+import "tokenizer"
+
+func posOf(t tokenizer.Token) tokenizer.Pos { return tokenizer.Pos{Line: t.Line, Col: t.Column} }
+
+// Node is any AST node: declarations, statements, expressions and types.
+type Node interface {
+	Position() tokenizer.Pos
+}
+
+// ---------- file & declarations ----------
+
+type File struct {
+	tokenizer.Pos
+	Package *Ident        `json:"package"`
+	Imports []*ImportDecl `json:"imports"`
+	Decls   []Node        `json:"decls"`
+}
+
+type ImportDecl struct {
+	tokenizer.Pos
+	Path string `json:"path"`
+}
+
+type FuncDecl struct {
+	tokenizer.Pos
+	Annotations []*Annotation `json:"annotations,omitempty"`
+	Name        *Ident        `json:"name"`
+	Params      []*Field      `json:"params"`
+	Result      Node          `json:"result,omitempty"`
+	Body        *BlockStmt    `json:"body"`
+}
+
+// VarDecl is a `var` or `cons` declaration.
+type VarDecl struct {
+	tokenizer.Pos
+	Annotations []*Annotation `json:"annotations,omitempty"`
+	Const       bool          `json:"const,omitempty"`
+	Names       []*Ident      `json:"names"`
+	Type        Node          `json:"type,omitempty"`
+	Values      []Node        `json:"values,omitempty"`
+}
+
+type TypeDecl struct {
+	tokenizer.Pos
+	Annotations []*Annotation `json:"annotations,omitempty"`
+	Name        *Ident        `json:"name"`
+	Type        Node          `json:"type"`
+}
+
+// Annotation is `@name` or `@name(args)` in front of a declaration.
+type Annotation struct {
+	tokenizer.Pos
+	Name string `json:"name"`
+	Args []Node `json:"args,omitempty"`
+}
+
+// Field is a parameter or struct field group such as `a, b: i32`.
+type Field struct {
+	tokenizer.Pos
+	Names    []*Ident `json:"names"`
+	Type     Node     `json:"type"`
+	Variadic bool     `json:"variadic,omitempty"` // name: ...T
+}
+
+// ---------- types ----------
+
+// ArrayType is `[N]T`, or `[]T` when Len is nil.
+type ArrayType struct {
+	tokenizer.Pos
+	Len  Node `json:"len,omitempty"`
+	Elem Node `json:"elem"`
+}
+
+type MapType struct {
+	tokenizer.Pos
+	Key   Node `json:"key"`
+	Value Node `json:"value"`
+}
+
+type ChanType struct {
+	tokenizer.Pos
+	Elem Node `json:"elem"`
+}
+
+type PointerType struct {
+	tokenizer.Pos
+	Elem Node `json:"elem"`
+}
+
+type StructType struct {
+	tokenizer.Pos
+	Fields []*Field `json:"fields"`
+}
+
+type InterfaceType struct {
+	tokenizer.Pos
+	Methods []*MethodSpec `json:"methods"`
+}
+
+type MethodSpec struct {
+	tokenizer.Pos
+	Name   *Ident   `json:"name"`
+	Params []*Field `json:"params"`
+	Result Node     `json:"result,omitempty"`
+}
+
+// ---------- expressions ----------
+
+type Ident struct {
+	tokenizer.Pos
+	Name string `json:"name"`
+}
+
+type BasicLit struct {
+	tokenizer.Pos
+	Type  tokenizer.TokenType `json:"type"`
+	Value string              `json:"value"`
+}
+
+// InterpString is an interpolated string literal: STRING_SEGMENT BasicLits
+// alternating with the interpolated expressions, starting and ending with
+// a segment. A segment's Value is its decoded text, without the quotes and
+// the ${ } around the interpolations.
+type InterpString struct {
+	tokenizer.Pos
+	Parts []Node `json:"parts"`
+}
+
+type CompositeLit struct {
+	tokenizer.Pos
+	Type Node   `json:"type"`
+	Elts []Node `json:"elts"`
+}
+
+type ParenExpr struct {
+	tokenizer.Pos
+	X Node `json:"x"`
+}
+
+type UnaryExpr struct {
+	tokenizer.Pos
+	Op tokenizer.TokenType `json:"op"`
+	X  Node                `json:"x"`
+}
+
+// CondExpr is cond ? then : else.
+type CondExpr struct {
+	tokenizer.Pos
+	Cond Node `json:"cond"`
+	Then Node `json:"then"`
+	Else Node `json:"else"`
+}
+
+type BinaryExpr struct {
+	tokenizer.Pos
+	Op tokenizer.TokenType `json:"op"`
+	X  Node                `json:"x"`
+	Y  Node                `json:"y"`
+}
+
+type CallExpr struct {
+	tokenizer.Pos
+	Fun    Node   `json:"fun"`
+	Args   []Node `json:"args"`
+	Spread bool   `json:"spread,omitempty"` // f(xs...)
+}
+
+type IndexExpr struct {
+	tokenizer.Pos
+	X     Node `json:"x"`
+	Index Node `json:"index"`
+}
+
+type SelectorExpr struct {
+	tokenizer.Pos
+	X   Node   `json:"x"`
+	Sel *Ident `json:"sel"`
+}
+
+// ---------- statements ----------
+
+type BlockStmt struct {
+	tokenizer.Pos
+	Stmts []Node `json:"stmts"`
+}
+
+type ExprStmt struct {
+	tokenizer.Pos
+	X Node `json:"x"`
+}
+
+// AssignStmt covers `=`, `:=` and the compound assignment operators.
+type AssignStmt struct {
+	tokenizer.Pos
+	Lhs []Node              `json:"lhs"`
+	Op  tokenizer.TokenType `json:"op"`
+	Rhs []Node              `json:"rhs"`
+}
+
+// IncDecStmt is x++ or x--.
+type IncDecStmt struct {
+	tokenizer.Pos
+	X  Node                `json:"x"`
+	Op tokenizer.TokenType `json:"op"`
+}
+
+type SendStmt struct {
+	tokenizer.Pos
+	Chan  Node `json:"chan"`
+	Value Node `json:"value"`
+}
+
+type IfStmt struct {
+	tokenizer.Pos
+	Cond Node       `json:"cond"`
+	Then *BlockStmt `json:"then"`
+	Else Node       `json:"else,omitempty"`
+}
+
+type ForStmt struct {
+	tokenizer.Pos
+	Init Node       `json:"init,omitempty"`
+	Cond Node       `json:"cond,omitempty"`
+	Post Node       `json:"post,omitempty"`
+	Body *BlockStmt `json:"body"`
+}
+
+type RangeStmt struct {
+	tokenizer.Pos
+	Key    Node       `json:"key,omitempty"`
+	Value  Node       `json:"value,omitempty"`
+	Define bool       `json:"define,omitempty"`
+	X      Node       `json:"x"`
+	Body   *BlockStmt `json:"body,omitempty"`
+}
+
+type SwitchStmt struct {
+	tokenizer.Pos
+	Tag   Node          `json:"tag,omitempty"`
+	Cases []*CaseClause `json:"cases"`
+}
+
+type SelectStmt struct {
+	tokenizer.Pos
+	Cases []*CaseClause `json:"cases"`
+}
+
+// CaseClause is a `case` or, when List is nil, a `dft` clause.
+type CaseClause struct {
+	tokenizer.Pos
+	List []Node `json:"list,omitempty"`
+	Body []Node `json:"body"`
+}
+
+type ReturnStmt struct {
+	tokenizer.Pos
+	Results []Node `json:"results,omitempty"`
+}
+
+// BranchStmt is break, continue, joto or fall.
+type BranchStmt struct {
+	tokenizer.Pos
+	Tok   tokenizer.TokenType `json:"tok"`
+	Label *Ident              `json:"label,omitempty"`
+}
+
+type LabeledStmt struct {
+	tokenizer.Pos
+	Label *Ident `json:"label"`
+	Stmt  Node   `json:"stmt"`
+}
+
+// LaterStmt is `later call`, run when the enclosing function returns.
+type LaterStmt struct {
+	tokenizer.Pos
+	Call Node `json:"call"`
+}
+
+// GoStmt is `j call`, run concurrently.
+type GoStmt struct {
+	tokenizer.Pos
+	Call Node `json:"call"`
+}
+
+type PanicStmt struct {
+	tokenizer.Pos
+	X Node `json:"x"`
+}
+
+// BadNode stands in for a construct that failed to parse.
+type BadNode struct {
+	tokenizer.Pos
+}
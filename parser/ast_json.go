@@ -0,0 +1,93 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+)
+
+// MarshalAST encodes an AST as JSON. Every node object carries a "kind"
+// field naming its Go type (e.g. "FuncDecl") so consumers can tell node
+// types apart; the other fields follow the json tags in ast.go.
+func MarshalAST(n Node) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := encodeAST(&buf, reflect.ValueOf(n)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func encodeAST(buf *bytes.Buffer, v reflect.Value) error {
+	switch v.Kind() {
+	case reflect.Interface, reflect.Ptr:
+		if v.IsNil() {
+			buf.WriteString("null")
+			return nil
+		}
+		return encodeAST(buf, v.Elem())
+	case reflect.Slice:
+		if v.IsNil() {
+			buf.WriteString("null")
+			return nil
+		}
+		buf.WriteByte('[')
+		for i := 0; i < v.Len(); i++ {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := encodeAST(buf, v.Index(i)); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+		return nil
+	case reflect.Struct:
+		buf.WriteString(`{"kind":`)
+		kind, _ := json.Marshal(v.Type().Name())
+		buf.Write(kind)
+		if err := encodeFields(buf, v); err != nil {
+			return err
+		}
+		buf.WriteByte('}')
+		return nil
+	}
+	b, err := json.Marshal(v.Interface())
+	if err != nil {
+		return err
+	}
+	buf.Write(b)
+	return nil
+}
+
+// encodeFields writes the fields of struct v, flattening embedded structs.
+func encodeFields(buf *bytes.Buffer, v reflect.Value) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f, fv := t.Field(i), v.Field(i)
+		if f.Anonymous {
+			if err := encodeFields(buf, fv); err != nil {
+				return err
+			}
+			continue
+		}
+		name, opts, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		if opts == "omitempty" && (fv.IsZero() || fv.Kind() == reflect.Slice && fv.Len() == 0) {
+			continue
+		}
+		buf.WriteByte(',')
+		key, _ := json.Marshal(name)
+		buf.Write(key)
+		buf.WriteByte(':')
+		if err := encodeAST(buf, fv); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,847 @@
+// Package parser is a recursive-descent parser for the tokens of the
+// tokenizer package: ParseFile builds the AST of a source file.
+package parser
+
+import (
+	"fmt"
+
+	"tokenizer"
+)
+
+// binaryPrec gives the precedence of binary operators; higher binds tighter.
+// The pipeline `x |> f` binds loosest, so `a + b |> f` pipes the sum; `**`
+// binds tightest and is right-associative.
+var binaryPrec = map[tokenizer.TokenType]int{
+	tokenizer.PIPE_FORWARD: 1,
+	tokenizer.OROR:         2,
+	tokenizer.ANDAND:       3,
+	tokenizer.EQ:           4, tokenizer.NE: 4, tokenizer.LT: 4, tokenizer.LE: 4, tokenizer.GT: 4, tokenizer.GE: 4,
+	tokenizer.PLUS: 5, tokenizer.MINUS: 5, tokenizer.BOR: 5, tokenizer.BXOR: 5,
+	tokenizer.STAR: 6, tokenizer.SLASH: 6, tokenizer.PERCENT: 6, tokenizer.SHL: 6, tokenizer.SHR: 6, tokenizer.BAND: 6,
+	tokenizer.POW: 7,
+}
+
+var assignOps = map[tokenizer.TokenType]bool{
+	tokenizer.ASSIGN: true, tokenizer.DECL: true,
+	tokenizer.ADDEQ: true, tokenizer.SUBEQ: true, tokenizer.MULEQ: true, tokenizer.DIVEQ: true, tokenizer.MODEQ: true,
+	tokenizer.ANDEQ: true, tokenizer.OREQ: true, tokenizer.XOREQ: true, tokenizer.SHLEQ: true, tokenizer.SHREQ: true,
+	tokenizer.POWEQ: true,
+}
+
+// Parser builds an AST from the token stream produced by a tokenizer.Lexer.
+// Newlines are not tokens, so a statement also ends where the next token
+// starts on a later line than the previous one ended.
+type Parser struct {
+	toks   []tokenizer.Token
+	pos    int
+	errors []string
+	diags  []tokenizer.Diagnostic
+	filter tokenizer.ErrorFilter
+}
+
+// New returns a parser for toks. DIRECTIVE tokens are left to the
+// preprocessor and PRAGMA tokens to later stages; both are skipped.
+func New(toks []tokenizer.Token) *Parser {
+	for i, t := range toks {
+		if t.Type == tokenizer.DIRECTIVE || t.Type == tokenizer.PRAGMA {
+			kept := append([]tokenizer.Token(nil), toks[:i]...)
+			for _, t := range toks[i:] {
+				if t.Type != tokenizer.DIRECTIVE && t.Type != tokenizer.PRAGMA {
+					kept = append(kept, t)
+				}
+			}
+			toks = kept
+			break
+		}
+	}
+	return &Parser{toks: toks}
+}
+
+// ParseFile parses a whole source file and returns the AST together with the
+// syntax errors, formatted like lexical errors.
+func (p *Parser) ParseFile() (*File, []string) {
+	f := &File{Pos: posOf(p.peek()), Imports: []*ImportDecl{}, Decls: []Node{}}
+	if p.at(tokenizer.KW_PKG) {
+		p.next()
+		f.Package = p.ident()
+		p.endStmt()
+	} else {
+		p.errorAt(p.peek(), "expected pkg clause")
+	}
+	for p.at(tokenizer.KW_IMP) {
+		f.Imports = append(f.Imports, p.importDecls()...)
+	}
+	for !p.at(tokenizer.EOF) {
+		if d := p.topDecl(); d != nil {
+			f.Decls = append(f.Decls, d)
+		}
+	}
+	return f, p.errors
+}
+
+// Diagnostics returns the structured form of the errors reported by ParseFile.
+func (p *Parser) Diagnostics() []tokenizer.Diagnostic {
+	return p.diags
+}
+
+// SetMaxErrors makes the parser keep at most n syntax errors; the rest are
+// only counted, see OmittedErrors. n <= 0 means no limit.
+func (p *Parser) SetMaxErrors(n int) {
+	p.filter.Max = n
+}
+
+// Suppress drops syntax errors with the given codes.
+func (p *Parser) Suppress(codes ...string) {
+	p.filter.Suppress(codes...)
+}
+
+// OmittedErrors returns how many errors were dropped by SetMaxErrors.
+func (p *Parser) OmittedErrors() int {
+	return p.filter.Omitted()
+}
+
+// ---------- token helpers ----------
+
+func (p *Parser) peekAt(n int) tokenizer.Token {
+	if p.pos+n < len(p.toks) {
+		return p.toks[p.pos+n]
+	}
+	return tokenizer.EOFAfter(p.toks)
+}
+
+func (p *Parser) peek() tokenizer.Token { return p.peekAt(0) }
+
+func (p *Parser) at(tt tokenizer.TokenType) bool { return p.peek().Type == tt }
+
+func (p *Parser) next() tokenizer.Token {
+	t := p.peek()
+	if p.pos < len(p.toks) {
+		p.pos++
+	}
+	return t
+}
+
+func (p *Parser) accept(tt tokenizer.TokenType) bool {
+	if p.at(tt) {
+		p.next()
+		return true
+	}
+	return false
+}
+
+func (p *Parser) expect(tt tokenizer.TokenType, what string) tokenizer.Token {
+	t := p.peek()
+	if t.Type != tt {
+		p.errorAt(t, fmt.Sprintf("expected %s, found %s", what, tokenizer.Describe(t)))
+		return t
+	}
+	return p.next()
+}
+
+// nameFollows reports whether the current token, a contextual keyword, is
+// used as a name: it is followed by something that can only come after an
+// operand, such as an assignment, or it ends the statement.
+func (p *Parser) nameFollows() bool {
+	next := p.peekAt(1)
+	if next.Type == tokenizer.EOF || tokenizer.LineBreak(p.peek(), next) {
+		return true
+	}
+	switch next.Type {
+	case tokenizer.COMMA, tokenizer.DOT, tokenizer.COLON, tokenizer.SEMI, tokenizer.INC, tokenizer.DEC, tokenizer.CH_SEND, tokenizer.LBRACK, tokenizer.RPAREN, tokenizer.RBRACK, tokenizer.RBRACE, tokenizer.QUESTION:
+		return true
+	case tokenizer.PLUS, tokenizer.MINUS, tokenizer.STAR, tokenizer.BAND, tokenizer.BXOR, tokenizer.POW:
+		return false // could start the keyword's unary operand
+	}
+	return assignOps[next.Type] || binaryPrec[next.Type] > 0
+}
+
+// errorAt records a syntax error at t. Only the first error at a given
+// position is kept, since later ones are usually consequences of it.
+func (p *Parser) errorAt(t tokenizer.Token, msg string) {
+	if n := len(p.diags); n > 0 && p.diags[n-1].File == t.File && p.diags[n-1].Line == t.Line && p.diags[n-1].Col == t.Column {
+		return
+	}
+	if !p.filter.Admit(tokenizer.ErrSyntax) {
+		return
+	}
+	d := tokenizer.Diagnostic{Phase: "syntax", Code: tokenizer.ErrSyntax, Line: t.Line, Col: t.Column, Offset: t.Offset, End: t.End, Message: msg, File: t.File}
+	p.diags = append(p.diags, d)
+	p.errors = append(p.errors, d.String())
+}
+
+// newLine reports whether the current token starts on a later line than
+// the previous token ended, or in another (included) file.
+func (p *Parser) newLine() bool {
+	if p.pos == 0 || p.pos >= len(p.toks) {
+		return true
+	}
+	return tokenizer.LineBreak(p.toks[p.pos-1], p.toks[p.pos])
+}
+
+// endStmt consumes a statement terminator: ';', or nothing before '}', end
+// of file or a line break.
+func (p *Parser) endStmt() {
+	if p.accept(tokenizer.SEMI) || p.at(tokenizer.RBRACE) || p.at(tokenizer.EOF) || p.newLine() {
+		return
+	}
+	p.errorAt(p.peek(), fmt.Sprintf("expected ';' or newline, found %s", tokenizer.Describe(p.peek())))
+	p.sync()
+}
+
+// sync skips tokens until a likely statement boundary so one mistake does
+// not cascade into many errors. It always consumes at least one token.
+func (p *Parser) sync() {
+	p.next()
+	for !p.at(tokenizer.EOF) && !p.at(tokenizer.RBRACE) && !p.newLine() {
+		if p.next().Type == tokenizer.SEMI {
+			return
+		}
+	}
+}
+
+// ---------- declarations ----------
+
+func (p *Parser) importDecls() []*ImportDecl {
+	p.next() // imp
+	var out []*ImportDecl
+	one := func() {
+		t := p.expect(tokenizer.STRING_LIT, "import path")
+		if t.Type == tokenizer.STRING_LIT {
+			path := t.Lexeme
+			if t.Value != nil {
+				path = *t.Value
+			}
+			out = append(out, &ImportDecl{Pos: posOf(t), Path: path})
+		}
+	}
+	if p.accept(tokenizer.LPAREN) {
+		for !p.at(tokenizer.RPAREN) && !p.at(tokenizer.EOF) {
+			n := p.pos
+			one()
+			p.accept(tokenizer.SEMI)
+			if p.pos == n {
+				p.sync()
+			}
+		}
+		p.expect(tokenizer.RPAREN, "')'")
+	} else {
+		one()
+	}
+	p.endStmt()
+	return out
+}
+
+// annotations parses the `@name` and `@name(args)` before a declaration.
+func (p *Parser) annotations() []*Annotation {
+	var out []*Annotation
+	for p.at(tokenizer.ANNOTATION) {
+		t := p.next()
+		a := &Annotation{Pos: posOf(t), Name: *t.Value}
+		if p.at(tokenizer.LPAREN) && !p.newLine() {
+			p.next()
+			for !p.at(tokenizer.RPAREN) && !p.at(tokenizer.EOF) {
+				a.Args = append(a.Args, p.expr())
+				if !p.accept(tokenizer.COMMA) {
+					break
+				}
+			}
+			p.expect(tokenizer.RPAREN, "')'")
+		}
+		out = append(out, a)
+		p.accept(tokenizer.SEMI)
+	}
+	return out
+}
+
+func (p *Parser) topDecl() Node {
+	if p.at(tokenizer.ANNOTATION) {
+		annots := p.annotations()
+		switch d := p.topDecl().(type) {
+		case *FuncDecl:
+			d.Annotations = append(annots, d.Annotations...)
+			return d
+		case *VarDecl:
+			d.Annotations = append(annots, d.Annotations...)
+			return d
+		case *TypeDecl:
+			d.Annotations = append(annots, d.Annotations...)
+			return d
+		default:
+			return d
+		}
+	}
+	switch p.peek().Type {
+	case tokenizer.KW_DEF:
+		return p.funcDecl()
+	case tokenizer.KW_VAR, tokenizer.KW_CONS:
+		d := p.varDecl()
+		p.endStmt()
+		return d
+	case tokenizer.KW_TYPE:
+		d := p.typeDecl()
+		p.endStmt()
+		return d
+	case tokenizer.SEMI:
+		p.next()
+		return nil
+	}
+	p.errorAt(p.peek(), fmt.Sprintf("expected declaration, found %s", tokenizer.Describe(p.peek())))
+	p.sync()
+	return nil
+}
+
+func (p *Parser) ident() *Ident {
+	if t := p.peek(); t.AsIdent {
+		p.next()
+		return &Ident{Pos: posOf(t), Name: t.Lexeme}
+	}
+	t := p.expect(tokenizer.IDENT, "identifier")
+	if t.Type != tokenizer.IDENT {
+		return &Ident{Pos: posOf(t)}
+	}
+	return &Ident{Pos: posOf(t), Name: tokenizer.NameOf(t)}
+}
+
+func (p *Parser) funcDecl() *FuncDecl {
+	f := &FuncDecl{Pos: posOf(p.next())}
+	f.Name = p.ident()
+	f.Params = p.params()
+	if p.accept(tokenizer.COLON) || p.accept(tokenizer.ARROW) {
+		f.Result = p.typeExpr()
+	}
+	f.Body = p.block()
+	return f
+}
+
+// params parses `(a, b: T, c: U)`; the last parameter may be `c: ...U`.
+func (p *Parser) params() []*Field {
+	p.expect(tokenizer.LPAREN, "'('")
+	fields := []*Field{}
+	for !p.at(tokenizer.RPAREN) && !p.at(tokenizer.EOF) {
+		f := p.field()
+		fields = append(fields, f)
+		if !p.accept(tokenizer.COMMA) {
+			break
+		}
+		if f.Variadic {
+			p.errorAt(p.peek(), "only the last parameter can be variadic")
+		}
+	}
+	p.expect(tokenizer.RPAREN, "')'")
+	return fields
+}
+
+// field parses `name {, name} : type`.
+func (p *Parser) field() *Field {
+	f := &Field{Pos: posOf(p.peek())}
+	f.Names = append(f.Names, p.ident())
+	for p.at(tokenizer.COMMA) && p.peekAt(1).Type == tokenizer.IDENT {
+		p.next()
+		f.Names = append(f.Names, p.ident())
+	}
+	p.expect(tokenizer.COLON, "':'")
+	f.Variadic = p.accept(tokenizer.ELLIPSIS)
+	f.Type = p.typeExpr()
+	return f
+}
+
+func (p *Parser) varDecl() *VarDecl {
+	t := p.next()
+	d := &VarDecl{Pos: posOf(t), Const: t.Type == tokenizer.KW_CONS}
+	d.Names = append(d.Names, p.ident())
+	for p.accept(tokenizer.COMMA) {
+		d.Names = append(d.Names, p.ident())
+	}
+	if p.accept(tokenizer.COLON) {
+		d.Type = p.typeExpr()
+	}
+	if p.accept(tokenizer.ASSIGN) {
+		d.Values = p.exprList()
+	} else if d.Const {
+		p.errorAt(p.peek(), "missing value in cons declaration")
+	}
+	return d
+}
+
+func (p *Parser) typeDecl() *TypeDecl {
+	d := &TypeDecl{Pos: posOf(p.next())}
+	d.Name = p.ident()
+	d.Type = p.typeExpr()
+	return d
+}
+
+// ---------- types ----------
+
+func (p *Parser) typeExpr() Node {
+	t := p.peek()
+	switch t.Type {
+	case tokenizer.TYPE_NAME:
+		p.next()
+		return &Ident{Pos: posOf(t), Name: t.Lexeme}
+	case tokenizer.IDENT:
+		var n Node = p.ident()
+		if p.at(tokenizer.DOT) {
+			p.next()
+			n = &SelectorExpr{Pos: posOf(t), X: n, Sel: p.ident()}
+		}
+		return n
+	case tokenizer.LBRACK:
+		p.next()
+		a := &ArrayType{Pos: posOf(t)}
+		if !p.at(tokenizer.RBRACK) {
+			a.Len = p.expr()
+		}
+		p.expect(tokenizer.RBRACK, "']'")
+		a.Elem = p.typeExpr()
+		return a
+	case tokenizer.KW_MAPPING:
+		p.next()
+		m := &MapType{Pos: posOf(t)}
+		p.expect(tokenizer.LBRACK, "'['")
+		m.Key = p.typeExpr()
+		p.expect(tokenizer.RBRACK, "']'")
+		m.Value = p.typeExpr()
+		return m
+	case tokenizer.KW_CHANNEL:
+		p.next()
+		return &ChanType{Pos: posOf(t), Elem: p.typeExpr()}
+	case tokenizer.STAR:
+		p.next()
+		return &PointerType{Pos: posOf(t), Elem: p.typeExpr()}
+	case tokenizer.POW: // **T
+		p.next()
+		inner := &PointerType{Pos: tokenizer.Pos{Line: t.Line, Col: t.Column + 1}, Elem: p.typeExpr()}
+		return &PointerType{Pos: posOf(t), Elem: inner}
+	case tokenizer.LPAREN:
+		p.next()
+		n := p.typeExpr()
+		p.expect(tokenizer.RPAREN, "')'")
+		return n
+	case tokenizer.KW_STRUCT:
+		p.next()
+		s := &StructType{Pos: posOf(t), Fields: []*Field{}}
+		p.expect(tokenizer.LBRACE, "'{'")
+		for !p.at(tokenizer.RBRACE) && !p.at(tokenizer.EOF) {
+			n := p.pos
+			s.Fields = append(s.Fields, p.field())
+			p.endStmt()
+			if p.pos == n {
+				p.sync()
+			}
+		}
+		p.expect(tokenizer.RBRACE, "'}'")
+		return s
+	case tokenizer.KW_INTERFACE:
+		p.next()
+		it := &InterfaceType{Pos: posOf(t), Methods: []*MethodSpec{}}
+		p.expect(tokenizer.LBRACE, "'{'")
+		for !p.at(tokenizer.RBRACE) && !p.at(tokenizer.EOF) {
+			n := p.pos
+			m := &MethodSpec{Pos: posOf(p.peek())}
+			m.Name = p.ident()
+			m.Params = p.params()
+			if p.accept(tokenizer.COLON) || p.accept(tokenizer.ARROW) {
+				m.Result = p.typeExpr()
+			}
+			it.Methods = append(it.Methods, m)
+			p.endStmt()
+			if p.pos == n {
+				p.sync()
+			}
+		}
+		p.expect(tokenizer.RBRACE, "'}'")
+		return it
+	}
+	p.errorAt(t, fmt.Sprintf("expected type, found %s", tokenizer.Describe(t)))
+	return &BadNode{Pos: posOf(t)}
+}
+
+// ---------- statements ----------
+
+func (p *Parser) block() *BlockStmt {
+	b := &BlockStmt{Pos: posOf(p.peek()), Stmts: []Node{}}
+	p.expect(tokenizer.LBRACE, "'{'")
+	for !p.at(tokenizer.RBRACE) && !p.at(tokenizer.EOF) {
+		n := p.pos
+		if s := p.stmt(); s != nil {
+			b.Stmts = append(b.Stmts, s)
+		}
+		if p.pos == n {
+			p.sync()
+		}
+	}
+	p.expect(tokenizer.RBRACE, "'}'")
+	return b
+}
+
+// stmt parses one statement including its terminator.
+func (p *Parser) stmt() Node {
+	t := p.peek()
+	if t.AsIdent && p.nameFollows() {
+		t.Type = tokenizer.IDENT
+	}
+	switch t.Type {
+	case tokenizer.SEMI:
+		p.next()
+		return nil
+	case tokenizer.KW_VAR, tokenizer.KW_CONS:
+		d := p.varDecl()
+		p.endStmt()
+		return d
+	case tokenizer.KW_TYPE:
+		d := p.typeDecl()
+		p.endStmt()
+		return d
+	case tokenizer.LBRACE:
+		return p.block()
+	case tokenizer.KW_IF:
+		return p.ifStmt()
+	case tokenizer.KW_FR:
+		return p.forStmt()
+	case tokenizer.KW_SWITCH:
+		return p.switchStmt()
+	case tokenizer.KW_SELECT:
+		return p.selectStmt()
+	case tokenizer.KW_RET:
+		p.next()
+		r := &ReturnStmt{Pos: posOf(t)}
+		if !p.at(tokenizer.SEMI) && !p.at(tokenizer.RBRACE) && !p.at(tokenizer.EOF) && !p.newLine() {
+			r.Results = p.exprList()
+		}
+		p.endStmt()
+		return r
+	case tokenizer.KW_BREAK, tokenizer.KW_CONTINUE, tokenizer.KW_JOTO, tokenizer.KW_FALL:
+		p.next()
+		b := &BranchStmt{Pos: posOf(t), Tok: t.Type}
+		if t.Type == tokenizer.KW_JOTO || (t.Type != tokenizer.KW_FALL && p.at(tokenizer.IDENT) && !p.newLine()) {
+			b.Label = p.ident()
+		}
+		p.endStmt()
+		return b
+	case tokenizer.KW_LATER, tokenizer.KW_J:
+		p.next()
+		call := p.expr()
+		if _, ok := call.(*CallExpr); !ok {
+			p.errorAt(t, fmt.Sprintf("expression in %s must be a function call", t.Lexeme))
+		}
+		p.endStmt()
+		if t.Type == tokenizer.KW_LATER {
+			return &LaterStmt{Pos: posOf(t), Call: call}
+		}
+		return &GoStmt{Pos: posOf(t), Call: call}
+	case tokenizer.KW_PANIC:
+		p.next()
+		s := &PanicStmt{Pos: posOf(t), X: p.expr()}
+		p.endStmt()
+		return s
+	case tokenizer.IDENT:
+		if p.peekAt(1).Type == tokenizer.COLON {
+			p.next()
+			p.next()
+			l := &LabeledStmt{Pos: posOf(t), Label: &Ident{Pos: posOf(t), Name: tokenizer.NameOf(t)}}
+			if !p.at(tokenizer.RBRACE) {
+				l.Stmt = p.stmt()
+			}
+			return l
+		}
+	}
+	s := p.simpleStmt(false)
+	p.endStmt()
+	return s
+}
+
+// simpleStmt parses an expression, assignment or send statement. With
+// rangeOK it also accepts the `k, v := range x` header of a fr loop.
+func (p *Parser) simpleStmt(rangeOK bool) Node {
+	t := p.peek()
+	if rangeOK && t.Type == tokenizer.KW_RANGE && !(t.AsIdent && p.nameFollows()) {
+		p.next()
+		return &RangeStmt{Pos: posOf(t), X: p.expr()}
+	}
+	lhs := p.exprList()
+	op := p.peek()
+	switch {
+	case assignOps[op.Type]:
+		p.next()
+		if rangeOK && (op.Type == tokenizer.ASSIGN || op.Type == tokenizer.DECL) && p.at(tokenizer.KW_RANGE) && !(p.peek().AsIdent && p.nameFollows()) {
+			p.next()
+			r := &RangeStmt{Pos: posOf(t), Define: op.Type == tokenizer.DECL, Key: lhs[0], X: p.expr()}
+			if len(lhs) > 1 {
+				r.Value = lhs[1]
+			}
+			if len(lhs) > 2 {
+				p.errorAt(t, "range permits at most two iteration variables")
+			}
+			return r
+		}
+		return &AssignStmt{Pos: posOf(t), Lhs: lhs, Op: op.Type, Rhs: p.exprList()}
+	case op.Type == tokenizer.CH_SEND:
+		p.next()
+		return &SendStmt{Pos: posOf(t), Chan: lhs[0], Value: p.expr()}
+	case op.Type == tokenizer.INC || op.Type == tokenizer.DEC:
+		p.next()
+		if len(lhs) > 1 {
+			p.errorAt(op, fmt.Sprintf("%s applies to a single operand", op.Lexeme))
+		}
+		return &IncDecStmt{Pos: posOf(t), X: lhs[0], Op: op.Type}
+	}
+	if len(lhs) > 1 {
+		p.errorAt(op, fmt.Sprintf("expected assignment, found %s", tokenizer.Describe(op)))
+	}
+	return &ExprStmt{Pos: posOf(t), X: lhs[0]}
+}
+
+func (p *Parser) ifStmt() *IfStmt {
+	s := &IfStmt{Pos: posOf(p.next())}
+	s.Cond = p.expr()
+	s.Then = p.block()
+	if p.at(tokenizer.KW_ELSE) {
+		p.next()
+		if p.at(tokenizer.KW_IF) {
+			s.Else = p.ifStmt()
+		} else {
+			s.Else = p.block()
+		}
+	}
+	return s
+}
+
+// forStmt parses the fr loop forms: `fr {}`, `fr cond {}`,
+// `fr init; cond; post {}` and `fr k, v := range x {}`.
+func (p *Parser) forStmt() Node {
+	t := p.next()
+	if p.at(tokenizer.LBRACE) {
+		return &ForStmt{Pos: posOf(t), Body: p.block()}
+	}
+	var init Node
+	if !p.at(tokenizer.SEMI) {
+		init = p.simpleStmt(true)
+	}
+	if r, ok := init.(*RangeStmt); ok {
+		r.Pos = posOf(t)
+		r.Body = p.block()
+		return r
+	}
+	if p.at(tokenizer.LBRACE) {
+		s := &ForStmt{Pos: posOf(t)}
+		if e, ok := init.(*ExprStmt); ok {
+			s.Cond = e.X
+		} else {
+			p.errorAt(t, "expected loop condition")
+		}
+		s.Body = p.block()
+		return s
+	}
+	s := &ForStmt{Pos: posOf(t), Init: init}
+	p.expect(tokenizer.SEMI, "';'")
+	if !p.at(tokenizer.SEMI) {
+		s.Cond = p.expr()
+	}
+	p.expect(tokenizer.SEMI, "';'")
+	if !p.at(tokenizer.LBRACE) {
+		s.Post = p.simpleStmt(false)
+	}
+	s.Body = p.block()
+	return s
+}
+
+func (p *Parser) switchStmt() *SwitchStmt {
+	s := &SwitchStmt{Pos: posOf(p.next())}
+	if !p.at(tokenizer.LBRACE) {
+		s.Tag = p.expr()
+	}
+	s.Cases = p.caseClauses(false)
+	return s
+}
+
+func (p *Parser) selectStmt() *SelectStmt {
+	s := &SelectStmt{Pos: posOf(p.next())}
+	s.Cases = p.caseClauses(true)
+	return s
+}
+
+// caseClauses parses `{ case ...: stmts  dft: stmts }`. In a select each
+// case holds a single send or receive statement.
+func (p *Parser) caseClauses(comm bool) []*CaseClause {
+	p.expect(tokenizer.LBRACE, "'{'")
+	cases := []*CaseClause{}
+	for !p.at(tokenizer.RBRACE) && !p.at(tokenizer.EOF) {
+		t := p.peek()
+		c := &CaseClause{Pos: posOf(t), Body: []Node{}}
+		switch t.Type {
+		case tokenizer.KW_CASE:
+			p.next()
+			if comm {
+				c.List = []Node{p.simpleStmt(false)}
+			} else {
+				c.List = p.exprList()
+			}
+		case tokenizer.KW_DFT:
+			p.next()
+		default:
+			p.errorAt(t, fmt.Sprintf("expected case or dft, found %s", tokenizer.Describe(t)))
+			p.sync()
+			continue
+		}
+		p.expect(tokenizer.COLON, "':'")
+		for !p.at(tokenizer.KW_CASE) && !p.at(tokenizer.KW_DFT) && !p.at(tokenizer.RBRACE) && !p.at(tokenizer.EOF) {
+			n := p.pos
+			if st := p.stmt(); st != nil {
+				c.Body = append(c.Body, st)
+			}
+			if p.pos == n {
+				p.sync()
+			}
+		}
+		cases = append(cases, c)
+	}
+	p.expect(tokenizer.RBRACE, "'}'")
+	return cases
+}
+
+// ---------- expressions ----------
+
+func (p *Parser) exprList() []Node {
+	list := []Node{p.expr()}
+	for p.accept(tokenizer.COMMA) {
+		list = append(list, p.expr())
+	}
+	return list
+}
+
+// expr parses a binary expression, optionally followed by `? x : y`
+// (right-associative, binding looser than every binary operator).
+func (p *Parser) expr() Node {
+	x := p.binaryExpr(1)
+	if !p.at(tokenizer.QUESTION) {
+		return x
+	}
+	p.next()
+	c := &CondExpr{Pos: x.Position(), Cond: x, Then: p.expr()}
+	p.expect(tokenizer.COLON, "':' in conditional expression")
+	c.Else = p.expr()
+	return c
+}
+
+func (p *Parser) binaryExpr(minPrec int) Node {
+	x := p.unaryExpr()
+	for {
+		op := p.peek()
+		prec, ok := binaryPrec[op.Type]
+		if !ok || prec < minPrec {
+			return x
+		}
+		p.next()
+		next := prec + 1
+		if op.Type == tokenizer.POW {
+			next = prec // right-associative: 2 ** 3 ** 2 is 2 ** (3 ** 2)
+		}
+		y := p.binaryExpr(next)
+		x = &BinaryExpr{Pos: x.Position(), Op: op.Type, X: x, Y: y}
+	}
+}
+
+func (p *Parser) unaryExpr() Node {
+	t := p.peek()
+	switch t.Type {
+	case tokenizer.PLUS, tokenizer.MINUS, tokenizer.BANG, tokenizer.BXOR, tokenizer.STAR, tokenizer.BAND, tokenizer.CH_SEND:
+		p.next()
+		return &UnaryExpr{Pos: posOf(t), Op: t.Type, X: p.unaryExpr()}
+	case tokenizer.POW: // **p lexes as one token but is two dereferences
+		p.next()
+		inner := &UnaryExpr{Pos: tokenizer.Pos{Line: t.Line, Col: t.Column + 1}, Op: tokenizer.STAR, X: p.unaryExpr()}
+		return &UnaryExpr{Pos: posOf(t), Op: tokenizer.STAR, X: inner}
+	}
+	return p.primaryExpr()
+}
+
+func (p *Parser) primaryExpr() Node {
+	x := p.operand()
+	for {
+		t := p.peek()
+		switch {
+		case t.Type == tokenizer.DOT:
+			p.next()
+			x = &SelectorExpr{Pos: x.Position(), X: x, Sel: p.ident()}
+		case t.Type == tokenizer.LPAREN && !p.newLine():
+			p.next()
+			call := &CallExpr{Pos: x.Position(), Fun: x, Args: []Node{}}
+			for !p.at(tokenizer.RPAREN) && !p.at(tokenizer.EOF) {
+				call.Args = append(call.Args, p.expr())
+				if p.accept(tokenizer.ELLIPSIS) {
+					call.Spread = true
+					p.accept(tokenizer.COMMA)
+					break
+				}
+				if !p.accept(tokenizer.COMMA) {
+					break
+				}
+			}
+			p.expect(tokenizer.RPAREN, "')'")
+			x = call
+		case t.Type == tokenizer.LBRACK && !p.newLine():
+			p.next()
+			x = &IndexExpr{Pos: x.Position(), X: x, Index: p.expr()}
+			p.expect(tokenizer.RBRACK, "']'")
+		default:
+			return x
+		}
+	}
+}
+
+// interpString parses "a${x}b${y}c", lexed as segments around
+// INTERP_START expr INTERP_END.
+func (p *Parser) interpString() Node {
+	x := &InterpString{Pos: posOf(p.peek()), Parts: []Node{}}
+	for {
+		seg := p.next()
+		text := seg.Lexeme
+		if seg.Value != nil {
+			text = *seg.Value // without the quote and ${ } delimiters, escapes decoded
+		}
+		x.Parts = append(x.Parts, &BasicLit{Pos: posOf(seg), Type: seg.Type, Value: text})
+		if !p.accept(tokenizer.INTERP_START) {
+			return x
+		}
+		x.Parts = append(x.Parts, p.expr())
+		if p.expect(tokenizer.INTERP_END, "'}' closing the interpolation").Type != tokenizer.INTERP_END || !p.at(tokenizer.STRING_SEGMENT) {
+			return x
+		}
+	}
+}
+
+func (p *Parser) operand() Node {
+	t := p.peek()
+	if t.AsIdent {
+		p.next()
+		return &Ident{Pos: posOf(t), Name: t.Lexeme}
+	}
+	switch t.Type {
+	case tokenizer.IDENT, tokenizer.TYPE_NAME, tokenizer.KW_RECOVER:
+		p.next()
+		return &Ident{Pos: posOf(t), Name: tokenizer.NameOf(t)}
+	case tokenizer.INT_LIT, tokenizer.FLOAT_LIT, tokenizer.STRING_LIT, tokenizer.CHAR_LIT:
+		p.next()
+		return &BasicLit{Pos: posOf(t), Type: t.Type, Value: t.Lexeme}
+	case tokenizer.STRING_SEGMENT:
+		return p.interpString()
+	case tokenizer.LPAREN:
+		p.next()
+		x := p.expr()
+		p.expect(tokenizer.RPAREN, "')'")
+		return &ParenExpr{Pos: posOf(t), X: x}
+	case tokenizer.LBRACK, tokenizer.KW_MAPPING:
+		lit := &CompositeLit{Pos: posOf(t), Type: p.typeExpr(), Elts: []Node{}}
+		p.expect(tokenizer.LBRACE, "'{'")
+		for !p.at(tokenizer.RBRACE) && !p.at(tokenizer.EOF) {
+			lit.Elts = append(lit.Elts, p.expr())
+			if !p.accept(tokenizer.COMMA) {
+				break
+			}
+		}
+		p.expect(tokenizer.RBRACE, "'}'")
+		return lit
+	}
+	p.errorAt(t, fmt.Sprintf("expected expression, found %s", tokenizer.Describe(t)))
+	return &BadNode{Pos: posOf(t)}
+}
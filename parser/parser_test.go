@@ -0,0 +1,206 @@
+package parser
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"tokenizer"
+)
+
+// parseBody parses body as the statements of a function whose first line
+// is line 3 of the file.
+func parseBody(t *testing.T, body string) ([]Node, []tokenizer.Diagnostic) {
+	t.Helper()
+	toks, errs := tokenizer.NewLexer("pkg main\ndef f() {\n" + body + "\n}\n").LexAll()
+	if len(errs) > 0 {
+		t.Fatalf("%q: %q", body, errs)
+	}
+	p := New(toks)
+	f, _ := p.ParseFile()
+	return f.Decls[0].(*FuncDecl).Body.Stmts, p.Diagnostics()
+}
+
+// exprString writes x with every binary, unary and conditional expression
+// parenthesized, so the tests can tell how it was grouped.
+func exprString(x Node) string {
+	switch x := x.(type) {
+	case *Ident:
+		return x.Name
+	case *BasicLit:
+		return x.Value
+	case *ParenExpr:
+		return "paren" + exprString(x.X)
+	case *UnaryExpr:
+		return fmt.Sprintf("(%s %s)", x.Op, exprString(x.X))
+	case *BinaryExpr:
+		return fmt.Sprintf("(%s %s %s)", exprString(x.X), x.Op, exprString(x.Y))
+	case *CondExpr:
+		return fmt.Sprintf("(%s ? %s : %s)", exprString(x.Cond), exprString(x.Then), exprString(x.Else))
+	case *CallExpr:
+		var args []string
+		for _, a := range x.Args {
+			args = append(args, exprString(a))
+		}
+		return fmt.Sprintf("%s(%s)", exprString(x.Fun), strings.Join(args, ", "))
+	case *SelectorExpr:
+		return exprString(x.X) + "." + x.Sel.Name
+	case *IndexExpr:
+		return fmt.Sprintf("%s[%s]", exprString(x.X), exprString(x.Index))
+	case *InterpString:
+		var parts []string
+		for _, p := range x.Parts {
+			parts = append(parts, fmt.Sprintf("%q", exprString(p)))
+		}
+		return "interp(" + strings.Join(parts, ", ") + ")"
+	}
+	return fmt.Sprintf("%T", x)
+}
+
+func TestParseExpr(t *testing.T) {
+	tests := []struct{ src, want string }{
+		{"a + b * c", "(a PLUS (b STAR c))"},
+		{"a * b + c", "((a STAR b) PLUS c)"},
+		{"a - b - c", "((a MINUS b) MINUS c)"},
+		{"a || b && c == d", "(a OROR (b ANDAND (c EQ d)))"},
+		{"a << 1 + b", "((a SHL 1) PLUS b)"},
+		{"(a + b) * c", "(paren(a PLUS b) STAR c)"},
+		{"-a * !b", "((MINUS a) STAR (BANG b))"},
+		{"f(a + b, c)[i].x", "f((a PLUS b), c)[i].x"},
+		// |> binds loosest and associates left
+		{"a + b |> f", "((a PLUS b) PIPE_FORWARD f)"},
+		{"x |> f |> g", "((x PIPE_FORWARD f) PIPE_FORWARD g)"},
+		{"a || b |> f", "((a OROR b) PIPE_FORWARD f)"},
+		// ** binds tightest and associates right
+		{"a ** b ** c", "(a POW (b POW c))"},
+		{"2 * a ** b", "(2 STAR (a POW b))"},
+		{"a ** b * c", "((a POW b) STAR c)"},
+		// ?: takes a whole binary expression as its condition and nests
+		// to the right
+		{"a ? b : c", "(a ? b : c)"},
+		{"a || b ? c : d", "((a OROR b) ? c : d)"},
+		{"a ? b : c ? d : e", "(a ? b : (c ? d : e))"},
+		{"a ? b ? c : d : e", "(a ? (b ? c : d) : e)"},
+		{"a ? b : c |> f", "(a ? b : (c PIPE_FORWARD f))"},
+		// interpolated strings keep the decoded text of their segments
+		{`"a${b}c"`, `interp("a", "b", "c")`},
+		{`"${a + b}\t\${${c}"`, `interp("", "(a PLUS b)", "\t${", "c", "")`},
+	}
+	for _, tt := range tests {
+		stmts, diags := parseBody(t, "x := "+tt.src)
+		if len(diags) > 0 || len(stmts) != 1 {
+			t.Errorf("%q: got %d statements and %v", tt.src, len(stmts), diags)
+			continue
+		}
+		if got := exprString(stmts[0].(*AssignStmt).Rhs[0]); got != tt.want {
+			t.Errorf("%q: got %s, want %s", tt.src, got, tt.want)
+		}
+	}
+}
+
+func isFor(n Node) bool {
+	_, ok := n.(*ForStmt)
+	return ok
+}
+
+func TestParseRange(t *testing.T) {
+	tests := []struct {
+		src        string
+		key, value string // "" when absent
+		define     bool
+		x          string
+	}{
+		{"fr i, v := range xs {}", "i", "v", true, "xs"},
+		{"fr k := range m {}", "k", "", true, "m"},
+		{"fr k = range m {}", "k", "", false, "m"},
+		{"fr range ch {}", "", "", false, "ch"},
+		{"fr _, v := range f(x) {}", "_", "v", true, "f(x)"},
+	}
+	name := func(n Node) string {
+		if n == nil {
+			return ""
+		}
+		return exprString(n)
+	}
+	for _, tt := range tests {
+		stmts, diags := parseBody(t, tt.src)
+		r, ok := stmts[0].(*RangeStmt)
+		if len(diags) > 0 || !ok {
+			t.Errorf("%q: got %T and %v, want a RangeStmt", tt.src, stmts[0], diags)
+			continue
+		}
+		if name(r.Key) != tt.key || name(r.Value) != tt.value || r.Define != tt.define || exprString(r.X) != tt.x || r.Body == nil {
+			t.Errorf("%q: got key %q value %q define %v over %q", tt.src, name(r.Key), name(r.Value), r.Define, exprString(r.X))
+		}
+		if r.Line != 3 || r.Col != 1 {
+			t.Errorf("%q: at %d:%d, want 3:1", tt.src, r.Line, r.Col)
+		}
+	}
+
+	if stmts, _ := parseBody(t, "fr i := 0; i < n; i++ {}"); !isFor(stmts[0]) {
+		t.Errorf("three-clause loop parsed as %T", stmts[0])
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		src  string
+		want []string // line:col message
+	}{
+		{"def f() {}", []string{"1:1 expected pkg clause"}},
+		{"pkg main\ndef f() { x := }", []string{`2:16 expected expression, found "}"`}},
+		{"pkg main\ndef f() {\n\tx := (1 + 2\n}", []string{`4:1 expected ')', found "}"`}},
+		{"pkg main\ndef f() {", []string{"2:10 expected '}', found end of file"}},
+		{"pkg main\ndef f() { switch x { y } }", []string{`2:22 expected case or dft, found "y"`}},
+		{"pkg main\ndef f() { a, b++ }", []string{"2:15 ++ applies to a single operand"}},
+		{"pkg main\ndef f() {\n\tfr a, b, c := range xs {}\n}", []string{"3:5 range permits at most two iteration variables"}},
+		// the parser recovers at the next statement
+		{"pkg main\ndef f() {\n\tx := )\n\ty := 2\n\tz := ]\n}", []string{
+			`3:7 expected expression, found ")"`,
+			`5:7 expected expression, found "]"`,
+		}},
+	}
+	for _, tt := range tests {
+		toks, _ := tokenizer.NewLexer(tt.src).LexAll()
+		p := New(toks)
+		_, errs := p.ParseFile()
+		var got []string
+		for _, d := range p.Diagnostics() {
+			got = append(got, fmt.Sprintf("%d:%d %s", d.Line, d.Col, d.Message))
+			if d.Phase != "syntax" || d.Code != tokenizer.ErrSyntax {
+				t.Errorf("%q: %s is a %s %s, want a syntax error", tt.src, d.Message, d.Phase, d.Code)
+			}
+		}
+		if fmt.Sprint(got) != fmt.Sprint(tt.want) || len(errs) != len(got) {
+			t.Errorf("%q: got %q, want %q", tt.src, got, tt.want)
+		}
+	}
+}
+
+// FuzzParseFile checks that the parser never panics or hangs on whatever
+// tokens the lexer gives it. The example programs of the repository seed it.
+func FuzzParseFile(f *testing.F) {
+	for _, name := range []string{"../main.jl", "../errors_demo.jl"} {
+		src, err := os.ReadFile(name)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(string(src))
+	}
+	f.Add("pkg main\ndef f(xs: ...i32) { ret c ? f(xs...) : x |> g ** 2 }")
+	f.Fuzz(func(t *testing.T, src string) {
+		toks, _ := tokenizer.NewLexer(src).LexAll()
+		done := make(chan struct{})
+		go func() {
+			New(toks).ParseFile()
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(10 * time.Second):
+			t.Fatalf("%q: parsing did not finish", src)
+		}
+	})
+}
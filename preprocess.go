@@ -0,0 +1,253 @@
+package tokenizer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Preprocessor expands directives in a lexed token stream. `#include
+// "path"` is replaced by the tokens of the named file, which is looked up
+// next to the including file and then in the include path. Included tokens
+// keep the positions of their own file; their File indexes Files.
+// `#define` and `#undef` manage macros, which are expanded wherever their
+// name appears after the definition; the tokens of an expansion keep the
+// positions they have in the #define line or the macro call's arguments.
+type Preprocessor struct {
+	includePath []string
+	newLexer    func(src string) *Lexer
+	filter      ErrorFilter
+
+	macros map[string]*macro
+	files  []Source
+	index  map[string]int  // cleaned path -> index in files
+	tokens map[int][]Token // lexed tokens of each included file
+	open   []string        // the chain of includes being expanded, for cycles
+	diags  []Diagnostic
+
+	skipped       []skippedSpan // text left out by #if
+	checkInactive bool
+	// directives already reported, by file and offset: a file included
+	// twice is expanded twice
+	reported map[[2]int]bool
+	// tokens produced by macro expansion so far, up to maxMacroTokens
+	expanded int
+}
+
+// NewPreprocessor returns a preprocessor that searches includePath for
+// included files and lexes them with lexers made by newLexer (NewLexer when
+// nil).
+func NewPreprocessor(includePath []string, newLexer func(src string) *Lexer) *Preprocessor {
+	if newLexer == nil {
+		newLexer = func(src string) *Lexer { return NewLexer(src) }
+	}
+	return &Preprocessor{
+		includePath: includePath,
+		newLexer:    newLexer,
+		macros:      map[string]*macro{},
+		index:       map[string]int{},
+		tokens:      map[int][]Token{},
+		reported:    map[[2]int]bool{},
+	}
+}
+
+// Suppress drops preprocessor errors with the given codes, e.g. ErrInclude.
+func (pp *Preprocessor) Suppress(codes ...string) {
+	pp.filter.Suppress(codes...)
+}
+
+// Expand preprocesses toks, the tokens of the file name with contents src.
+// That file becomes Files()[0].
+func (pp *Preprocessor) Expand(name, src string, toks []Token) []Token {
+	pp.files = append(pp.files[:0], Source{Name: name, Text: src})
+	pp.index[filepath.Clean(name)] = 0
+	pp.open = []string{filepath.Clean(name)}
+	return pp.expand(toks, 0)
+}
+
+// Files returns the file table: the expanded file followed by every file it
+// includes, directly or not.
+func (pp *Preprocessor) Files() []Source {
+	return pp.files
+}
+
+// Diagnostics returns the preprocessor errors and the lexical errors of the
+// included files, except those Skipped. Their File says which file each one
+// belongs to.
+func (pp *Preprocessor) Diagnostics() []Diagnostic {
+	var diags []Diagnostic
+	for _, d := range pp.diags {
+		if !pp.Skipped(d) {
+			diags = append(diags, d)
+		}
+	}
+	return diags
+}
+
+// Errors returns Diagnostics as strings, prefixed with the file name for
+// errors outside the expanded file.
+func (pp *Preprocessor) Errors() []string {
+	var errs []string
+	for _, d := range pp.Diagnostics() {
+		e := d.String()
+		if d.File != 0 {
+			e = pp.files[d.File].Name + ": " + e
+		}
+		errs = append(errs, e)
+	}
+	return errs
+}
+
+// Define defines the object-like macro name as value, like `#define name
+// value` before the first line. Its tokens have no position in any file.
+func (pp *Preprocessor) Define(name, value string) error {
+	if toks, _ := NewLexer(name).LexAll(); len(toks) != 1 || toks[0].Type != IDENT || toks[0].Lexeme != name {
+		return fmt.Errorf("invalid macro name %q", name)
+	}
+	lx := pp.newLexer(value)
+	body, errs := lx.LexAll()
+	if len(errs) > 0 {
+		return fmt.Errorf("macro %s: %s", name, errs[0])
+	}
+	for i := range body {
+		body[i].Line, body[i].Column, body[i].Offset, body[i].End, body[i].Cols = 0, 0, 0, 0, nil
+	}
+	pp.macros[name] = &macro{name: name, body: body}
+	return nil
+}
+
+func (pp *Preprocessor) errorAt(t Token, code, msg string) {
+	at := [2]int{t.File, t.Offset}
+	if pp.reported[at] || !pp.filter.Admit(code) {
+		return
+	}
+	pp.reported[at] = true
+	pp.diags = append(pp.diags, Diagnostic{Phase: "preprocessor", Code: code, Line: t.Line, Col: t.Column, Offset: t.Offset, End: t.End, Message: msg, File: t.File})
+}
+
+func (pp *Preprocessor) expand(toks []Token, file int) []Token {
+	var (
+		out   []Token
+		conds []cond
+		from  int // where the current skipped stretch began
+	)
+	for i := 0; i < len(toks); i++ {
+		t := toks[i]
+		if t.Type == DIRECTIVE {
+			was := activeConds(conds)
+			if pp.conditional(t, &conds) {
+				switch now := activeConds(conds); {
+				case was && !now:
+					from = t.End
+				case !was && now:
+					pp.skip(file, from, t.Offset)
+				}
+				continue
+			}
+		}
+		if !activeConds(conds) {
+			continue
+		}
+		if t.Type == DIRECTIVE {
+			switch *t.Value {
+			case "include":
+				out = append(out, pp.include(t, file)...)
+				continue
+			case "define":
+				pp.define(t)
+				continue
+			case "undef":
+				pp.undef(t)
+				continue
+			}
+		}
+		if exp, n := pp.macroCall(toks, i, nil); n > 0 {
+			site := &MacroSite{Macro: t.Lexeme, File: t.File, Line: t.Line, Col: t.Column}
+			for j := range exp {
+				exp[j].Expansion = site
+			}
+			out = append(out, exp...)
+			i += n - 1
+			continue
+		}
+		out = append(out, t)
+	}
+	if len(conds) > 0 {
+		if !activeConds(conds) {
+			pp.skip(file, from, len(pp.files[file].Text))
+		}
+		for _, c := range conds {
+			pp.errorAt(c.at, ErrCondition, fmt.Sprintf("#%s without #endif", *c.at.Value))
+		}
+	}
+	return out
+}
+
+// include returns the expanded tokens of the file named by the #include
+// directive t, found in file.
+func (pp *Preprocessor) include(t Token, file int) []Token {
+	arg := ""
+	if t.Arg != nil {
+		arg = *t.Arg
+	}
+	name, err := strconv.Unquote(arg)
+	if err != nil || name == "" {
+		pp.errorAt(t, ErrInclude, fmt.Sprintf("#include expects a quoted file name, found %q", arg))
+		return nil
+	}
+	path, data, err := pp.find(name, filepath.Dir(pp.files[file].Name))
+	if err != nil {
+		pp.errorAt(t, ErrInclude, err.Error())
+		return nil
+	}
+	for i, p := range pp.open {
+		if p == path {
+			chain := append(append([]string(nil), pp.open[i:]...), path)
+			pp.errorAt(t, ErrInclude, "include cycle: "+strings.Join(chain, " -> "))
+			return nil
+		}
+	}
+
+	idx, seen := pp.index[path]
+	if !seen {
+		idx = len(pp.files)
+		pp.index[path] = idx
+		pp.files = append(pp.files, Source{Name: path, Text: string(data)})
+		lx := pp.newLexer(string(data))
+		lx.SetFile(idx)
+		toks, _ := lx.LexAll()
+		pp.tokens[idx] = toks
+		// an included file's lexical errors are reported once, however
+		// often it is included
+		pp.diags = append(pp.diags, lx.Diagnostics()...)
+	}
+	pp.open = append(pp.open, path)
+	toks := pp.expand(pp.tokens[idx], idx)
+	pp.open = pp.open[:len(pp.open)-1]
+	return toks
+}
+
+// find reads the included file name, trying dir (the including file's
+// directory) before the include path. It returns the cleaned path it read.
+func (pp *Preprocessor) find(name, dir string) (string, []byte, error) {
+	if filepath.IsAbs(name) {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			return "", nil, fmt.Errorf("cannot read included file: %v", err)
+		}
+		return filepath.Clean(name), data, nil
+	}
+	for _, d := range append([]string{dir}, pp.includePath...) {
+		path := filepath.Clean(filepath.Join(d, name))
+		data, err := os.ReadFile(path)
+		if err == nil {
+			return path, data, nil
+		}
+		if !os.IsNotExist(err) {
+			return "", nil, fmt.Errorf("cannot read included file: %v", err)
+		}
+	}
+	return "", nil, fmt.Errorf("included file %q not found", name)
+}
@@ -0,0 +1,41 @@
+package tokenizer
+
+// Progress is how far a LexAll has come, as passed to a progress hook.
+type Progress struct {
+	Bytes  int // of the input lexed so far
+	Total  int // bytes of the input
+	Tokens int // lexed so far
+}
+
+// progressEvery is how many bytes of input the lexer reads between calls to
+// its progress hook.
+const progressEvery = 256 << 10
+
+// SetProgress makes LexAll call fn every few hundred kilobytes of input,
+// and once more when it is done, with Bytes equal to Total. fn runs on the
+// lexing goroutine and should return quickly.
+func (lx *Lexer) SetProgress(fn func(Progress)) {
+	lx.progress = fn
+}
+
+// WithProgress sets a progress hook, as SetProgress does.
+func WithProgress(fn func(Progress)) Option {
+	return func(lx *Lexer) error {
+		lx.SetProgress(fn)
+		return nil
+	}
+}
+
+// reportProgress calls the progress hook when lexing has passed the next
+// report, or when done is set.
+func (lx *Lexer) reportProgress(done bool) {
+	if lx.progress == nil || !done && lx.i < lx.progressAt {
+		return
+	}
+	lx.progressAt = lx.i + progressEvery
+	p := Progress{Bytes: lx.i, Total: lx.length, Tokens: len(lx.tokens)}
+	if done {
+		p.Bytes = p.Total
+	}
+	lx.progress(p)
+}
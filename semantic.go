@@ -0,0 +1,115 @@
+package tokenizer
+
+import (
+	"strings"
+)
+
+// SemanticLegend describes how tokens are reported as LSP semantic tokens.
+// TokenTypes and TokenModifiers are the legend announced to the client;
+// Types maps a TokenType (e.g. "KW_IF") or a TokenCategory (e.g. "keyword")
+// to one of TokenTypes. Tokens that map to nothing are left out.
+type SemanticLegend struct {
+	TokenTypes     []string          `json:"tokenTypes"`
+	TokenModifiers []string          `json:"tokenModifiers"`
+	Types          map[string]string `json:"types"`
+}
+
+// DefaultSemanticLegend uses token type names from the LSP specification.
+var DefaultSemanticLegend = SemanticLegend{
+	TokenTypes:     []string{"keyword", "type", "variable", "string", "number", "operator", "comment"},
+	TokenModifiers: []string{},
+	Types: map[string]string{
+		"keyword":  "keyword",
+		"type":     "type",
+		"ident":    "variable",
+		"operator": "operator",
+		"comment":  "comment",
+		"INT_LIT":  "number", "FLOAT_LIT": "number",
+		"STRING_LIT": "string", "CHAR_LIT": "string", "STRING_SEGMENT": "string",
+	},
+}
+
+// index returns the legend index for tt, or -1 if tt is not reported.
+func (lg SemanticLegend) index(tt TokenType) int {
+	name, ok := lg.Types[string(tt)]
+	if !ok {
+		name, ok = lg.Types[TokenCategory(tt)]
+	}
+	if !ok {
+		return -1
+	}
+	for i, n := range lg.TokenTypes {
+		if n == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// utf16Len returns the length of s in UTF-16 code units.
+func utf16Len(s string) int {
+	n := 0
+	for _, r := range s {
+		if r >= 0x10000 {
+			n += 2
+		} else {
+			n++
+		}
+	}
+	return n
+}
+
+// EncodeSemanticTokens converts toks into the delta-encoded data array of an
+// LSP SemanticTokens response. Positions are zero-based with characters
+// counted in UTF-16 code units, and tokens spanning several lines are split
+// into one entry per line. toks must be ordered by offset.
+func EncodeSemanticTokens(src string, toks []Token, legend SemanticLegend) []uint32 {
+	data := []uint32{}
+	prevLine, prevChar := 0, 0
+	emit := func(ln, ch, length, typ int) {
+		if length == 0 {
+			return
+		}
+		dl, dc := ln-prevLine, ch
+		if dl == 0 {
+			dc = ch - prevChar
+		}
+		data = append(data, uint32(dl), uint32(dc), uint32(length), uint32(typ), 0)
+		prevLine, prevChar = ln, ch
+	}
+	// the line and UTF-16 character of byte offset pos, carried forward
+	// from token to token
+	line, char, pos := 0, 0, 0
+	// advance moves pos to end, calling seg, if set, with each piece of the
+	// text on one line before moving over it
+	advance := func(end int, seg func(from, to int)) {
+		for pos < end {
+			to := end
+			if nl := strings.IndexAny(src[pos:end], "\r\n"); nl >= 0 {
+				to = pos + nl
+			}
+			if seg != nil {
+				seg(pos, to)
+			}
+			char += utf16Len(src[pos:to])
+			if pos = to; to == end {
+				return
+			}
+			if src[to] == '\r' || to == 0 || src[to-1] != '\r' { // \r\n is one break
+				line++
+			}
+			char, pos = 0, to+1
+		}
+	}
+	for _, t := range toks {
+		typ := legend.index(t.Type)
+		if typ < 0 || t.Offset < pos || t.End > len(src) {
+			continue
+		}
+		advance(t.Offset, nil)
+		advance(t.End, func(from, to int) {
+			emit(line, char, utf16Len(src[from:to]), typ)
+		})
+	}
+	return data
+}
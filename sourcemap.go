@@ -0,0 +1,77 @@
+package tokenizer
+
+import (
+	"encoding/json"
+	"os"
+	"sort"
+	"unicode/utf8"
+)
+
+// SourceMapping ties one token, by its index in the token stream, to its
+// place in the original source.
+type SourceMapping struct {
+	Token  int `json:"token"`
+	Offset int `json:"offset"`
+	End    int `json:"end"`
+	Line   int `json:"line"`
+	Col    int `json:"col"`
+}
+
+// SourceMap records where every token of a file came from, so tools that
+// generate code from the token stream can map positions back. It also
+// answers offset → line/column queries for any byte of the source.
+type SourceMap struct {
+	Source   string          `json:"source"`
+	Mappings []SourceMapping `json:"mappings"`
+
+	src        string
+	lineStarts []int // byte offset of the first byte of each line
+}
+
+// NewSourceMap builds the map for toks, which must have been lexed from src.
+// name is recorded as the source file name.
+func NewSourceMap(name, src string, toks []Token) *SourceMap {
+	sm := &SourceMap{Source: name, Mappings: make([]SourceMapping, len(toks)), src: src, lineStarts: []int{0}}
+	for i, t := range toks {
+		sm.Mappings[i] = SourceMapping{Token: i, Offset: t.Offset, End: t.End, Line: t.Line, Col: t.Column}
+	}
+	for i := 0; i < len(src); i++ {
+		if src[i] == '\n' || src[i] == '\r' && (i+1 == len(src) || src[i+1] != '\n') {
+			sm.lineStarts = append(sm.lineStarts, i+1)
+		}
+	}
+	return sm
+}
+
+// PositionFor returns the 1-based line and column (in runes, as the lexer
+// counts them) of byte offset off. Offsets are clamped to the source.
+func (sm *SourceMap) PositionFor(off int) Pos {
+	if off < 0 {
+		off = 0
+	}
+	if off > len(sm.src) {
+		off = len(sm.src)
+	}
+	line := sort.Search(len(sm.lineStarts), func(i int) bool { return sm.lineStarts[i] > off }) - 1
+	start := sm.lineStarts[line]
+	return Pos{Line: line + 1, Col: utf8.RuneCountInString(sm.src[start:off]) + 1}
+}
+
+// TokenAt returns the index of the token covering byte offset off, or -1 if
+// off falls in whitespace or a comment.
+func (sm *SourceMap) TokenAt(off int) int {
+	i := sort.Search(len(sm.Mappings), func(i int) bool { return sm.Mappings[i].End > off })
+	if i < len(sm.Mappings) && sm.Mappings[i].Offset <= off {
+		return i
+	}
+	return -1
+}
+
+// WriteFile stores the map as JSON at path.
+func (sm *SourceMap) WriteFile(path string) error {
+	b, err := json.MarshalIndent(sm, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(b, '\n'), 0644)
+}
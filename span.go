@@ -0,0 +1,57 @@
+package tokenizer
+
+import (
+	"sync"
+)
+
+// SpanToken is the compact form of a Token used by LexSpans. Instead of a
+// copied lexeme it records where the token lies in the source, and it
+// carries no literal values, so producing one never allocates. The fields
+// are ints rather than something narrower so that mapped inputs beyond
+// 2 GiB keep exact positions.
+type SpanToken struct {
+	Type   TokenType
+	Offset int // byte offset of the first byte
+	Len    int // length in bytes
+	Line   int
+	Column int
+}
+
+// Lexeme returns the token's text, sliced from the source it was lexed from.
+func (t SpanToken) Lexeme(src string) string {
+	return src[t.Offset : t.Offset+t.Len]
+}
+
+// SpanBuffer holds the result of LexSpans. Its slices are reused between
+// calls once Release has been called.
+type SpanBuffer struct {
+	Tokens      []SpanToken
+	Diagnostics []Diagnostic
+	lx          Lexer
+}
+
+var spanPool = sync.Pool{New: func() interface{} { return new(SpanBuffer) }}
+
+// LexSpans tokenizes src into span tokens using a pooled buffer. Call
+// Release once the tokens are no longer needed; in steady state this makes
+// lexing allocation-free apart from building diagnostics for bad input.
+func LexSpans(src string) *SpanBuffer {
+	b := spanPool.Get().(*SpanBuffer)
+	b.lx = Lexer{
+		src: src, length: len(src),
+		line: 1, col: 1,
+		spanMode: true,
+		spans:    b.Tokens[:0],
+		diags:    b.Diagnostics[:0],
+	}
+	for b.lx.nextToken() {
+	}
+	b.Tokens, b.Diagnostics = b.lx.spans, b.lx.diags
+	return b
+}
+
+// Release returns the buffer to the pool. b must not be used afterwards.
+func (b *SpanBuffer) Release() {
+	b.lx = Lexer{}
+	spanPool.Put(b)
+}
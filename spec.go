@@ -0,0 +1,393 @@
+package tokenizer
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// tokenSpecJSON is the declarative token spec the keyword tables and the
+// operator DFA in tokens_gen.go are generated from.
+//
+//go:embed tokenspec.json
+var tokenSpecJSON []byte
+
+// TokenSpec returns tokenspec.json, the spec of the built-in language, for
+// documentation and as the start of a spec of a dialect.
+func TokenSpec() []byte {
+	return bytes.Clone(tokenSpecJSON)
+}
+
+// LexSpec describes the lexical structure of a small language. The built-in
+// language is described by tokenspec.json; a spec loaded at runtime with
+// SetSpec makes the lexer tokenize another language instead. Token types
+// are free-form names, so a spec may map "begin" to "KW_BEGIN".
+type LexSpec struct {
+	Name                    string            `json:"name"`
+	Keywords                map[string]string `json:"keywords"`
+	CaseInsensitiveKeywords bool              `json:"caseInsensitiveKeywords"`
+	TypeNames               []string          `json:"typeNames"`
+	Operators               map[string]string `json:"operators"`  // ASCII only; longest match wins
+	IdentChars              string            `json:"identChars"` // besides letters, digits and '_'
+	LineComments            []string          `json:"lineComments"`
+	BlockComments           [][2]string       `json:"blockComments"` // open, close
+	NestedComments          bool              `json:"nestedComments"`
+	Strings                 []StringSpec      `json:"strings"`
+	Numbers                 NumberSpec        `json:"numbers"`
+}
+
+// StringSpec is one kind of string literal. Its value is the text between
+// the delimiters; escapes are skipped over but not decoded.
+type StringSpec struct {
+	Open      string `json:"open"`
+	Close     string `json:"close"`
+	Escapes   bool   `json:"escapes"`   // a backslash escapes the next character
+	Multiline bool   `json:"multiline"` // may span lines
+	Type      string `json:"type"`      // token type, STRING_LIT by default
+}
+
+// NumberSpec selects the number syntax. Decimal integers are always
+// recognized.
+type NumberSpec struct {
+	Hex         bool `json:"hex"`    // 0x1F
+	Binary      bool `json:"binary"` // 0b101
+	Octal       bool `json:"octal"`  // 0o17
+	Float       bool `json:"float"`  // 1.5, 2e10
+	Underscores bool `json:"underscores"`
+}
+
+// compiledSpec is a LexSpec prepared for lexing.
+type compiledSpec struct {
+	LexSpec
+	keywords  map[string]TokenType
+	typeNames map[string]bool
+	opNext    [][128]int
+	opAccept  []TokenType
+}
+
+// LoadLexSpec reads a spec file: JSON, or YAML when the name ends in .yaml
+// or .yml. Fields the spec format does not have are errors rather than
+// silently ignored, so a misspelled "lineComment" is caught.
+func LoadLexSpec(path string) (*LexSpec, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read spec error: %w", err)
+	}
+	if ext := strings.ToLower(filepath.Ext(path)); ext == ".yaml" || ext == ".yml" {
+		if data, err = yamlToJSON(data); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	sp, err := decodeLexSpec(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return sp, nil
+}
+
+// decodeLexSpec decodes a JSON spec, rejecting unknown fields and anything
+// after the spec object.
+func decodeLexSpec(data []byte) (*LexSpec, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	var sp LexSpec
+	if err := dec.Decode(&sp); err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, errors.New("unexpected data after the spec")
+	}
+	return &sp, nil
+}
+
+func (sp *LexSpec) compile() (*compiledSpec, error) {
+	cs := &compiledSpec{LexSpec: *sp, keywords: map[string]TokenType{}, typeNames: map[string]bool{}}
+	for w, t := range sp.Keywords {
+		if sp.CaseInsensitiveKeywords {
+			w = strings.ToLower(w)
+		}
+		cs.keywords[w] = TokenType(t)
+	}
+	for _, n := range sp.TypeNames {
+		cs.typeNames[n] = true
+	}
+	// the operators form a trie, which is already a DFA; state 0 is the start
+	cs.opNext, cs.opAccept = make([][128]int, 1), make([]TokenType, 1)
+	for op, t := range sp.Operators {
+		s := 0
+		for i := 0; i < len(op); i++ {
+			if op[i] >= utf8.RuneSelf {
+				return nil, fmt.Errorf("spec: operator %q is not ASCII", op)
+			}
+			if cs.opNext[s][op[i]] == 0 {
+				cs.opNext[s][op[i]] = len(cs.opNext)
+				cs.opNext = append(cs.opNext, [128]int{})
+				cs.opAccept = append(cs.opAccept, "")
+			}
+			s = cs.opNext[s][op[i]]
+		}
+		if s == 0 {
+			return nil, fmt.Errorf("spec: empty operator")
+		}
+		cs.opAccept[s] = TokenType(t)
+	}
+	for _, bc := range sp.BlockComments {
+		if bc[0] == "" || bc[1] == "" {
+			return nil, fmt.Errorf("spec: block comment needs an open and a close delimiter")
+		}
+	}
+	for i, st := range sp.Strings {
+		if st.Open == "" || st.Close == "" {
+			return nil, fmt.Errorf("spec: string %d needs an open and a close delimiter", i+1)
+		}
+		if st.Type == "" {
+			cs.Strings[i].Type = string(STRING_LIT)
+		}
+	}
+	// try longer delimiters first, so """ wins over "
+	cs.Strings = append([]StringSpec(nil), cs.Strings...)
+	sort.SliceStable(cs.Strings, func(i, j int) bool { return len(cs.Strings[i].Open) > len(cs.Strings[j].Open) })
+	return cs, nil
+}
+
+// SetSpec makes the lexer tokenize the language described by sp instead of
+// the built-in one. Directives, annotations, interpolation and escape
+// decoding are features of the built-in language and are not available.
+func (lx *Lexer) SetSpec(sp *LexSpec) error {
+	cs, err := sp.compile()
+	if err != nil {
+		return err
+	}
+	lx.spec = cs
+	return nil
+}
+
+// hasPrefix reports whether the input continues with s at the cursor.
+func (lx *Lexer) hasPrefix(s string) bool {
+	return strings.HasPrefix(lx.src[lx.i:], s)
+}
+
+// skip advances over the next n bytes.
+func (lx *Lexer) skip(n int) {
+	for end := lx.i + n; lx.i < end && lx.i < lx.length; {
+		lx.advance()
+	}
+}
+
+// skipSpecTrivia skips whitespace and the spec's comments.
+func (lx *Lexer) skipSpecTrivia() {
+	sp := lx.spec
+outer:
+	for {
+		ch := lx.peek(0)
+		if ch == ' ' || ch == '\t' || ch == '\r' || ch == '\n' {
+			lx.advance()
+			continue
+		}
+		if ch == '\\' && lx.continuation && lx.skipContinuation() {
+			continue
+		}
+		l, c, start := lx.line, lx.col, lx.i
+		// block comments first, so --[[ is not taken for a -- line comment
+		for _, bc := range sp.BlockComments {
+			if !lx.hasPrefix(bc[0]) {
+				continue
+			}
+			lx.skip(len(bc[0]))
+			for depth := 1; depth > 0; {
+				switch {
+				case lx.peek(0) == eof:
+					lx.errorFrom(l, c, start, ErrUnterminatedComment, "unterminated block comment")
+					lx.addComment(lx.src[start:lx.i], l, c, start)
+					return
+				case lx.hasPrefix(bc[1]):
+					lx.skip(len(bc[1]))
+					depth--
+				case sp.NestedComments && lx.hasPrefix(bc[0]):
+					lx.skip(len(bc[0]))
+					depth++
+				default:
+					lx.advance()
+				}
+			}
+			lx.addComment(lx.src[start:lx.i], l, c, start)
+			continue outer
+		}
+		for _, lc := range sp.LineComments {
+			if lc != "" && lx.hasPrefix(lc) {
+				for !isLineEnd(lx.peek(0)) {
+					lx.advance()
+				}
+				lx.addComment(lx.src[start:lx.i], l, c, start)
+				continue outer
+			}
+		}
+		return
+	}
+}
+
+func (lx *Lexer) isSpecIdentStart(r rune) bool {
+	return lx.isIdentStart(r) || r != eof && strings.ContainsRune(lx.spec.IdentChars, r)
+}
+
+func (lx *Lexer) isSpecIdentPart(r rune) bool {
+	return lx.isSpecIdentStart(r) || unicode.IsDigit(r) || unicode.In(r, unicode.Mn, unicode.Mc)
+}
+
+// nextSpecToken is nextToken for a language loaded with SetSpec.
+func (lx *Lexer) nextSpecToken() bool {
+	sp := lx.spec
+	lx.skipSpecTrivia()
+	ch := lx.peek(0)
+	if ch == eof {
+		return false
+	}
+	l, c := lx.line, lx.col
+	lx.start = lx.i
+
+	switch {
+	case lx.isSpecIdentStart(ch):
+		for lx.isSpecIdentPart(lx.peek(0)) {
+			lx.advance()
+		}
+		lex := lx.src[lx.start:lx.i]
+		key := lex
+		if sp.CaseInsensitiveKeywords {
+			key = strings.ToLower(lex)
+		}
+		switch t, ok := sp.keywords[key]; {
+		case ok:
+			lx.add(t, lex, l, c, nil, nil)
+		case sp.typeNames[lex]:
+			lx.add(TYPE_NAME, lex, l, c, nil, nil)
+		default:
+			lx.add(IDENT, lex, l, c, nil, nil)
+			lx.normalizeName()
+		}
+		return true
+	case unicode.IsDigit(ch):
+		lx.scanSpecNumber(l, c)
+		return true
+	}
+	for _, st := range sp.Strings {
+		if lx.hasPrefix(st.Open) {
+			lx.scanSpecString(st, l, c)
+			return true
+		}
+	}
+
+	state, tt, end := 0, TokenType(""), 0
+	for i := lx.i; i < lx.length && lx.src[i] < utf8.RuneSelf; i++ {
+		if state = sp.opNext[state][lx.src[i]]; state == 0 {
+			break
+		}
+		if sp.opAccept[state] != "" {
+			tt, end = sp.opAccept[state], i+1
+		}
+	}
+	if tt != "" {
+		lx.skip(end - lx.i)
+		lx.add(tt, lx.src[lx.start:lx.i], l, c, nil, nil)
+		return true
+	}
+	if _, w := utf8.DecodeRuneInString(lx.src[lx.i:]); ch == utf8.RuneError && w == 1 || IsBidiControl(ch) {
+		lx.advance() // reports the encoding error or the control itself
+		return true
+	}
+	lx.errorAt(l, c, ErrInvalidCharacter, fmt.Sprintf("invalid character %q", ch))
+	lx.advance()
+	return true
+}
+
+func (lx *Lexer) scanSpecNumber(l, c int) {
+	ns := lx.spec.Numbers
+	digits := func(ok func(rune) bool) int {
+		n := 0
+		for r := lx.peek(0); ok(r) || ns.Underscores && r == '_'; r = lx.peek(0) {
+			lx.advance()
+			n++
+		}
+		return n
+	}
+	isDec := func(r rune) bool { return r >= '0' && r <= '9' }
+	if lx.peek(0) == '0' {
+		var ok func(rune) bool
+		var name string
+		switch p := lx.peek(1); {
+		case ns.Hex && (p == 'x' || p == 'X'):
+			ok, name = isHexDigit, "hex"
+		case ns.Binary && (p == 'b' || p == 'B'):
+			ok, name = func(r rune) bool { return r == '0' || r == '1' }, "binary"
+		case ns.Octal && (p == 'o' || p == 'O'):
+			ok, name = func(r rune) bool { return r >= '0' && r <= '7' }, "octal"
+		}
+		if ok != nil {
+			lx.skip(2)
+			if digits(ok) == 0 || !validUnderscores(lx.src[lx.start+2:lx.i]) {
+				lx.errorAt(l, c, ErrInvalidNumber, "invalid "+name+" literal")
+				return
+			}
+			lx.addInt(lx.src[lx.start:lx.i], l, c)
+			return
+		}
+	}
+	digits(isDec)
+	float := false
+	if ns.Float && lx.peek(0) == '.' && isDec(lx.peek(1)) {
+		float = true
+		lx.advance()
+		digits(isDec)
+	}
+	if ns.Float && (lx.peek(0) == 'e' || lx.peek(0) == 'E') {
+		float = true
+		lx.advance()
+		if lx.peek(0) == '+' || lx.peek(0) == '-' {
+			lx.advance()
+		}
+		if digits(isDec) == 0 {
+			lx.errorAt(l, c, ErrInvalidNumber, "invalid float exponent")
+			return
+		}
+	}
+	lex := lx.src[lx.start:lx.i]
+	if ns.Underscores && strings.Contains(lex, "_") && !validUnderscores(lex) {
+		lx.errorAt(l, c, ErrInvalidNumber, "illegal underscore placement in number")
+		return
+	}
+	if float {
+		lx.add(FLOAT_LIT, lex, l, c, nil, nil)
+		return
+	}
+	lx.addInt(lex, l, c)
+}
+
+func (lx *Lexer) scanSpecString(st StringSpec, l, c int) {
+	lx.skip(len(st.Open))
+	bodyStart := lx.i
+	for {
+		ch := lx.peek(0)
+		if ch == eof || (ch == '\n' || ch == '\r') && !st.Multiline {
+			lx.errorAt(l, c, ErrUnterminatedString, "unterminated string literal")
+			return
+		}
+		if lx.hasPrefix(st.Close) {
+			body := lx.src[bodyStart:lx.i]
+			lx.skip(len(st.Close))
+			lx.addValue(TokenType(st.Type), lx.src[lx.start:lx.i], l, c, body)
+			return
+		}
+		lx.advance()
+		if st.Escapes && ch == '\\' && lx.peek(0) != eof {
+			lx.advance()
+		}
+	}
+}
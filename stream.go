@@ -0,0 +1,97 @@
+package tokenizer
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// EOF is the pseudo token parsers see past the end of the stream.
+const EOF TokenType = "EOF"
+
+// TokenStream is a cursor over a token slice for hand-written parsers: it
+// peeks ahead, consumes, and can go back to a mark to try another parse.
+// Past the last token it returns an EOF token placed after it.
+type TokenStream struct {
+	toks []Token
+	pos  int
+}
+
+// NewTokenStream returns a stream at the first of toks.
+func NewTokenStream(toks []Token) *TokenStream {
+	return &TokenStream{toks: toks}
+}
+
+// A StreamMark is a position of a TokenStream, for Rewind.
+type StreamMark int
+
+// Mark returns the current position.
+func (s *TokenStream) Mark() StreamMark { return StreamMark(s.pos) }
+
+// Rewind goes back, or forward, to a position returned by Mark.
+func (s *TokenStream) Rewind(m StreamMark) { s.pos = int(m) }
+
+// Peek returns the token n ahead of the current one, which is Peek(0),
+// without consuming anything.
+func (s *TokenStream) Peek(n int) Token {
+	if s.pos+n < len(s.toks) {
+		return s.toks[s.pos+n]
+	}
+	return EOFAfter(s.toks)
+}
+
+// Next consumes the current token and returns it.
+func (s *TokenStream) Next() Token {
+	t := s.Peek(0)
+	if s.pos < len(s.toks) {
+		s.pos++
+	}
+	return t
+}
+
+// At reports whether the current token has type tt.
+func (s *TokenStream) At(tt TokenType) bool { return s.Peek(0).Type == tt }
+
+// Done reports whether every token has been consumed.
+func (s *TokenStream) Done() bool { return s.pos >= len(s.toks) }
+
+// Accept consumes the current token if it has type tt.
+func (s *TokenStream) Accept(tt TokenType) bool {
+	if s.At(tt) {
+		s.pos++
+		return true
+	}
+	return false
+}
+
+// Expect consumes the current token if it has type tt, and otherwise
+// returns it, unconsumed, with an error saying what was expected.
+func (s *TokenStream) Expect(tt TokenType) (Token, error) {
+	t := s.Peek(0)
+	if t.Type != tt {
+		want := string(tt)
+		if sp, ok := spellings[tt]; ok {
+			want = strconv.Quote(sp)
+		}
+		return t, fmt.Errorf("%d:%d: expected %s, found %s", t.Line, t.Column, want, Describe(t))
+	}
+	return s.Next(), nil
+}
+
+// EOFAfter returns the EOF token that follows toks, placed right after the
+// last of them.
+func EOFAfter(toks []Token) Token {
+	t := Token{Type: EOF, Line: 1, Column: 1}
+	if len(toks) > 0 {
+		last := toks[len(toks)-1]
+		t.Line, t.Column, t.Offset, t.End = EndLine(last), last.Column+len([]rune(last.Lexeme)), last.End, last.End
+	}
+	return t
+}
+
+// Describe names t in an error message: its lexeme, quoted, or end of file.
+func Describe(t Token) string {
+	if t.Type == EOF {
+		return "end of file"
+	}
+	return fmt.Sprintf("%q", t.Lexeme)
+}
@@ -0,0 +1,71 @@
+package tokenizer
+
+import (
+	"sort"
+)
+
+// Symbol is one distinct identifier of a symbol report.
+type Symbol struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+	File  string `json:"file,omitempty"` // where it first occurs
+	First Pos    `json:"first"`
+	// Decl is "def", "type", "var" or "cons" when an occurrence directly
+	// follows that keyword, which makes it a probable declaration.
+	Decl string `json:"decl,omitempty"`
+}
+
+// declKeywords maps the keywords that introduce a declared name to the kind
+// reported in Symbol.Decl.
+var declKeywords = map[TokenType]string{
+	KW_DEF:  "def",
+	KW_TYPE: "type",
+	KW_VAR:  "var",
+	KW_CONS: "cons",
+}
+
+// SymbolTable collects identifiers across one or more files.
+type SymbolTable struct {
+	byName map[string]*Symbol
+	order  []*Symbol // by first occurrence
+}
+
+func NewSymbolTable() *SymbolTable {
+	return &SymbolTable{byName: map[string]*Symbol{}}
+}
+
+// Add records the identifiers of one lexed file.
+func (st *SymbolTable) Add(file string, toks []Token) {
+	for i, t := range toks {
+		if t.Type != IDENT {
+			continue
+		}
+		name := NameOf(t)
+		sym := st.byName[name]
+		if sym == nil {
+			sym = &Symbol{Name: name, File: file, First: Pos{t.Line, t.Column}}
+			st.byName[name] = sym
+			st.order = append(st.order, sym)
+		}
+		sym.Count++
+		if i > 0 && sym.Decl == "" {
+			sym.Decl = declKeywords[toks[i-1].Type]
+		}
+	}
+}
+
+// Symbols returns the collected symbols ordered by name, or by descending
+// count when byCount is set.
+func (st *SymbolTable) Symbols(byCount bool) []Symbol {
+	out := make([]Symbol, len(st.order))
+	for i, s := range st.order {
+		out[i] = *s
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		if byCount && out[i].Count != out[j].Count {
+			return out[i].Count > out[j].Count
+		}
+		return out[i].Name < out[j].Name
+	})
+	return out
+}
@@ -0,0 +1,134 @@
+// Code generated by gen_tokens.go from tokenspec.json; DO NOT EDIT.
+
+package tokenizer
+
+// keywords maps each keyword, in lowercase, to its token type.
+var keywords = map[string]TokenType{
+	"break":     KW_BREAK,
+	"case":      KW_CASE,
+	"channel":   KW_CHANNEL,
+	"cons":      KW_CONS,
+	"continue":  KW_CONTINUE,
+	"def":       KW_DEF,
+	"dft":       KW_DFT,
+	"else":      KW_ELSE,
+	"fall":      KW_FALL,
+	"fr":        KW_FR,
+	"if":        KW_IF,
+	"imp":       KW_IMP,
+	"interface": KW_INTERFACE,
+	"j":         KW_J,
+	"joto":      KW_JOTO,
+	"later":     KW_LATER,
+	"mapping":   KW_MAPPING,
+	"panic":     KW_PANIC,
+	"pkg":       KW_PKG,
+	"range":     KW_RANGE,
+	"recover":   KW_RECOVER,
+	"recovery":  KW_RECOVER,
+	"ret":       KW_RET,
+	"select":    KW_SELECT,
+	"struct":    KW_STRUCT,
+	"switch":    KW_SWITCH,
+	"type":      KW_TYPE,
+	"var":       KW_VAR,
+}
+
+// typeNames holds the predeclared type names, lexed as TYPE_NAME.
+var typeNames = map[string]struct{}{
+	"i8":     {},
+	"i16":    {},
+	"i32":    {},
+	"i64":    {},
+	"u8":     {},
+	"u16":    {},
+	"u32":    {},
+	"u64":    {},
+	"f32":    {},
+	"f64":    {},
+	"bool":   {},
+	"string": {},
+}
+
+// opStates is the number of states of the operator DFA. State 0 is the
+// start state, so 0 in opNext means there is no transition.
+const opStates = 52
+
+// opNext is the transition table of the operator DFA, indexed by state
+// and ASCII byte.
+var opNext = [opStates][128]uint8{
+	0:  {'!': 1, '%': 2, '&': 3, '(': 4, ')': 5, '*': 6, '+': 7, ',': 8, '-': 9, '.': 10, '/': 11, ':': 12, ';': 13, '<': 14, '=': 15, '>': 16, '?': 17, '[': 18, ']': 19, '^': 20, '{': 21, '|': 22, '}': 23},
+	1:  {'=': 24},
+	2:  {'=': 25},
+	3:  {'&': 26, '=': 27},
+	6:  {'*': 28, '=': 29},
+	7:  {'+': 30, '=': 31},
+	9:  {'-': 32, '=': 33, '>': 34},
+	10: {'.': 35},
+	11: {'=': 36},
+	12: {'=': 37},
+	14: {'-': 38, '<': 39, '=': 40},
+	15: {'=': 41},
+	16: {'=': 42, '>': 43},
+	20: {'=': 44},
+	22: {'=': 45, '>': 46, '|': 47},
+	28: {'=': 48},
+	35: {'.': 49},
+	39: {'=': 50},
+	43: {'=': 51},
+}
+
+// opAccept gives the token type recognized in each accepting state.
+var opAccept = [opStates]TokenType{
+	1:  BANG,
+	2:  PERCENT,
+	3:  BAND,
+	4:  LPAREN,
+	5:  RPAREN,
+	6:  STAR,
+	7:  PLUS,
+	8:  COMMA,
+	9:  MINUS,
+	10: DOT,
+	11: SLASH,
+	12: COLON,
+	13: SEMI,
+	14: LT,
+	15: ASSIGN,
+	16: GT,
+	17: QUESTION,
+	18: LBRACK,
+	19: RBRACK,
+	20: BXOR,
+	21: LBRACE,
+	22: BOR,
+	23: RBRACE,
+	24: NE,
+	25: MODEQ,
+	26: ANDAND,
+	27: ANDEQ,
+	28: POW,
+	29: MULEQ,
+	30: INC,
+	31: ADDEQ,
+	32: DEC,
+	33: SUBEQ,
+	34: ARROW,
+	35: RANGE_OP,
+	36: DIVEQ,
+	37: DECL,
+	38: CH_SEND,
+	39: SHL,
+	40: LE,
+	41: EQ,
+	42: GE,
+	43: SHR,
+	44: XOREQ,
+	45: OREQ,
+	46: PIPE_FORWARD,
+	47: OROR,
+	48: POWEQ,
+	49: ELLIPSIS,
+	50: SHLEQ,
+	51: SHREQ,
+}
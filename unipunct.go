@@ -0,0 +1,61 @@
+package tokenizer
+
+import (
+	"strings"
+)
+
+// asciiPunct maps the typographic punctuation word processors substitute
+// for ASCII, such as curly quotes and dashes, to the ASCII character it
+// stands for.
+var asciiPunct = map[rune]byte{
+	'“': '"', '”': '"', '„': '"', '‟': '"', '″': '"',
+	'‘': '\'', '’': '\'', '‚': '\'', '‛': '\'', '′': '\'',
+	'–': '-', '—': '-', '−': '-',
+	' ': ' ',
+}
+
+// A PunctFix is a character FixUnicodePunct replaced, at its position in the
+// original source.
+type PunctFix struct {
+	Line   int  `json:"line"`
+	Col    int  `json:"col"`
+	Offset int  `json:"offset"`
+	From   rune `json:"from"`
+	To     byte `json:"to"`
+}
+
+// FixUnicodePunct replaces the smart quotes, dashes and other typographic
+// punctuation of src that the lexer rejects as invalid characters with
+// their ASCII equivalents, and returns the result with the replacements in
+// source order. Such characters inside strings and comments are not errors
+// and are kept. opts configure the lexer as for NewLexer; every invalid
+// character is considered whatever errors they suppress or limit. Each
+// replacement is one character, so lines and character columns stay the
+// same, but byte offsets after it move back.
+func FixUnicodePunct(src string, opts ...Option) (string, []PunctFix) {
+	lx := NewLexer(src, opts...)
+	lx.filter = ErrorFilter{}
+	lx.LexAll()
+	var fixes []PunctFix
+	for _, d := range lx.Diagnostics() {
+		if d.Code != ErrInvalidCharacter {
+			continue
+		}
+		r := []rune(src[d.Offset:d.End])
+		if to, ok := asciiPunct[r[0]]; ok && len(r) == 1 {
+			fixes = append(fixes, PunctFix{Line: d.Line, Col: d.Col, Offset: d.Offset, From: r[0], To: to})
+		}
+	}
+	if len(fixes) == 0 {
+		return src, nil
+	}
+	var sb strings.Builder
+	last := 0
+	for _, f := range fixes {
+		sb.WriteString(src[last:f.Offset])
+		sb.WriteByte(f.To)
+		last = f.Offset + len(string(f.From))
+	}
+	sb.WriteString(src[last:])
+	return sb.String(), fixes
+}
@@ -0,0 +1,68 @@
+package tokenizer
+
+import (
+	"fmt"
+	"strings"
+)
+
+// WithTrivia returns toks and comments merged in source order, with the
+// text between them added as WHITESPACE tokens (blanks and line breaks) and
+// SKIPPED tokens (input the lexer rejected). The lexemes of the result
+// concatenate to src, so the stream is a lossless copy of it. Columns of
+// the added tokens count in unit.
+func WithTrivia(src string, toks, comments []Token, unit ColumnUnit) []Token {
+	all := MergeTrivia(toks, comments)
+	out := make([]Token, 0, 2*len(all)+1)
+	line, counted := 1, 0 // line holds src[counted:]
+	gap := func(from, to int) {
+		for from < to {
+			n := strings.IndexFunc(src[from:to], func(r rune) bool { return !strings.ContainsRune(" \t\r\n", r) })
+			tt := WHITESPACE
+			if n == 0 {
+				n = strings.IndexAny(src[from:to], " \t\r\n")
+				tt = SKIPPED
+			}
+			if n < 0 {
+				n = to - from
+			}
+			line += CountLineBreaks(src[counted:from])
+			counted = from
+			c := ColumnsOf(src, LineStartOf(src, from), from).in(unit)
+			out = append(out, Token{Type: tt, Lexeme: src[from : from+n], Line: line, Column: c, Offset: from, End: from + n})
+			from += n
+		}
+	}
+	prev := 0
+	for _, t := range all {
+		if t.Offset > prev {
+			gap(prev, t.Offset)
+		}
+		out = append(out, t)
+		if t.End > prev {
+			prev = t.End
+		}
+	}
+	gap(prev, len(src))
+	return out
+}
+
+// Unlex rebuilds a source from a lossless token stream such as WithTrivia
+// returns: the lexemes in order, each of which must start where the one
+// before it ended and fill its span exactly.
+func Unlex(toks []Token) (string, error) {
+	var b strings.Builder
+	pos := 0
+	for _, t := range toks {
+		switch {
+		case t.Offset > pos:
+			return "", fmt.Errorf("%d:%d: bytes %d..%d are not covered by any token (lex with --trivia for a lossless stream)", t.Line, t.Column, pos, t.Offset)
+		case t.Offset < pos:
+			return "", fmt.Errorf("%d:%d: %s %q overlaps the previous token", t.Line, t.Column, t.Type, t.Lexeme)
+		case len(t.Lexeme) != t.End-t.Offset:
+			return "", fmt.Errorf("%d:%d: %s %q does not fill its span %d..%d (it was rewritten, or held invalid UTF-8, which JSON cannot store)", t.Line, t.Column, t.Type, t.Lexeme, t.Offset, t.End)
+		}
+		b.WriteString(t.Lexeme)
+		pos = t.End
+	}
+	return b.String(), nil
+}
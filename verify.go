@@ -0,0 +1,97 @@
+package tokenizer
+
+import (
+	"fmt"
+	"strings"
+)
+
+// VerifyRoundTrip rebuilds src from the lexemes of toks and comments and
+// checks it byte-for-byte against the original. The text between two
+// tokens must be whitespace, a line continuation in joins (see
+// Lexer.Continuations) or lie inside a diagnostic's span (input the lexer
+// rejected); anything else means bytes were dropped or duplicated.
+func VerifyRoundTrip(src string, toks, comments []Token, diags []Diagnostic, joins [][2]int) error {
+	sm := NewSourceMap("", src, nil)
+	// covered reports whether src[from:to] is whitespace, line
+	// continuations and rejected input.
+	covered := func(from, to int) bool {
+		for from < to {
+			if strings.IndexByte(" \t\r\n", src[from]) >= 0 {
+				from++
+				continue
+			}
+			advanced := false
+			for _, j := range joins {
+				if j[0] <= from && j[1] > from {
+					from, advanced = j[1], true
+				}
+			}
+			for _, d := range diags {
+				if d.Offset <= from && d.End > from {
+					from, advanced = d.End, true
+				}
+			}
+			if !advanced {
+				return false
+			}
+		}
+		return true
+	}
+
+	var b strings.Builder
+	b.Grow(len(src))
+	prev := 0
+	for _, t := range MergeTrivia(toks, comments) {
+		if t.Offset < prev {
+			p := sm.PositionFor(t.Offset)
+			return fmt.Errorf("%d:%d: %s %q overlaps the previous token", p.Line, p.Col, t.Type, t.Lexeme)
+		}
+		gap := src[prev:t.Offset]
+		if strings.TrimSpace(gap) != "" && !covered(prev, t.Offset) {
+			p := sm.PositionFor(prev)
+			return fmt.Errorf("%d:%d: %q is not part of any token", p.Line, p.Col, gap)
+		}
+		b.WriteString(gap)
+		b.WriteString(t.Lexeme)
+		prev = t.End
+		if prev > len(src) {
+			p := sm.PositionFor(t.Offset)
+			return fmt.Errorf("%d:%d: %s %q extends past the end of the input", p.Line, p.Col, t.Type, t.Lexeme)
+		}
+	}
+	tail := src[prev:]
+	if strings.TrimSpace(tail) != "" && !covered(prev, len(src)) {
+		p := sm.PositionFor(prev)
+		return fmt.Errorf("%d:%d: %q is not part of any token", p.Line, p.Col, tail)
+	}
+	b.WriteString(tail)
+
+	rebuilt := b.String()
+	if rebuilt == src {
+		return nil
+	}
+	i := 0
+	for i < len(rebuilt) && i < len(src) && rebuilt[i] == src[i] {
+		i++
+	}
+	p := sm.PositionFor(i)
+	return fmt.Errorf("%d:%d: rebuilt source differs from the input (%d bytes rebuilt, %d in input)", p.Line, p.Col, len(rebuilt), len(src))
+}
+
+// VerifySpans checks that every token and comment is the slice of src its
+// Offset and End delimit: src[Offset:End] must equal its Lexeme. It reports
+// the first token that is not, which catches a scanner that builds a lexeme
+// other than the text it consumed.
+func VerifySpans(src string, toks, comments []Token) error {
+	sm := NewSourceMap("", src, nil)
+	for _, t := range MergeTrivia(toks, comments) {
+		p := sm.PositionFor(t.Offset)
+		switch {
+		case t.Offset < 0 || t.End < t.Offset || t.End > len(src):
+			return fmt.Errorf("%d:%d: %s %q has span %d..%d outside the input (%d bytes)", p.Line, p.Col, t.Type, t.Lexeme, t.Offset, t.End, len(src))
+		case src[t.Offset:t.End] != t.Lexeme:
+			return fmt.Errorf("%d:%d: %s lexeme %q differs from its span %d..%d, %q", p.Line, p.Col, t.Type, t.Lexeme, t.Offset, t.End, src[t.Offset:t.End])
+		}
+	}
+	return nil
+}
@@ -0,0 +1,286 @@
+package tokenizer
+
+import (
+	"container/heap"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// The kinds of vocabulary.
+const (
+	VocabLexical = "lexical" // one id per frequent lexeme
+	VocabBPE     = "bpe"     // byte-level subwords of the lexical pieces
+)
+
+// UnknownToken is the entry of id 0 of a lexical vocabulary, which stands
+// for every lexeme the vocabulary lacks.
+const UnknownToken = "<unk>"
+
+// Vocab maps source text to integer ids and back. A lexical vocabulary
+// lists its lexemes in Tokens, the most frequent first after UnknownToken;
+// its ids skip comments and whitespace. A BPE vocabulary is its Merges (see
+// BPE) and encodes the source losslessly.
+type Vocab struct {
+	Kind   string   `json:"kind"`
+	Size   int      `json:"size"`
+	Tokens []string `json:"tokens,omitempty"` // lexical
+	Merges [][2]int `json:"merges,omitempty"` // bpe
+
+	ids map[string]int // lexical: lexeme -> id
+	bpe *BPE
+}
+
+// LoadVocab reads a vocabulary written by `tokenizer train-vocab`.
+func LoadVocab(path string) (*Vocab, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read vocab error: %w", err)
+	}
+	var v Vocab
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if err := v.init(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &v, nil
+}
+
+// init builds the lookup tables of a decoded vocabulary.
+func (v *Vocab) init() error {
+	switch v.Kind {
+	case VocabLexical:
+		if len(v.Tokens) == 0 || v.Tokens[0] != UnknownToken {
+			return fmt.Errorf("vocab: a lexical vocabulary starts with %s", UnknownToken)
+		}
+		v.ids = make(map[string]int, len(v.Tokens))
+		for id, s := range v.Tokens {
+			v.ids[s] = id
+		}
+		v.Size = len(v.Tokens)
+	case VocabBPE:
+		b, err := NewBPE(v.Merges)
+		if err != nil {
+			return err
+		}
+		v.bpe, v.Size = b, b.Size()
+	default:
+		return fmt.Errorf("vocab: unknown kind %q (want %s or %s)", v.Kind, VocabLexical, VocabBPE)
+	}
+	return nil
+}
+
+// BPE returns the merges of a BPE vocabulary, or nil for a lexical one.
+func (v *Vocab) BPE() *BPE {
+	return v.bpe
+}
+
+// Encode returns the ids of src.
+func (v *Vocab) Encode(src string) []int {
+	if v.bpe != nil {
+		return v.bpe.Encode(src)
+	}
+	toks, _ := NewLexer(src).LexAll()
+	ids := make([]int, len(toks))
+	for i, t := range toks {
+		ids[i] = v.ids[t.Lexeme] // 0, UnknownToken, when missing
+	}
+	return ids
+}
+
+// Decode returns the text ids stand for: the source itself for a BPE
+// vocabulary, the lexemes separated by spaces for a lexical one.
+func (v *Vocab) Decode(ids []int) (string, error) {
+	if v.bpe != nil {
+		b, err := v.bpe.Decode(ids)
+		return string(b), err
+	}
+	parts := make([]string, len(ids))
+	for i, id := range ids {
+		if id < 0 || id >= len(v.Tokens) {
+			return "", fmt.Errorf("vocab: id %d at index %d is not in the vocabulary of %d", id, i, len(v.Tokens))
+		}
+		parts[i] = v.Tokens[id]
+	}
+	return strings.Join(parts, " "), nil
+}
+
+// VocabTrainer counts the pieces of a corpus, one source at a time, and
+// trains a vocabulary of a kind from them.
+type VocabTrainer struct {
+	kind   string
+	counts map[string]int
+}
+
+func NewVocabTrainer(kind string) (*VocabTrainer, error) {
+	if kind != VocabLexical && kind != VocabBPE {
+		return nil, fmt.Errorf("vocab: unknown kind %q (want %s or %s)", kind, VocabLexical, VocabBPE)
+	}
+	return &VocabTrainer{kind: kind, counts: map[string]int{}}, nil
+}
+
+// Add counts the lexemes of src, or for BPE all of its lexical pieces.
+func (vt *VocabTrainer) Add(src string) {
+	if vt.kind == VocabBPE {
+		for _, p := range bpePieces(src) {
+			vt.counts[p]++
+		}
+		return
+	}
+	toks, _ := NewLexer(src).LexAll()
+	for _, t := range toks {
+		vt.counts[t.Lexeme]++
+	}
+}
+
+// Train returns a vocabulary of at most size ids. A lexical one keeps the
+// size-1 most frequent lexemes; a BPE one learns merges until it has size
+// ids or no pair occurs twice.
+func (vt *VocabTrainer) Train(size int) (*Vocab, error) {
+	v := &Vocab{Kind: vt.kind}
+	if vt.kind == VocabBPE {
+		if size < 256 {
+			return nil, fmt.Errorf("vocab: a BPE vocabulary has at least the 256 bytes")
+		}
+		v.Merges = trainMerges(vt.counts, size-256)
+	} else {
+		if size < 1 {
+			return nil, fmt.Errorf("vocab: size must be at least 1")
+		}
+		lexemes := make([]string, 0, len(vt.counts))
+		for s := range vt.counts {
+			lexemes = append(lexemes, s)
+		}
+		sort.Slice(lexemes, func(i, j int) bool {
+			if ci, cj := vt.counts[lexemes[i]], vt.counts[lexemes[j]]; ci != cj {
+				return ci > cj
+			}
+			return lexemes[i] < lexemes[j]
+		})
+		if len(lexemes) > size-1 {
+			lexemes = lexemes[:size-1]
+		}
+		v.Tokens = append([]string{UnknownToken}, lexemes...)
+	}
+	if err := v.init(); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// trainMerges learns up to n merges from the pieces and their counts: each
+// time, the pair of adjacent ids seen most often (the lowest ids on a tie)
+// becomes a new id. Pair counts are updated only in the words holding the
+// merged pair, and a heap with stale entries finds the most frequent one.
+func trainMerges(counts map[string]int, n int) [][2]int {
+	type word struct {
+		ids   []int
+		count int
+	}
+	pieces := make([]string, 0, len(counts))
+	for s := range counts {
+		pieces = append(pieces, s)
+	}
+	sort.Strings(pieces)
+	words := make([]word, len(pieces))
+	pairs := map[[2]int]int{}
+	where := map[[2]int]map[int]bool{} // pair -> the words holding it
+	count := func(w int, delta int) {
+		ids := words[w].ids
+		for i := 0; i+1 < len(ids); i++ {
+			p := [2]int{ids[i], ids[i+1]}
+			pairs[p] += delta * words[w].count
+			if delta > 0 {
+				if where[p] == nil {
+					where[p] = map[int]bool{}
+				}
+				where[p][w] = true
+			}
+		}
+	}
+	for w, s := range pieces {
+		ids := make([]int, len(s))
+		for i := 0; i < len(s); i++ {
+			ids[i] = int(s[i])
+		}
+		words[w] = word{ids: ids, count: counts[s]}
+		count(w, 1)
+	}
+	h := &pairHeap{}
+	for p, c := range pairs {
+		h.items = append(h.items, pairCount{p, c})
+	}
+	heap.Init(h)
+
+	var merges [][2]int
+	for len(merges) < n && h.Len() > 0 {
+		top := heap.Pop(h).(pairCount)
+		if pairs[top.pair] != top.count {
+			continue // stale: the pair's count changed since it was pushed
+		}
+		if top.count < 2 {
+			break
+		}
+		id := 256 + len(merges)
+		merges = append(merges, top.pair)
+		changed := map[[2]int]bool{}
+		for w := range where[top.pair] {
+			old := words[w].ids
+			for i := 0; i+1 < len(old); i++ {
+				changed[[2]int{old[i], old[i+1]}] = true
+			}
+			count(w, -1)
+			ids := make([]int, 0, len(old))
+			for i := 0; i < len(old); i++ {
+				if i+1 < len(old) && old[i] == top.pair[0] && old[i+1] == top.pair[1] {
+					ids = append(ids, id)
+					i++
+					continue
+				}
+				ids = append(ids, old[i])
+			}
+			words[w].ids = ids
+			count(w, 1)
+			for i := 0; i+1 < len(ids); i++ {
+				changed[[2]int{ids[i], ids[i+1]}] = true
+			}
+		}
+		delete(where, top.pair)
+		for p := range changed {
+			if c := pairs[p]; c > 0 {
+				heap.Push(h, pairCount{p, c})
+			} else {
+				delete(pairs, p)
+			}
+		}
+	}
+	return merges
+}
+
+// pairCount is an entry of pairHeap.
+type pairCount struct {
+	pair  [2]int
+	count int
+}
+
+// pairHeap orders pairs by count, most frequent first, then by ids.
+type pairHeap struct{ items []pairCount }
+
+func (h *pairHeap) Len() int { return len(h.items) }
+func (h *pairHeap) Less(i, j int) bool {
+	a, b := h.items[i], h.items[j]
+	if a.count != b.count {
+		return a.count > b.count
+	}
+	return a.pair[0] < b.pair[0] || a.pair[0] == b.pair[0] && a.pair[1] < b.pair[1]
+}
+func (h *pairHeap) Swap(i, j int)      { h.items[i], h.items[j] = h.items[j], h.items[i] }
+func (h *pairHeap) Push(x interface{}) { h.items = append(h.items, x.(pairCount)) }
+func (h *pairHeap) Pop() interface{} {
+	x := h.items[len(h.items)-1]
+	h.items = h.items[:len(h.items)-1]
+	return x
+}
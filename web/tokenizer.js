@@ -0,0 +1,17 @@
+// Loads tokenizer.wasm in the browser and resolves to the tokenize
+// function it exports. Requires Go's wasm_exec.js to be loaded first.
+//
+//   const tokenize = await loadTokenizer("tokenizer.wasm");
+//   const { tokens, errors } = tokenize('pkg main\n');
+async function loadTokenizer(url = "tokenizer.wasm") {
+  const go = new Go();
+  const resp = fetch(url);
+  const { instance } = WebAssembly.instantiateStreaming
+    ? await WebAssembly.instantiateStreaming(resp, go.importObject)
+    : await WebAssembly.instantiate(await (await resp).arrayBuffer(), go.importObject);
+  go.run(instance); // not awaited: it settles only when the Go program exits
+  if (typeof globalThis.tokenize !== "function") {
+    throw new Error("tokenizer.wasm did not register tokenize");
+  }
+  return globalThis.tokenize;
+}
@@ -0,0 +1,450 @@
+package tokenizer
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// yamlToJSON converts the YAML subset lexer specs are written in to JSON:
+// block mappings and sequences nested by indentation, flow [..] and {..}
+// collections (which may span lines), plain, single- and double-quoted
+// scalars and # comments. Anchors, tags, block scalars (| and >) and
+// multi-document streams are not supported and are reported as errors.
+func yamlToJSON(data []byte) ([]byte, error) {
+	lines, err := yamlLines(string(data))
+	if err != nil {
+		return nil, err
+	}
+	p := &yamlParser{lines: lines}
+	var v any
+	if len(lines) > 0 {
+		if v, err = p.block(lines[0].indent); err != nil {
+			return nil, err
+		}
+		if p.i < len(lines) {
+			return nil, p.errorf("unexpected indentation")
+		}
+	}
+	return json.Marshal(v)
+}
+
+// yamlLine is a line of YAML without its indentation and comment.
+type yamlLine struct {
+	num    int // 1-based
+	indent int
+	text   string
+}
+
+// yamlLines splits src into its non-blank lines, dropping comments and
+// joining the lines of a flow collection that spans several.
+func yamlLines(src string) ([]yamlLine, error) {
+	var lines []yamlLine
+	var err error
+	depth := 0 // open flow brackets at the end of the line before
+	for i, raw := range SourceLines(src) {
+		num := i + 1
+		if i == 0 {
+			raw = strings.TrimPrefix(raw, "\ufeff")
+		}
+		text := strings.TrimLeft(raw, " ")
+		indent := len(raw) - len(text)
+		if strings.HasPrefix(text, "\t") {
+			return nil, fmt.Errorf("line %d: tabs cannot indent YAML", num)
+		}
+		open := depth
+		text, depth, err = yamlStripComment(text, num, depth)
+		if err != nil {
+			return nil, err
+		}
+		if text == "" {
+			continue
+		}
+		if open == 0 && indent == 0 && (text == "---" || text == "...") {
+			if len(lines) > 0 {
+				return nil, fmt.Errorf("line %d: only one YAML document is supported", num)
+			}
+			continue
+		}
+		if open > 0 {
+			lines[len(lines)-1].text += " " + text
+		} else {
+			lines = append(lines, yamlLine{num: num, indent: indent, text: text})
+		}
+	}
+	if depth > 0 {
+		return nil, fmt.Errorf("line %d: unclosed [ or {", lines[len(lines)-1].num)
+	}
+	return lines, nil
+}
+
+// yamlStripComment removes a # comment and trailing blanks from text, which
+// starts inside depth flow brackets, and returns the depth at its end.
+// Brackets only count where a flow collection can be: not in `key: a[0]`.
+func yamlStripComment(text string, num, depth int) (string, int, error) {
+	var quote byte
+	last := byte(' ') // the last non-blank byte outside a scalar
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case quote == '"' && c == '\\':
+			i++
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			// a quote only starts a scalar where one can start
+			if i == 0 || strings.IndexByte(" [{,:-", text[i-1]) >= 0 {
+				quote = c
+			}
+		case c == '#' && (i == 0 || text[i-1] == ' ' || text[i-1] == '\t'):
+			text = text[:i]
+		case (c == '[' || c == '{') && (depth > 0 || strings.IndexByte(" :-", last) >= 0):
+			depth++
+		case (c == ']' || c == '}') && depth > 0:
+			depth--
+		}
+		if c != ' ' && c != '\t' {
+			last = c
+		}
+	}
+	if quote != 0 {
+		return "", 0, fmt.Errorf("line %d: unterminated quoted string", num)
+	}
+	return strings.TrimRight(text, " \t"), depth, nil
+}
+
+// yamlParser builds the value of a list of lines.
+type yamlParser struct {
+	lines []yamlLine
+	i     int // the next line
+}
+
+func (p *yamlParser) errorf(format string, args ...any) error {
+	num := 0
+	if p.i < len(p.lines) {
+		num = p.lines[p.i].num
+	} else if len(p.lines) > 0 {
+		num = p.lines[len(p.lines)-1].num
+	}
+	return fmt.Errorf("line %d: %s", num, fmt.Sprintf(format, args...))
+}
+
+// block parses the mapping or sequence whose lines start at indent.
+func (p *yamlParser) block(indent int) (any, error) {
+	if isYAMLItem(p.lines[p.i].text) {
+		return p.sequence(indent)
+	}
+	return p.mapping(indent)
+}
+
+func isYAMLItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+// sequence parses the "- item" lines at indent.
+func (p *yamlParser) sequence(indent int) (any, error) {
+	list := []any{}
+	for p.i < len(p.lines) && p.lines[p.i].indent == indent && isYAMLItem(p.lines[p.i].text) {
+		ln := p.lines[p.i]
+		rest := strings.TrimLeft(ln.text[1:], " ")
+		if rest == "" {
+			p.i++
+			if p.i == len(p.lines) || p.lines[p.i].indent <= indent {
+				list = append(list, nil)
+				continue
+			}
+			v, err := p.block(p.lines[p.i].indent)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+			continue
+		}
+		if _, _, ok := splitYAMLKey(rest); ok || isYAMLItem(rest) {
+			// "- key: value" or "- - x": a block that starts on the item's
+			// line, indented to where its text starts
+			p.lines[p.i] = yamlLine{num: ln.num, indent: indent + len(ln.text) - len(rest), text: rest}
+			v, err := p.block(p.lines[p.i].indent)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+			continue
+		}
+		v, err := p.value(rest)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, v)
+		p.i++
+	}
+	if p.i < len(p.lines) && p.lines[p.i].indent > indent {
+		return nil, p.errorf("unexpected indentation")
+	}
+	return list, nil
+}
+
+// mapping parses the "key: value" lines at indent.
+func (p *yamlParser) mapping(indent int) (any, error) {
+	m := map[string]any{}
+	for p.i < len(p.lines) && p.lines[p.i].indent == indent {
+		text := p.lines[p.i].text
+		if isYAMLItem(text) {
+			break // a sequence that is the value of the key before
+		}
+		key, rest, ok := splitYAMLKey(text)
+		if !ok {
+			return nil, p.errorf("expected key: value, got %q", text)
+		}
+		if _, dup := m[key]; dup {
+			return nil, p.errorf("duplicate key %q", key)
+		}
+		var v any
+		var err error
+		if rest != "" {
+			v, err = p.value(rest)
+			p.i++
+		} else {
+			p.i++
+			switch {
+			case p.i == len(p.lines):
+			case p.lines[p.i].indent > indent:
+				v, err = p.block(p.lines[p.i].indent)
+			case p.lines[p.i].indent == indent && isYAMLItem(p.lines[p.i].text):
+				v, err = p.sequence(indent)
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+		m[key] = v
+	}
+	if p.i < len(p.lines) && p.lines[p.i].indent > indent {
+		return nil, p.errorf("unexpected indentation")
+	}
+	return m, nil
+}
+
+// value parses the scalar or flow collection that makes up all of s.
+func (p *yamlParser) value(s string) (any, error) {
+	var v any
+	var err error
+	rest := s
+	switch s[0] {
+	case '[', '{', '"', '\'':
+		v, rest, err = yamlFlow(s)
+	case '&', '*', '!', '|', '>', '@', '`':
+		return nil, p.errorf("unsupported YAML syntax %q", s)
+	default:
+		v, rest = yamlPlain(s), ""
+	}
+	if err != nil {
+		return nil, p.errorf("%v", err)
+	}
+	if rest = strings.TrimLeft(rest, " "); rest != "" {
+		return nil, p.errorf("unexpected %q after value", rest)
+	}
+	return v, nil
+}
+
+// splitYAMLKey splits `key: value` into the key and the value text. A
+// quoted key may contain ':'; a plain key ends at the first ": ".
+func splitYAMLKey(text string) (key, rest string, ok bool) {
+	if text[0] == '"' || text[0] == '\'' {
+		k, after, err := yamlQuoted(text)
+		if err != nil || !strings.HasPrefix(after, ":") {
+			return "", "", false
+		}
+		after = after[1:]
+		if after != "" && after[0] != ' ' {
+			return "", "", false
+		}
+		return k, strings.TrimLeft(after, " "), true
+	}
+	if strings.IndexByte("[{&*!|>", text[0]) >= 0 || strings.HasPrefix(text, "? ") {
+		return "", "", false
+	}
+	i := strings.Index(text, ": ")
+	if i < 0 {
+		if !strings.HasSuffix(text, ":") {
+			return "", "", false
+		}
+		i = len(text) - 1
+	}
+	return strings.TrimRight(text[:i], " "), strings.TrimLeft(text[i+1:], " "), true
+}
+
+// yamlFlow parses the flow collection or quoted scalar s starts with and
+// returns what follows it.
+func yamlFlow(s string) (any, string, error) {
+	switch s[0] {
+	case '"', '\'':
+		return yamlQuoted(s)
+	case '[':
+		list := []any{}
+		s = strings.TrimLeft(s[1:], " ")
+		for !strings.HasPrefix(s, "]") {
+			v, rest, err := yamlFlowItem(s, false)
+			if err != nil {
+				return nil, "", err
+			}
+			list = append(list, v)
+			if s, err = yamlFlowNext(rest, ']'); err != nil {
+				return nil, "", err
+			}
+		}
+		return list, s[1:], nil
+	case '{':
+		m := map[string]any{}
+		s = strings.TrimLeft(s[1:], " ")
+		for !strings.HasPrefix(s, "}") {
+			k, rest, err := yamlFlowItem(s, true)
+			if err != nil {
+				return nil, "", err
+			}
+			key, isString := k.(string)
+			if !isString {
+				key = fmt.Sprint(k)
+			}
+			rest = strings.TrimLeft(rest, " ")
+			if !strings.HasPrefix(rest, ":") {
+				return nil, "", fmt.Errorf("expected ':' after key %q", key)
+			}
+			if _, dup := m[key]; dup {
+				return nil, "", fmt.Errorf("duplicate key %q", key)
+			}
+			v, rest, err := yamlFlowItem(strings.TrimLeft(rest[1:], " "), false)
+			if err != nil {
+				return nil, "", err
+			}
+			m[key] = v
+			if s, err = yamlFlowNext(rest, '}'); err != nil {
+				return nil, "", err
+			}
+		}
+		return m, s[1:], nil
+	}
+	return nil, "", fmt.Errorf("unexpected %q", s)
+}
+
+// yamlFlowItem parses one element of a flow collection. Plain scalars end
+// at ',', ']' or '}', and keys also at ':'.
+func yamlFlowItem(s string, key bool) (any, string, error) {
+	if s == "" {
+		return nil, "", fmt.Errorf("unclosed [ or {")
+	}
+	switch s[0] {
+	case '[', '{', '"', '\'':
+		return yamlFlow(s)
+	case ',', ']', '}':
+		return nil, s, nil
+	}
+	stop := ",]}"
+	if key {
+		stop += ":"
+	}
+	i := strings.IndexAny(s, stop)
+	if i < 0 {
+		return nil, "", fmt.Errorf("unclosed [ or {")
+	}
+	return yamlPlain(strings.TrimRight(s[:i], " ")), s[i:], nil
+}
+
+// yamlFlowNext skips the ',' after an element of a flow collection closed
+// by end and returns the text starting with the next element or end.
+func yamlFlowNext(s string, end byte) (string, error) {
+	s = strings.TrimLeft(s, " ")
+	switch {
+	case s == "":
+		return "", fmt.Errorf("unclosed %c", end)
+	case s[0] == end:
+		return s, nil
+	case s[0] == ',':
+		return strings.TrimLeft(s[1:], " "), nil
+	}
+	return "", fmt.Errorf("expected ',' or %c, got %q", end, s)
+}
+
+// yamlQuoted decodes the single- or double-quoted scalar s starts with and
+// returns what follows it.
+func yamlQuoted(s string) (string, string, error) {
+	q := s[0]
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == q && q == '\'' && i+1 < len(s) && s[i+1] == '\'':
+			b.WriteByte('\'')
+			i++
+		case c == q:
+			return b.String(), s[i+1:], nil
+		case c == '\\' && q == '"':
+			n, err := yamlEscape(&b, s[i+1:])
+			if err != nil {
+				return "", "", err
+			}
+			i += n
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", "", fmt.Errorf("unterminated quoted string")
+}
+
+// yamlEscapes are the one-character escapes of double-quoted scalars.
+var yamlEscapes = map[byte]string{
+	'0': "\x00", 'a': "\a", 'b': "\b", 't': "\t", '\t': "\t", 'n': "\n",
+	'v': "\v", 'f': "\f", 'r': "\r", 'e': "\x1b", ' ': " ", '"': "\"",
+	'/': "/", '\\': "\\", 'N': "\u0085", '_': "\u00a0", 'L': "\u2028", 'P': "\u2029",
+}
+
+// yamlEscape writes the character of the escape sequence s starts with
+// (after its backslash) to b and returns the length of the sequence.
+func yamlEscape(b *strings.Builder, s string) (int, error) {
+	if s == "" {
+		return 0, fmt.Errorf("unterminated quoted string")
+	}
+	if r, ok := yamlEscapes[s[0]]; ok {
+		b.WriteString(r)
+		return 1, nil
+	}
+	digits := map[byte]int{'x': 2, 'u': 4, 'U': 8}[s[0]]
+	if digits == 0 || len(s) <= digits {
+		return 0, fmt.Errorf("invalid escape \\%c", s[0])
+	}
+	v, err := strconv.ParseUint(s[1:1+digits], 16, 32)
+	if err != nil || v > utf8.MaxRune {
+		return 0, fmt.Errorf("invalid escape \\%s", s[:1+digits])
+	}
+	b.WriteRune(rune(v))
+	return 1 + digits, nil
+}
+
+// yamlPlain resolves a plain scalar: null, a boolean, a number or else a
+// string.
+func yamlPlain(s string) any {
+	switch s {
+	case "", "~", "null", "Null", "NULL":
+		return nil
+	case "true", "True", "TRUE":
+		return true
+	case "false", "False", "FALSE":
+		return false
+	}
+	if strings.Contains(s, "_") {
+		return s
+	}
+	if n, err := strconv.ParseInt(s, 0, 64); err == nil {
+		return n
+	}
+	if strings.Trim(s, "0123456789.eE+-") == "" {
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return json.Number(strconv.FormatFloat(f, 'g', -1, 64))
+		}
+	}
+	return s
+}